@@ -0,0 +1,287 @@
+package grovelog
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrWriterClosed is returned by writes to a closed AsyncWriter
+var ErrWriterClosed = errors.New("grovelog: writer closed")
+
+// AsyncOptions configures NewAsyncWriter
+type AsyncOptions struct {
+	// QueueSize is the number of records buffered in memory, 1024 if not positive
+	QueueSize int
+	// SpillPath is the on-disk journal records go to while the queue is
+	// full or the sink fails. They are replayed once the sink accepts
+	// writes again. Empty drops these records instead
+	SpillPath string
+	// MaxSpillBytes bounds the journal size, 64 MiB if not positive.
+	// Records not fitting are dropped
+	MaxSpillBytes int64
+	// RetryInterval is the period of replaying spilled records while no
+	// records are queued, one second if not positive
+	RetryInterval time.Duration
+}
+
+// AsyncWriter writes records to a sink in a background goroutine, so slow
+// sinks don't block logging. Every Write is one record, as written by the
+// grovelog handlers. Spilled records are replayed once the queue is empty,
+// every RetryInterval while it stays empty and on Close, so ordering is
+// only preserved while the sink keeps up
+type AsyncWriter struct {
+	out   io.Writer
+	opts  AsyncOptions
+	queue chan []byte
+	done  chan struct{}
+
+	mu      sync.RWMutex // Guards closed against sends on queue
+	closed  bool
+	dropped atomic.Uint64
+
+	spillMu     sync.Mutex
+	spill       *os.File
+	spillSize   int64 // End of the journaled records
+	spillOffset int64 // Start of the records not replayed yet
+}
+
+// NewAsyncWriter returns an AsyncWriter writing to out. The journal at
+// opts.SpillPath is created if needed and records left over from a
+// previous run are replayed first
+func NewAsyncWriter(out io.Writer, opts AsyncOptions) (*AsyncWriter, error) {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1024
+	}
+	if opts.MaxSpillBytes <= 0 {
+		opts.MaxSpillBytes = 64 << 20
+	}
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = time.Second
+	}
+
+	w := &AsyncWriter{
+		out:   out,
+		opts:  opts,
+		queue: make(chan []byte, opts.QueueSize),
+		done:  make(chan struct{}),
+	}
+	if opts.SpillPath != "" {
+		f, err := os.OpenFile(opts.SpillPath, os.O_CREATE|os.O_RDWR, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		size, err := spillEnd(f)
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		w.spill, w.spillSize = f, size
+	}
+
+	go w.run()
+	return w, nil
+}
+
+// Write queues a copy of p, spilling it to the journal if the queue is full
+func (w *AsyncWriter) Write(p []byte) (int, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return 0, ErrWriterClosed
+	}
+
+	record := append([]byte(nil), p...)
+	select {
+	case w.queue <- record:
+	default:
+		w.spillRecord(record)
+	}
+	return len(p), nil
+}
+
+// Dropped returns the number of records lost because the journal was
+// full or disabled
+func (w *AsyncWriter) Dropped() uint64 {
+	return w.dropped.Load()
+}
+
+// Close stops accepting records, waits until the queued ones are written
+// or spilled, replays the journal once more and closes it, keeping only
+// the records not replayed yet
+func (w *AsyncWriter) Close() error {
+	w.mu.Lock()
+	first := !w.closed
+	if first {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+
+	<-w.done
+	if !first || w.spill == nil {
+		return nil
+	}
+	w.spillMu.Lock()
+	defer w.spillMu.Unlock()
+	err := w.dropReplayed()
+	return errors.Join(err, w.spill.Close())
+}
+
+func (w *AsyncWriter) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.opts.RetryInterval)
+	defer ticker.Stop()
+
+	w.replay()
+	for {
+		select {
+		case record, ok := <-w.queue:
+			if !ok {
+				w.replay()
+				return
+			}
+			if _, err := w.out.Write(record); err != nil {
+				w.spillRecord(record)
+				continue
+			}
+			if len(w.queue) == 0 {
+				w.replay()
+			}
+		case <-ticker.C:
+			w.replay()
+		}
+	}
+}
+
+// spillRecord appends a length prefixed record to the journal
+func (w *AsyncWriter) spillRecord(record []byte) {
+	w.spillMu.Lock()
+	defer w.spillMu.Unlock()
+
+	size := int64(4 + len(record))
+	if w.spill == nil || w.spillSize+size > w.opts.MaxSpillBytes {
+		w.dropped.Add(1)
+		return
+	}
+	frame := binary.BigEndian.AppendUint32(make([]byte, 0, size), uint32(len(record))) //nolint:gosec
+	if _, err := w.spill.WriteAt(append(frame, record...), w.spillSize); err != nil {
+		w.dropped.Add(1)
+		return
+	}
+	w.spillSize += size
+}
+
+// replay writes the journaled records to the sink one at a time,
+// advancing spillOffset past every record written, and empties the
+// journal once all are. It stops at the first failing record, which
+// stays in the journal for the next replay. A crash during replay only
+// writes the records replayed so far again on the next run
+func (w *AsyncWriter) replay() {
+	for {
+		record, ok := w.nextSpilled()
+		if !ok {
+			return
+		}
+		if _, err := w.out.Write(record); err != nil {
+			return
+		}
+
+		w.spillMu.Lock()
+		w.spillOffset += int64(4 + len(record))
+		w.compactSpill()
+		w.spillMu.Unlock()
+	}
+}
+
+// nextSpilled reads the record at spillOffset, false if there is none
+func (w *AsyncWriter) nextSpilled() ([]byte, bool) {
+	w.spillMu.Lock()
+	defer w.spillMu.Unlock()
+	if w.spill == nil || w.spillOffset+4 > w.spillSize {
+		w.compactSpill()
+		return nil, false
+	}
+
+	var header [4]byte
+	if _, err := w.spill.ReadAt(header[:], w.spillOffset); err != nil {
+		return nil, false
+	}
+	record := make([]byte, binary.BigEndian.Uint32(header[:]))
+	if _, err := w.spill.ReadAt(record, w.spillOffset+4); err != nil {
+		return nil, false
+	}
+	return record, true
+}
+
+// spillEnd returns the end of the last complete record of the journal f,
+// dropping the torn write of a crashed process
+func spillEnd(f *os.File) (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	var end int64
+	var header [4]byte
+	for end+4 <= info.Size() {
+		if _, err := f.ReadAt(header[:], end); err != nil {
+			return 0, err
+		}
+		next := end + 4 + int64(binary.BigEndian.Uint32(header[:]))
+		if next > info.Size() {
+			break
+		}
+		end = next
+	}
+	return end, nil
+}
+
+// dropReplayed rewrites the journal without the replayed records. The
+// rest is copied to a new file renamed over the journal, so a crash
+// leaves either journal intact
+func (w *AsyncWriter) dropReplayed() error {
+	if w.spillOffset == 0 {
+		return nil
+	}
+	rest := make([]byte, w.spillSize-w.spillOffset)
+	if _, err := w.spill.ReadAt(rest, w.spillOffset); err != nil {
+		return err
+	}
+	tmp := w.opts.SpillPath + ".tmp"
+	if err := os.WriteFile(tmp, rest, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, w.opts.SpillPath)
+}
+
+// compactSpill empties the journal once every record is replayed
+func (w *AsyncWriter) compactSpill() {
+	if w.spill == nil || w.spillOffset < w.spillSize {
+		return
+	}
+	if err := w.spill.Truncate(0); err == nil {
+		w.spillSize, w.spillOffset = 0, 0
+	}
+}
+
+// Drain closes the writer like Close, but gives up waiting for the queued
+// records when ctx is done. The remaining records are then written in
+// the background
+func (w *AsyncWriter) Drain(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- w.Close()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,93 @@
+// Package audit provides a logger for audit trails, distinct from best-effort
+// application logging: every event must name its actor, action, target and
+// outcome, gets a monotonically increasing sequence number and is written
+// synchronously, reporting failures instead of dropping the event
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Keys of the attributes of audit records
+const (
+	SeqKey     = "seq"
+	ActorKey   = "actor"
+	ActionKey  = "action"
+	TargetKey  = "target"
+	OutcomeKey = "outcome"
+)
+
+// Message is the message of audit records
+const Message = "audit"
+
+// ErrMissingField is returned for events without a required field
+var ErrMissingField = errors.New("audit: missing required field")
+
+// Event is an audited action
+type Event struct {
+	Actor   string // Who acted, e.g. a user ID
+	Action  string // What was done, e.g. "user.delete"
+	Target  string // What it was done to
+	Outcome string // E.g. "success", "denied" or "failure"
+
+	Attrs []slog.Attr // Additional attributes
+}
+
+// Logger writes audit events to a handler, e.g. a grovelog JSON handler
+// writing to a grovelog.ChainWriter. It is safe for concurrent use;
+// events are written one at a time in sequence order
+type Logger struct {
+	h slog.Handler
+
+	mu  sync.Mutex
+	seq uint64
+}
+
+// New returns a Logger writing to h whose first event gets sequence
+// number next, e.g. 1 or the last number of an existing trail plus one
+func New(h slog.Handler, next uint64) *Logger {
+	return &Logger{h: h, seq: next}
+}
+
+// Log validates and writes e at Info level, bypassing the level of the
+// handler. It blocks until the handler returned and returns its error;
+// the sequence number is only consumed by written events
+func (l *Logger) Log(ctx context.Context, e Event) error { //nolint:gocritic
+	for _, f := range [...][2]string{
+		{ActorKey, e.Actor}, {ActionKey, e.Action}, {TargetKey, e.Target}, {OutcomeKey, e.Outcome},
+	} {
+		if f[1] == "" {
+			return fmt.Errorf("%w: %s", ErrMissingField, f[0])
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, Message, 0)
+	r.AddAttrs(
+		slog.Uint64(SeqKey, l.seq),
+		slog.String(ActorKey, e.Actor),
+		slog.String(ActionKey, e.Action),
+		slog.String(TargetKey, e.Target),
+		slog.String(OutcomeKey, e.Outcome),
+	)
+	r.AddAttrs(e.Attrs...)
+	if err := l.h.Handle(ctx, r); err != nil {
+		return fmt.Errorf("audit: writing event %d: %w", l.seq, err)
+	}
+	l.seq++
+	return nil
+}
+
+// Next returns the sequence number of the next event
+func (l *Logger) Next() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.seq
+}
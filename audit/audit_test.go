@@ -0,0 +1,51 @@
+package audit_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/AlonMell/grovelog"
+	"github.com/AlonMell/grovelog/audit"
+)
+
+// TestAuditLogger tests the audit logger of the audit package
+func TestAuditLogger(t *testing.T) {
+	var out bytes.Buffer
+	h := grovelog.NewHandler(grovelog.NewChainWriter(&out, grovelog.ChainOptions{}),
+		grovelog.NewOptions(slog.LevelError, "", grovelog.JSON))
+	logger := audit.New(h, 1)
+	ctx := context.Background()
+
+	if err := logger.Log(ctx, audit.Event{Actor: "alice", Action: "user.delete"}); !errors.Is(err, audit.ErrMissingField) {
+		t.Errorf("Expected ErrMissingField, got %v", err)
+	}
+	for _, target := range []string{"bob", "carol"} {
+		event := audit.Event{Actor: "alice", Action: "user.delete", Target: target, Outcome: "success"}
+		if err := logger.Log(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if logger.Next() != 3 {
+		t.Errorf("Expected next sequence number 3, got %d", logger.Next())
+	}
+
+	if _, err := grovelog.VerifyChain(bytes.NewReader(out.Bytes())); err != nil {
+		t.Error(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 audit records despite the Error level, got %q", out.String())
+	}
+	var jsonMap map[string]any
+	if err := json.Unmarshal([]byte(lines[1]), &jsonMap); err != nil {
+		t.Fatal(err)
+	}
+	if jsonMap[audit.SeqKey] != float64(2) || jsonMap[audit.TargetKey] != "carol" || jsonMap["msg"] != audit.Message {
+		t.Errorf("Unexpected audit record %v", jsonMap)
+	}
+}
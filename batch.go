@@ -0,0 +1,301 @@
+package grovelog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// BatchOptions configures the buffering of a BatchHandler
+type BatchOptions struct {
+	// Level is the minimum level of the stored records, Info if nil
+	Level slog.Leveler
+	// MaxRecords is the number of records buffered before they are
+	// flushed, and the maximum size of a batch, 1000 if not positive
+	MaxRecords int
+	// MaxBuffered is the number of records buffered while flushes are
+	// slow or failing, newer records are dropped beyond it, 10 times
+	// MaxRecords if not positive
+	MaxBuffered int
+	// FlushInterval is the period of flushing the buffered records,
+	// one second if not positive
+	FlushInterval time.Duration
+	// FlushTimeout bounds the background flushes, 30 seconds if not positive
+	FlushTimeout time.Duration
+	// MaxRetries is the number of times a failed batch is flushed again
+	// before it is dropped, 3 if zero, none if negative
+	MaxRetries int
+	// OnError is called with the errors of background flushes and the
+	// number of dropped records, nil ignores them
+	OnError func(err error)
+}
+
+// BatchHandler is a slog.Handler buffering records and passing them in
+// batches to a flush function, the base of the database and file sinks.
+// Handle only buffers records, a background goroutine flushes them every
+// FlushInterval and as soon as MaxRecords are buffered, and Drain flushes
+// the rest. A failed batch is retried before newer records, up to
+// MaxRetries times. Handlers derived with WithAttrs and WithGroup share
+// the buffer
+type BatchHandler struct {
+	batch  *batch
+	groups []string      // Open groups
+	attrs  [][]slog.Attr // Attributes per group level, len(groups)+1
+}
+
+// batch holds the buffered records of a BatchHandler and its derivatives
+type batch struct {
+	opts  BatchOptions
+	flush func(context.Context, Records) error
+
+	mu      sync.Mutex
+	records Records
+	dropped int
+	drained bool
+
+	flushMu  sync.Mutex // Serializes flushes, guards failed and attempts
+	failed   Records    // Batch of the last failed flush
+	attempts int
+
+	ctx    context.Context // Canceled when Drain gives up
+	cancel context.CancelFunc
+	full   chan struct{}
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// NewBatchHandler creates a BatchHandler passing batches of records to flush,
+// in the order they were handled, never concurrently. flush gives up when
+// ctx is done, e.g. when the ctx given to Drain expires
+func NewBatchHandler(flush func(ctx context.Context, records Records) error, opts BatchOptions) *BatchHandler {
+	if opts.Level == nil {
+		opts.Level = slog.LevelInfo
+	}
+	if opts.MaxRecords <= 0 {
+		opts.MaxRecords = 1000
+	}
+	if opts.MaxBuffered <= 0 {
+		opts.MaxBuffered = 10 * opts.MaxRecords
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	if opts.FlushTimeout <= 0 {
+		opts.FlushTimeout = 30 * time.Second
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = 3
+	}
+
+	b := &batch{
+		opts:  opts,
+		flush: flush,
+		full:  make(chan struct{}, 1),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	b.ctx, b.cancel = context.WithCancel(context.Background())
+	go b.run()
+	return &BatchHandler{batch: b, attrs: make([][]slog.Attr, 1)}
+}
+
+// Enabled reports whether level is at or above the handler's level
+func (h *BatchHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.batch.opts.Level.Level()
+}
+
+// Handle buffers the record, waking the background flush once a batch is full
+func (h *BatchHandler) Handle(_ context.Context, r slog.Record) error { //nolint:gocritic
+	rec := captureRecord(h.groups, h.attrs, r)
+
+	b := h.batch
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case b.drained:
+		return nil
+	case len(b.records) >= b.opts.MaxBuffered:
+		b.dropped++
+		return nil
+	}
+	b.records = append(b.records, rec)
+	if len(b.records) >= b.opts.MaxRecords {
+		select {
+		case b.full <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// WithAttrs returns a BatchHandler adding attrs to the records it stores
+func (h *BatchHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.attrs = slices.Clone(h.attrs)
+	last := slices.Clip(h2.attrs[len(h.groups)])
+	for _, a := range attrs {
+		last = appendResolved(last, a)
+	}
+	h2.attrs[len(h.groups)] = last
+	return &h2
+}
+
+// WithGroup returns a BatchHandler nesting the attributes that follow in group name
+func (h *BatchHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.groups = append(slices.Clip(h.groups), name)
+	h2.attrs = append(slices.Clip(h.attrs), nil)
+	return &h2
+}
+
+// Flush flushes the buffered records, giving up when ctx is done
+func (h *BatchHandler) Flush(ctx context.Context) error {
+	return h.batch.flushAll(ctx)
+}
+
+// Drain stops the background flushes and flushes the buffered records,
+// retrying a failed batch right away, giving up when ctx is done. Records handled afterwards are dropped
+func (h *BatchHandler) Drain(ctx context.Context) error {
+	b := h.batch
+	b.mu.Lock()
+	if b.drained {
+		b.mu.Unlock()
+		return nil
+	}
+	b.drained = true
+	b.mu.Unlock()
+
+	close(b.stop)
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		b.cancel()
+		return ctx.Err()
+	}
+	defer b.cancel()
+	for {
+		err := b.flushAll(ctx)
+		if err == nil || ctx.Err() != nil || !b.retrying() {
+			return err
+		}
+	}
+}
+
+func (b *batch) run() {
+	defer close(b.done)
+	ticker := time.NewTicker(b.opts.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+		case <-b.full:
+		}
+
+		ctx, cancel := context.WithTimeout(b.ctx, b.opts.FlushTimeout)
+		err := b.flushAll(ctx)
+		cancel()
+		if err != nil && b.opts.OnError != nil {
+			b.opts.OnError(err)
+		}
+	}
+}
+
+// flushAll passes the failed batch, then the buffered records, to flush
+// in batches of at most MaxRecords. A failed batch is kept for the next
+// flush until it failed MaxRetries more times
+func (b *batch) flushAll(ctx context.Context) error {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	var errs []error
+	b.mu.Lock()
+	if b.dropped > 0 {
+		errs = append(errs, fmt.Errorf("grovelog: dropped %d records, the batch buffer is full", b.dropped))
+		b.dropped = 0
+	}
+	b.mu.Unlock()
+
+	for {
+		records := b.failed
+		if records == nil {
+			records = b.take()
+		}
+		if len(records) == 0 {
+			return errors.Join(errs...)
+		}
+
+		err := b.flush(ctx, records)
+		if err == nil {
+			b.failed, b.attempts = nil, 0
+			continue
+		}
+		b.attempts++
+		if b.attempts > b.opts.MaxRetries {
+			err = fmt.Errorf("grovelog: dropped %d records after %d failed flushes: %w", len(records), b.attempts, err)
+			b.failed, b.attempts = nil, 0
+		} else {
+			b.failed = records
+		}
+		return errors.Join(append(errs, err)...)
+	}
+}
+
+// retrying reports whether a failed batch is kept for another flush
+func (b *batch) retrying() bool {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+	return b.failed != nil
+}
+
+// take removes the oldest batch from the buffer
+func (b *batch) take() Records {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := min(len(b.records), b.opts.MaxRecords)
+	records := b.records[:n:n]
+	if n == len(b.records) {
+		b.records = nil
+	} else {
+		b.records = slices.Clone(b.records[n:])
+	}
+	return records
+}
+
+// recordAttrsJSON returns the attributes of r as a JSON object. Values
+// JSON can't encode, e.g. NaN, are stored as their text, so one bad
+// record doesn't fail its batch
+func recordAttrsJSON(r *Record) []byte {
+	if b, err := appendJSONGroup(nil, r.Attrs, "", false); err == nil {
+		return b
+	}
+	b, _ := appendJSONGroup(nil, jsonSafeAttrs(r.Attrs), "", false)
+	return b
+}
+
+// jsonSafeAttrs returns attrs with the values JSON can't encode replaced by their text
+func jsonSafeAttrs(attrs []slog.Attr) []slog.Attr {
+	safe := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		v := a.Value.Resolve()
+		if v.Kind() == slog.KindGroup {
+			v = slog.GroupValue(jsonSafeAttrs(v.Group())...)
+		} else if _, err := appendJSONValue(nil, v.Any(), "", false); err != nil {
+			v = slog.StringValue(valueText(v))
+		}
+		safe[i] = slog.Attr{Key: a.Key, Value: v}
+	}
+	return safe
+}
@@ -0,0 +1,143 @@
+package grovelog
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned for records dropped by an open circuit
+// breaker without a fallback handler
+var ErrCircuitOpen = errors.New("grovelog: circuit breaker open")
+
+// BreakerOptions configures NewBreakerHandler
+type BreakerOptions struct {
+	// Failures is the number of consecutive errors opening the circuit, 5 if not positive
+	Failures int
+	// ProbeInterval is the time after which an open circuit lets one
+	// record through to probe the primary handler, 10 seconds if not positive
+	ProbeInterval time.Duration
+}
+
+// NewBreakerHandler returns a handler writing to primary, e.g. a network
+// sink, through a circuit breaker. After opts.Failures consecutive errors
+// the circuit opens and records go to fallback, which may be nil to drop
+// them, without waiting on the failing sink. Records that fail on primary
+// are written to fallback too. Every opts.ProbeInterval one record probes
+// primary and closes the circuit on success
+func NewBreakerHandler(primary, fallback slog.Handler, opts BreakerOptions) slog.Handler {
+	if opts.Failures <= 0 {
+		opts.Failures = 5
+	}
+	if opts.ProbeInterval <= 0 {
+		opts.ProbeInterval = 10 * time.Second
+	}
+	return &breakerHandler{
+		primary:  primary,
+		fallback: fallback,
+		state:    &breakerState{opts: opts},
+	}
+}
+
+// breakerState is the circuit state shared by a handler and its derivatives
+type breakerState struct {
+	opts BreakerOptions
+
+	mu       sync.Mutex
+	failures int
+	open     bool
+	openedAt time.Time
+	probing  bool
+}
+
+// allow reports whether a record may be written to the primary handler
+func (s *breakerState) allow(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.open {
+		return true
+	}
+	if s.probing || now.Sub(s.openedAt) < s.opts.ProbeInterval {
+		return false
+	}
+	s.probing = true
+	return true
+}
+
+// report updates the state with the result of writing to the primary handler
+func (s *breakerState) report(now time.Time, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.probing {
+		s.probing = false
+		if err != nil {
+			s.openedAt = now
+			return
+		}
+		s.open = false
+	}
+
+	if err == nil {
+		s.failures = 0
+		return
+	}
+	s.failures++
+	if s.failures >= s.opts.Failures && !s.open {
+		s.open = true
+		s.openedAt = now
+	}
+}
+
+type breakerHandler struct {
+	primary  slog.Handler
+	fallback slog.Handler
+	state    *breakerState
+}
+
+// Enabled reports whether the primary or the fallback handler handles level
+func (h *breakerHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.primary.Enabled(ctx, level) || h.fallback != nil && h.fallback.Enabled(ctx, level)
+}
+
+// Handle writes the record to the primary handler while the circuit
+// is closed or probing, and to the fallback otherwise or on failure
+func (h *breakerHandler) Handle(ctx context.Context, r slog.Record) error { //nolint:gocritic
+	var err error
+	if h.primary.Enabled(ctx, r.Level) {
+		err = ErrCircuitOpen
+		if h.state.allow(time.Now()) {
+			err = h.primary.Handle(ctx, r)
+			h.state.report(time.Now(), err)
+			if err == nil {
+				return nil
+			}
+		}
+	}
+
+	if h.fallback != nil && h.fallback.Enabled(ctx, r.Level) {
+		return h.fallback.Handle(ctx, r)
+	}
+	return err
+}
+
+// WithAttrs returns a breakerHandler wrapping the handlers with attrs,
+// sharing the circuit state
+func (h *breakerHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := &breakerHandler{primary: h.primary.WithAttrs(attrs), state: h.state}
+	if h.fallback != nil {
+		c.fallback = h.fallback.WithAttrs(attrs)
+	}
+	return c
+}
+
+// WithGroup returns a breakerHandler wrapping the grouped handlers,
+// sharing the circuit state
+func (h *breakerHandler) WithGroup(name string) slog.Handler {
+	c := &breakerHandler{primary: h.primary.WithGroup(name), state: h.state}
+	if h.fallback != nil {
+		c.fallback = h.fallback.WithGroup(name)
+	}
+	return c
+}
@@ -0,0 +1,47 @@
+package grovelog
+
+import "sync"
+
+// Default sizes of the Color format buffers, see Options.BufferSize
+const (
+	defaultBufferSize    = 1 << 10
+	defaultMaxBufferSize = 64 << 10
+)
+
+// bufferPool recycles record buffers, dropping those grown beyond max
+type bufferPool struct {
+	pool sync.Pool
+	max  int
+}
+
+// newBufferPool creates a pool of buffers with capacity size, retaining
+// buffers up to capacity max. Non-positive values select the defaults
+func newBufferPool(size, maxSize int) *bufferPool {
+	if size <= 0 {
+		size = defaultBufferSize
+	}
+	if maxSize <= 0 {
+		maxSize = defaultMaxBufferSize
+	}
+	p := &bufferPool{max: maxSize}
+	p.pool.New = func() any {
+		buf := make([]byte, 0, size)
+		return &buf
+	}
+	return p
+}
+
+// get returns an empty buffer
+func (p *bufferPool) get() *[]byte {
+	buf, _ := p.pool.Get().(*[]byte)
+	*buf = (*buf)[:0]
+	return buf
+}
+
+// put returns buf to the pool unless it grew beyond the maximum
+func (p *bufferPool) put(buf *[]byte) {
+	if cap(*buf) > p.max {
+		return
+	}
+	p.pool.Put(buf)
+}
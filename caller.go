@@ -0,0 +1,51 @@
+package grovelog
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"strconv"
+
+	"github.com/AlonMell/grovelog/util"
+)
+
+// callerHandler adds a util.CallerKey attribute with the file:line of the
+// call site to records. Call sites in the logging packages or in wrappers
+// registered with util.SkipCallerPackages are replaced by the first frame
+// outside of them
+type callerHandler struct {
+	next slog.Handler
+}
+
+// Enabled reports whether the wrapped handler handles level
+func (h *callerHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle adds the caller attribute and passes the record on
+func (h *callerHandler) Handle(ctx context.Context, r slog.Record) error { //nolint:gocritic
+	var frame runtime.Frame
+	if r.PC != 0 {
+		frame, _ = runtime.CallersFrames([]uintptr{r.PC}).Next()
+	}
+	if frame.PC == 0 || util.IsInternalFrame(frame.Function) {
+		frame, _ = util.CallerFrame()
+	}
+	if frame.File == "" {
+		return h.next.Handle(ctx, r)
+	}
+
+	r = r.Clone()
+	r.AddAttrs(slog.String(util.CallerKey, frame.File+":"+strconv.Itoa(frame.Line)))
+	return h.next.Handle(ctx, r)
+}
+
+// WithAttrs returns a callerHandler wrapping the handler with attrs
+func (h *callerHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &callerHandler{next: h.next.WithAttrs(attrs)}
+}
+
+// WithGroup returns a callerHandler wrapping the grouped handler
+func (h *callerHandler) WithGroup(name string) slog.Handler {
+	return &callerHandler{next: h.next.WithGroup(name)}
+}
@@ -0,0 +1,208 @@
+package grovelog
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ChainKey is the key of the hash added to every record by ChainWriter
+const ChainKey = "chain"
+
+// maxChainLine bounds the length of the lines read by VerifyChain
+const maxChainLine = 64 << 20
+
+// ChainState is the position in a hash chain
+type ChainState struct {
+	Records int    // Records chained so far, checkpoints excluded
+	Hash    []byte // Hash of the last record or checkpoint, nil before the first one
+}
+
+// ChainOptions configures NewChainWriter
+type ChainOptions struct {
+	// CheckpointEvery writes a checkpoint line with the record count and
+	// the current hash after every n records, 0 disables checkpoints.
+	// Checkpoints are chained like records, so removing or inserting one
+	// breaks the chain
+	CheckpointEvery int
+	// Resume continues the chain of an existing file, see VerifyChain
+	Resume ChainState
+}
+
+// ChainWriter makes single line records tamper-evident for audit logs: every
+// record gets a ChainKey field with the SHA-256 of the previous hash and the
+// record, so modified or deleted lines break the chain. JSON records get a
+// "chain" member, others, like Plain records, a trailing chain=<hash>
+type ChainWriter struct {
+	out  io.Writer
+	opts ChainOptions
+
+	mu    sync.Mutex
+	state ChainState
+}
+
+// NewChainWriter returns a ChainWriter writing to out
+func NewChainWriter(out io.Writer, opts ChainOptions) *ChainWriter {
+	return &ChainWriter{out: out, opts: opts, state: opts.Resume}
+}
+
+// Write adds the chain hash to the record p and writes it
+func (w *ChainWriter) Write(p []byte) (int, error) {
+	body := bytes.TrimRight(p, "\n")
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	hash := chainHash(w.state.Hash, body)
+	if _, err := w.out.Write(appendChain(body, hash)); err != nil {
+		return 0, err
+	}
+	w.state = ChainState{Records: w.state.Records + 1, Hash: hash}
+
+	if w.opts.CheckpointEvery > 0 && w.state.Records%w.opts.CheckpointEvery == 0 {
+		checkpoint := checkpointBody(w.state)
+		hash := chainHash(w.state.Hash, checkpoint)
+		if _, err := w.out.Write(appendChain(checkpoint, hash)); err != nil {
+			return 0, err
+		}
+		w.state.Hash = hash
+	}
+	return len(p), nil
+}
+
+// State returns the current position in the chain
+func (w *ChainWriter) State() ChainState {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.state
+}
+
+// ChainError reports where a chain verification failed
+type ChainError struct {
+	Line   int
+	Reason string
+}
+
+// Error implements error
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("grovelog: chain broken at line %d: %s", e.Line, e.Reason)
+}
+
+// VerifyChain checks the chain of records written by ChainWriter and returns
+// the state at the end of r, to resume the chain. It fails with a
+// *ChainError at the first modified, inserted or deleted record or
+// inconsistent checkpoint
+func VerifyChain(r io.Reader) (ChainState, error) {
+	var state ChainState
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, maxChainLine)
+
+	for line := 1; sc.Scan(); line++ {
+		body, hash, ok := splitChain(sc.Text())
+		if !ok {
+			return state, &ChainError{Line: line, Reason: "missing chain hash"}
+		}
+		if want := chainHash(state.Hash, []byte(body)); !bytes.Equal(hash, want) {
+			return state, &ChainError{Line: line, Reason: "hash mismatch"}
+		}
+		if records, head, ok := parseCheckpoint(body); ok {
+			if records != state.Records || !bytes.Equal(head, state.Hash) {
+				return state, &ChainError{Line: line, Reason: "checkpoint mismatch"}
+			}
+			state.Hash = hash
+			continue
+		}
+		state = ChainState{Records: state.Records + 1, Hash: hash}
+	}
+	return state, sc.Err()
+}
+
+// chainHash returns SHA-256(prev || body)
+func chainHash(prev, body []byte) []byte {
+	h := sha256.New()
+	h.Write(prev)
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+// appendChain returns the record body with the chain hash and a newline
+func appendChain(body, hash []byte) []byte {
+	return appendTrailer(body, ChainKey, hex.EncodeToString(hash))
+}
+
+// splitChain is the reverse of appendChain
+func splitChain(line string) (body string, hash []byte, ok bool) {
+	body, encoded, ok := splitTrailer(line, ChainKey)
+	if !ok {
+		return "", nil, false
+	}
+	hash, err := hex.DecodeString(encoded)
+	return body, hash, err == nil && len(hash) == sha256.Size
+}
+
+// appendTrailer returns the record body with a trailing key field and a
+// newline: a JSON member for JSON records, key=value otherwise.
+// value must not need escaping
+func appendTrailer(body []byte, key, value string) []byte {
+	line := make([]byte, 0, len(body)+len(key)+len(value)+8)
+	if bytes.HasSuffix(body, []byte("}")) {
+		line = append(line, body[:len(body)-1]...)
+		if !bytes.Equal(bytes.TrimSpace(line), []byte("{")) { // Not an empty object
+			line = append(line, ',')
+		}
+		line = append(line, `"`+key+`":"`+value+"\"}\n"...)
+		return line
+	}
+	line = append(line, body...)
+	return append(line, " "+key+"="+value+"\n"...)
+}
+
+// splitTrailer is the reverse of appendTrailer, line has no newline
+func splitTrailer(line, key string) (body, value string, ok bool) {
+	member := `"` + key + `":"`
+	if i := strings.LastIndex(line, member); i > 0 && strings.HasSuffix(line, `"}`) {
+		value = line[i+len(member) : len(line)-2]
+		prefix := line[:i]
+		if strings.HasSuffix(prefix, ",") {
+			return prefix[:len(prefix)-1] + "}", value, true
+		}
+		if strings.TrimSpace(prefix) == "{" {
+			return prefix + "}", value, true
+		}
+	}
+	if i := strings.LastIndex(line, " "+key+"="); i >= 0 {
+		return line[:i], line[i+len(key)+2:], true
+	}
+	return "", "", false
+}
+
+// checkpointBody returns the body of a checkpoint line for state, chained
+// like a record body
+func checkpointBody(state ChainState) []byte {
+	body := []byte(`{"checkpoint":` + strconv.Itoa(state.Records) + `,"head":"`)
+	body = hex.AppendEncode(body, state.Hash)
+	return append(body, "\"}"...)
+}
+
+// parseCheckpoint parses the body of a checkpoint line
+func parseCheckpoint(body string) (records int, head []byte, ok bool) {
+	if !strings.HasPrefix(body, `{"checkpoint":`) {
+		return 0, nil, false
+	}
+	var cp struct {
+		Checkpoint int    `json:"checkpoint"`
+		Head       string `json:"head"`
+	}
+	if err := json.Unmarshal([]byte(body), &cp); err != nil {
+		return 0, nil, false
+	}
+	head, err := hex.DecodeString(cp.Head)
+	return cp.Checkpoint, head, err == nil
+}
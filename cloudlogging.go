@@ -0,0 +1,311 @@
+package grovelog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CloudResource is the monitored resource of Cloud Logging entries,
+// e.g. {Type: "gce_instance", Labels: {"instance_id": ..., "zone": ...}}
+type CloudResource struct {
+	Type   string            `json:"type"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// CloudLoggingOptions configures NewCloudLoggingHandler
+type CloudLoggingOptions struct {
+	// LogID is the name of the log in the project, "app" if empty
+	LogID string
+	// ProjectID is the project written to, read from the metadata server if empty
+	ProjectID string
+	// Resource is the monitored resource of the entries, detected for
+	// Cloud Run, GKE and GCE from the environment and the metadata server
+	// if nil, "global" elsewhere
+	Resource *CloudResource
+	// Client sends the write requests and must authorize them, e.g. the
+	// client of golang.org/x/oauth2/google.DefaultClient. Nil authorizes
+	// them with tokens of the default service account from the metadata server
+	Client *http.Client
+	// Endpoint is the API root, "https://logging.googleapis.com" if empty
+	Endpoint string
+	// BatchOptions configures the buffering, every flush is one
+	// entries.write request
+	BatchOptions
+}
+
+// NewCloudLoggingHandler returns a BatchHandler writing records with the
+// Cloud Logging entries.write API, for workloads that can't rely on the
+// logging agent. Levels map to the DEBUG, INFO, WARNING, ERROR and
+// CRITICAL severities, the message and attributes form the JSON payload
+// and the call site of records with a PC the source location. The
+// metadata server is the one of the GCE_METADATA_HOST environment
+// variable if set, like in the Google client libraries
+func NewCloudLoggingHandler(ctx context.Context, opts CloudLoggingOptions) (*BatchHandler, error) { //nolint:gocritic
+	if opts.LogID == "" {
+		opts.LogID = "app"
+	}
+	if opts.Endpoint == "" {
+		opts.Endpoint = "https://logging.googleapis.com"
+	}
+
+	md := &metadataClient{client: &http.Client{Timeout: 2 * time.Second}}
+	if opts.ProjectID == "" {
+		projectID, err := md.get(ctx, "project/project-id")
+		if err != nil {
+			return nil, fmt.Errorf("grovelog: detect the Cloud Logging project: %w", err)
+		}
+		opts.ProjectID = projectID
+	}
+	if opts.Resource == nil {
+		opts.Resource = md.detectResource(ctx, opts.ProjectID)
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{
+			Transport: &metadataTokenTransport{md: md, base: http.DefaultTransport},
+		}
+	}
+
+	w := &cloudLoggingWriter{
+		opts:    opts,
+		url:     strings.TrimSuffix(opts.Endpoint, "/") + "/v2/entries:write",
+		logName: "projects/" + opts.ProjectID + "/logs/" + strings.ReplaceAll(opts.LogID, "/", "%2F"),
+	}
+	return NewBatchHandler(w.write, opts.BatchOptions), nil
+}
+
+// cloudLoggingWriter writes batches of records with entries.write
+type cloudLoggingWriter struct {
+	opts    CloudLoggingOptions
+	url     string
+	logName string
+}
+
+// write sends the records in one entries.write request
+func (w *cloudLoggingWriter) write(ctx context.Context, records Records) error {
+	resource, err := json.Marshal(w.opts.Resource)
+	if err != nil {
+		return err
+	}
+
+	body := append([]byte(`{"logName":`), appendJSONString(nil, w.logName)...)
+	body = append(body, `,"resource":`...)
+	body = append(body, resource...)
+	body = append(body, `,"partialSuccess":true,"entries":[`...)
+	for i := range records {
+		if i > 0 {
+			body = append(body, ',')
+		}
+		body = appendCloudEntry(body, &records[i])
+	}
+	body = append(body, "]}"...)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.opts.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("grovelog: Cloud Logging write: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// appendCloudEntry appends r as a LogEntry. Attribute values JSON can't
+// encode, e.g. NaN, are sent as their text
+func appendCloudEntry(buf []byte, r *Record) []byte {
+	buf = append(buf, `{"timestamp":"`...)
+	buf = r.Time.UTC().AppendFormat(buf, time.RFC3339Nano)
+	buf = append(buf, `","severity":"`...)
+	buf = append(buf, cloudSeverity(r.Level)...)
+	buf = append(buf, '"')
+	if r.PC != 0 {
+		src := recordSource(r.PC)
+		buf = append(buf, `,"sourceLocation":{"file":`...)
+		buf = appendJSONString(buf, src.File)
+		buf = append(buf, `,"line":"`...)
+		buf = strconv.AppendInt(buf, int64(src.Line), 10)
+		buf = append(buf, `","function":`...)
+		buf = appendJSONString(buf, src.Function)
+		buf = append(buf, '}')
+	}
+
+	buf = append(buf, `,"jsonPayload":`...)
+	payload := append([]slog.Attr{slog.String("message", r.Message)}, r.Attrs...)
+	entry, err := appendJSONGroup(buf, payload, "", false)
+	if err != nil {
+		entry, _ = appendJSONGroup(buf, jsonSafeAttrs(payload), "", false)
+	}
+	return append(entry, '}')
+}
+
+// cloudSeverity maps level to a Cloud Logging severity
+func cloudSeverity(level slog.Level) string {
+	switch {
+	case level < slog.LevelInfo:
+		return "DEBUG"
+	case level < slog.LevelWarn:
+		return "INFO"
+	case level < slog.LevelError:
+		return "WARNING"
+	case level < slog.LevelError+4:
+		return "ERROR"
+	default:
+		return "CRITICAL"
+	}
+}
+
+// metadataClient reads the GCE metadata server
+type metadataClient struct {
+	client *http.Client
+}
+
+// get returns the metadata value at path, relative to computeMetadata/v1
+func (md *metadataClient) get(ctx context.Context, path string) (string, error) {
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = "metadata.google.internal"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+host+"/computeMetadata/v1/"+path, http.NoBody)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := md.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata %s: %s", path, resp.Status)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// lastSegment returns the metadata value at path up to its last "/",
+// e.g. the zone of "projects/123/zones/europe-west1-b"
+func (md *metadataClient) lastSegment(ctx context.Context, path string) string {
+	v, _ := md.get(ctx, path)
+	return v[strings.LastIndexByte(v, '/')+1:]
+}
+
+// detectResource detects the monitored resource of Cloud Run, GKE and GCE
+func (md *metadataClient) detectResource(ctx context.Context, projectID string) *CloudResource {
+	switch {
+	case os.Getenv("K_SERVICE") != "":
+		return &CloudResource{Type: "cloud_run_revision", Labels: map[string]string{
+			"project_id":         projectID,
+			"service_name":       os.Getenv("K_SERVICE"),
+			"revision_name":      os.Getenv("K_REVISION"),
+			"configuration_name": os.Getenv("K_CONFIGURATION"),
+			"location":           md.lastSegment(ctx, "instance/region"),
+		}}
+	case os.Getenv("KUBERNETES_SERVICE_HOST") != "":
+		clusterName, _ := md.get(ctx, "instance/attributes/cluster-name")
+		location, _ := md.get(ctx, "instance/attributes/cluster-location")
+		return &CloudResource{Type: "k8s_container", Labels: map[string]string{
+			"project_id":     projectID,
+			"location":       location,
+			"cluster_name":   clusterName,
+			"namespace_name": podNamespace(),
+			"pod_name":       firstEnv("POD_NAME", "HOSTNAME"),
+			"container_name": os.Getenv("CONTAINER_NAME"),
+		}}
+	}
+
+	if instanceID, err := md.get(ctx, "instance/id"); err == nil {
+		return &CloudResource{Type: "gce_instance", Labels: map[string]string{
+			"project_id":  projectID,
+			"instance_id": instanceID,
+			"zone":        md.lastSegment(ctx, "instance/zone"),
+		}}
+	}
+	return &CloudResource{Type: "global", Labels: map[string]string{"project_id": projectID}}
+}
+
+// podNamespace returns the Kubernetes namespace of the pod
+func podNamespace() string {
+	if ns := firstEnv("POD_NAMESPACE", "NAMESPACE"); ns != "" {
+		return ns
+	}
+	ns, _ := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace")
+	return strings.TrimSpace(string(ns))
+}
+
+// firstEnv returns the first non-empty environment variable of names
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// metadataTokenTransport authorizes requests with access tokens of the
+// default service account, refreshed a minute before they expire
+type metadataTokenTransport struct {
+	md   *metadataClient
+	base http.RoundTripper
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// RoundTrip sends req with an Authorization header
+func (t *metadataTokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.accessToken(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return t.base.RoundTrip(req)
+}
+
+func (t *metadataTokenTransport) accessToken(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token != "" && time.Now().Before(t.expiry) {
+		return t.token, nil
+	}
+
+	body, err := t.md.get(ctx, "instance/service-accounts/default/token")
+	if err != nil {
+		return "", err
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal([]byte(body), &token); err != nil {
+		return "", err
+	}
+	if token.AccessToken == "" {
+		return "", errors.New("metadata: empty access token")
+	}
+	t.token = token.AccessToken
+	t.expiry = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return t.token, nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/AlonMell/grovelog"
+	"github.com/AlonMell/grovelog/decode"
+)
+
+// decoders are the input formats by name
+var decoders = map[string]func([]byte) (decode.Record, error){
+	"json":   decode.JSON,
+	"logfmt": decode.Logfmt,
+	"plain":  decode.Logfmt,
+	"auto":   decode.Line,
+}
+
+// ecsVersion is the Elastic Common Schema version of ECS output
+const ecsVersion = "8.11.0"
+
+// outputOptions returns the options of the output formats by name
+func outputOptions(name string) (grovelog.Options, error) {
+	opts := grovelog.NewOptions(slog.LevelDebug, "[15:04:05.000]", grovelog.JSON)
+	switch name {
+	case "json":
+	case "logfmt", "plain":
+		opts.Format = grovelog.Plain
+	case "color":
+		// Asked for explicitly, and stdout is buffered, so it never looks like a terminal
+		opts.Format = grovelog.Color
+		opts.ColorMode = grovelog.ColorAlways
+		opts.Layout = grovelog.LayoutKeyValue
+	case "ecs":
+		opts.TimeKey, opts.LevelKey, opts.MessageKey = "@timestamp", "log.level", "message"
+	default:
+		return opts, fmt.Errorf("unknown output format %q", name)
+	}
+	return opts, nil
+}
+
+// convert re-encodes the records of stdin in another format
+func convert(args []string) error {
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	from := fs.String("from", "auto", "input format: json, logfmt, plain or auto")
+	to := fs.String("to", "logfmt", "output format: json, logfmt, plain, ecs or color")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	decoder, ok := decoders[*from]
+	if !ok {
+		return fmt.Errorf("unknown input format %q", *from)
+	}
+
+	opts, err := outputOptions(*to)
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(os.Stdout)
+	h := grovelog.NewHandler(out, opts)
+	if *to == "ecs" {
+		h = h.WithAttrs([]slog.Attr{slog.String("ecs.version", ecsVersion)})
+	}
+
+	if err := convertLines(os.Stdin, decoder, h); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// convertLines handles the records of in, failing on lines that
+// aren't records
+func convertLines(in io.Reader, decoder func([]byte) (decode.Record, error), h slog.Handler) error {
+	dec := decode.NewDecoder(in, decoder)
+	for line := 1; ; line++ {
+		rec, _, err := dec.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := h.Handle(context.Background(), rec.Slog()); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/AlonMell/grovelog"
+)
+
+// keyEnv is the environment variable holding the hex encoded key
+const keyEnv = "GROVELOG_KEY"
+
+// decrypt copies the decrypted records of stdin to stdout
+func decrypt(args []string) error {
+	fs := flag.NewFlagSet("decrypt", flag.ContinueOnError)
+	keyFile := fs.String("key-file", "", "file with the hex encoded AES key, defaults to $"+keyEnv)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	hexKey := os.Getenv(keyEnv)
+	if *keyFile != "" {
+		data, err := os.ReadFile(*keyFile)
+		if err != nil {
+			return err
+		}
+		hexKey = string(data)
+	}
+	if hexKey == "" {
+		return errors.New("no key, use -key-file or " + keyEnv)
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(hexKey))
+	if err != nil {
+		return err
+	}
+
+	r, err := grovelog.NewDecryptReader(os.Stdin, key)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(os.Stdout, r)
+	return err
+}
@@ -0,0 +1,34 @@
+// Command grovelog works with grovelog output:
+//
+//	kubectl logs app | grovelog pretty -level warn
+//	grovelog tail -f -level warn -where user_id=42 app.log
+//	grovelog convert -from json -to ecs < archive.log
+//	grovelog decrypt -key-file key < app.log.enc
+//
+// The key file holds the hex encoded AES key used by grovelog.EncryptWriter,
+// or the GROVELOG_KEY environment variable if no file is given
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// commands are the subcommands by name
+var commands = map[string]func(args []string) error{
+	"convert": convert,
+	"decrypt": decrypt,
+	"pretty":  pretty,
+	"tail":    tail,
+}
+
+func main() {
+	if len(os.Args) < 2 || commands[os.Args[1]] == nil {
+		fmt.Fprintln(os.Stderr, "usage: grovelog <command> [flags]\n\ncommands: convert, decrypt, pretty, tail")
+		os.Exit(2)
+	}
+	if err := commands[os.Args[1]](os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, "grovelog:", err)
+		os.Exit(1)
+	}
+}
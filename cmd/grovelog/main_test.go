@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AlonMell/grovelog"
+	"github.com/AlonMell/grovelog/decode"
+)
+
+// TestLastLinesOffset tests finding the last lines of a file from its end
+func TestLastLinesOffset(t *testing.T) {
+	data := "one\ntwo\nthree\n\n"
+	for _, tc := range []struct {
+		n    int
+		want string
+	}{
+		{n: 2, want: "two\nthree\n\n"},
+		{n: 3, want: data},
+		{n: 10, want: data},
+		{n: 0, want: ""},
+		{n: -1, want: data},
+	} {
+		offset, err := lastLinesOffset(strings.NewReader(data), int64(len(data)), tc.n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := data[offset:]; got != tc.want {
+			t.Errorf("-n %d: expected %q, got %q", tc.n, tc.want, got)
+		}
+	}
+
+	// Lines spanning several chunks
+	long := strings.Repeat("x", 40<<10) + "\n" + strings.Repeat("y", 40<<10) + "\n"
+	offset, err := lastLinesOffset(strings.NewReader(long), int64(len(long)), 1)
+	if err != nil || offset != 40<<10+1 {
+		t.Errorf("Expected the offset of the last long line, got %d, %v", offset, err)
+	}
+}
+
+// TestWhereFlag tests parsing and matching -where conditions
+func TestWhereFlag(t *testing.T) {
+	var where whereFlag
+	for _, cond := range []string{"user.id=42", "path~^/api/"} {
+		if err := where.Set(cond); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, cond := range []string{"user", "=42", "path~("} {
+		if err := (&whereFlag{}).Set(cond); err == nil {
+			t.Errorf("Expected an error for %q", cond)
+		}
+	}
+
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "served", 0)
+	r.AddAttrs(slog.Group("user", slog.Int("id", 42)), slog.String("path", "/api/users"))
+	if !where.match(r) {
+		t.Error("Expected the record to match")
+	}
+	r = slog.NewRecord(time.Now(), slog.LevelInfo, "served", 0)
+	r.AddAttrs(slog.Group("user", slog.Int("id", 42)), slog.String("path", "/health"))
+	if where.match(r) {
+		t.Error("Expected the record not to match the path")
+	}
+}
+
+// TestParseInterspersed tests flags given after the file name
+func TestParseInterspersed(t *testing.T) {
+	fs := flag.NewFlagSet("tail", flag.ContinueOnError)
+	follow := fs.Bool("f", false, "")
+	level := fs.String("level", "", "")
+	files, err := parseInterspersed(fs, []string{"app.log", "--level=warn", "-f"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0] != "app.log" || *level != "warn" || !*follow {
+		t.Errorf("Unexpected parse %v, level %q, follow %v", files, *level, *follow)
+	}
+}
+
+// TestFollowReaderRotation tests reading the rotated file to its end
+// before switching to the new one
+func TestFollowReaderRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	w, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if _, err := w.WriteString("one\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(&followReader{path: path, f: f})
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	next := func() string {
+		select {
+		case line := <-lines:
+			return line
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for a line")
+			return ""
+		}
+	}
+	if line := next(); line != "one" {
+		t.Fatalf("Expected one, got %q", line)
+	}
+
+	// Written to the old file after the rename, before the new file exists
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.WriteString("two\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("three\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"two", "three"} {
+		if line := next(); line != want {
+			t.Errorf("Expected %s, got %q", want, line)
+		}
+	}
+}
+
+// TestPrettyLines tests printing records with the Color format, copying
+// other lines and filtering by level
+func TestPrettyLines(t *testing.T) {
+	in := strings.Join([]string{
+		`{"time":"2024-05-06T07:08:09Z","level":"INFO","msg":"started","port":8080}`,
+		`panic: not a record`,
+		`time=2024-05-06T07:08:10Z level=DEBUG msg=noise`,
+		`time=2024-05-06T07:08:11Z level=WARN msg="slow query" ms=250`,
+	}, "\n")
+
+	flags := prettyFlags{level: slog.LevelInfo, layout: "kv"}
+	var out strings.Builder
+	h, err := flags.handler(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := prettyLines(strings.NewReader(in), &out, h, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 lines, got %q", lines)
+	}
+	if !strings.Contains(lines[0], "INFO: started port=8080") || lines[1] != "panic: not a record" ||
+		!strings.Contains(lines[2], "WARN: slow query ms=250") {
+		t.Errorf("Unexpected output %q", lines)
+	}
+	if _, err := (&prettyFlags{layout: "table"}).handler(&out); err == nil {
+		t.Error("Expected an error for an unknown layout")
+	}
+}
+
+// TestConvertLines tests re-encoding records in the output formats
+func TestConvertLines(t *testing.T) {
+	in := `{"time":"2024-05-06T07:08:09Z","level":"WARN","msg":"slow query","ms":250}`
+	for _, tc := range []struct {
+		to   string
+		want []string
+	}{
+		{to: "logfmt", want: []string{"level=WARN", `msg="slow query"`, "ms=250"}},
+		{to: "json", want: []string{`"level":"WARN"`, `"ms":250`}},
+		{to: "ecs", want: []string{`"@timestamp":"2024-05-06T07:08:09Z"`, `"log.level":"WARN"`, `"message":"slow query"`, `"ecs.version"`}},
+		{to: "color", want: []string{"\x1b[", "slow query"}},
+	} {
+		opts, err := outputOptions(tc.to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out strings.Builder
+		h := grovelog.NewHandler(&out, opts)
+		if tc.to == "ecs" {
+			h = h.WithAttrs([]slog.Attr{slog.String("ecs.version", ecsVersion)})
+		}
+		if err := convertLines(strings.NewReader(in), decoders["auto"], h); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("-to %s: expected %q in %q", tc.to, want, out.String())
+			}
+		}
+	}
+
+	if _, err := outputOptions("xml"); err == nil {
+		t.Error("Expected an error for an unknown output format")
+	}
+	h := grovelog.NewHandler(io.Discard, grovelog.NewOptions(slog.LevelDebug, "", grovelog.JSON))
+	if err := convertLines(strings.NewReader(in+"\nnot a record\n"), decode.JSON, h); err == nil || !strings.HasPrefix(err.Error(), "line 2:") {
+		t.Errorf("Expected an error on line 2, got %v", err)
+	}
+}
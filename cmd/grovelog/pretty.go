@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/AlonMell/grovelog"
+	"github.com/AlonMell/grovelog/decode"
+)
+
+// layouts are the values of the -layout flag
+var layouts = map[string]grovelog.Layout{
+	"indented": grovelog.LayoutIndented,
+	"kv":       grovelog.LayoutKeyValue,
+	"json":     grovelog.LayoutJSONLine,
+	"expanded": grovelog.LayoutExpanded,
+}
+
+// prettyFlags are the flags shared by the commands printing records
+type prettyFlags struct {
+	level  slog.Level
+	layout string
+}
+
+func (f *prettyFlags) register(fs *flag.FlagSet) {
+	fs.TextVar(&f.level, "level", slog.LevelDebug, "minimum level of the printed records")
+	fs.StringVar(&f.layout, "layout", "kv", "attribute layout: indented, kv, json or expanded")
+}
+
+// handler returns the Color handler printing records to out
+func (f *prettyFlags) handler(out io.Writer) (slog.Handler, error) {
+	layout, ok := layouts[f.layout]
+	if !ok {
+		return nil, fmt.Errorf("unknown layout %q", f.layout)
+	}
+	opts := grovelog.NewOptions(f.level, "[15:04:05.000]", grovelog.Color)
+	opts.Layout = layout
+	return grovelog.NewHandler(out, opts), nil
+}
+
+// pretty prints the JSON or logfmt records of stdin with the Color format.
+// Lines that aren't records are copied as they are
+func pretty(args []string) error {
+	fs := flag.NewFlagSet("pretty", flag.ContinueOnError)
+	var flags prettyFlags
+	flags.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	h, err := flags.handler(os.Stdout)
+	if err != nil {
+		return err
+	}
+	return prettyLines(os.Stdin, os.Stdout, h, nil)
+}
+
+// prettyLines prints the records of in accepted by keep, or all if keep is nil
+func prettyLines(in io.Reader, out io.Writer, h slog.Handler, keep func(slog.Record) bool) error {
+	ctx := context.Background()
+	dec := decode.NewDecoder(in, decode.Line)
+	for {
+		rec, line, err := dec.Next()
+		switch {
+		case errors.Is(err, io.EOF):
+			return nil
+		case errors.Is(err, decode.ErrNotRecord):
+			if _, err := fmt.Fprintf(out, "%s\n", line); err != nil {
+				return err
+			}
+			continue
+		case err != nil:
+			return err
+		}
+
+		r := rec.Slog()
+		if !h.Enabled(ctx, r.Level) || keep != nil && !keep(r) {
+			continue
+		}
+		if err := h.Handle(ctx, r); err != nil {
+			return err
+		}
+	}
+}
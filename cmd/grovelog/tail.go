@@ -0,0 +1,235 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// pollInterval is how often a followed file is checked for new data
+const pollInterval = 250 * time.Millisecond
+
+// condition is a -where filter on the attribute with the full key
+type condition struct {
+	key   string
+	value string         // Expected value for key=value
+	re    *regexp.Regexp // Pattern for key~regexp
+}
+
+// whereFlag collects the -where conditions
+type whereFlag []condition
+
+// String implements flag.Value
+func (w *whereFlag) String() string {
+	return fmt.Sprint(*w)
+}
+
+// Set implements flag.Value, parsing key=value or key~regexp
+func (w *whereFlag) Set(s string) error {
+	i := strings.IndexAny(s, "=~")
+	if i <= 0 {
+		return fmt.Errorf("invalid condition %q, want key=value or key~regexp", s)
+	}
+	c := condition{key: s[:i], value: s[i+1:]}
+	if s[i] == '~' {
+		re, err := regexp.Compile(c.value)
+		if err != nil {
+			return err
+		}
+		c.re = re
+	}
+	*w = append(*w, c)
+	return nil
+}
+
+// match reports whether the record satisfies all conditions
+func (w whereFlag) match(r slog.Record) bool { //nolint:gocritic
+	values := make(map[string]string)
+	r.Attrs(func(a slog.Attr) bool {
+		flattenAttr(values, "", a)
+		return true
+	})
+	for _, c := range w {
+		v, ok := values[c.key]
+		if !ok || c.re == nil && v != c.value || c.re != nil && !c.re.MatchString(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// flattenAttr adds the leaves of a to values under their dotted keys
+func flattenAttr(values map[string]string, prefix string, a slog.Attr) {
+	key := prefix + a.Key
+	if a.Value.Kind() == slog.KindGroup {
+		for _, ga := range a.Value.Group() {
+			flattenAttr(values, key+".", ga)
+		}
+		return
+	}
+	values[key] = a.Value.String()
+}
+
+// tail prints the records of a file, optionally following it
+func tail(args []string) error {
+	fs := flag.NewFlagSet("tail", flag.ContinueOnError)
+	var (
+		flags  prettyFlags
+		where  whereFlag
+		follow = fs.Bool("f", false, "follow the file, reopening it when rotated")
+		lines  = fs.Int("n", 10, "number of last lines to print first, negative for all")
+	)
+	flags.register(fs)
+	fs.Var(&where, "where", "filter by attribute, key=value or key~regexp, repeatable")
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(files) != 1 {
+		return errors.New("usage: grovelog tail [-f] [-n lines] [-level level] [-where cond] file")
+	}
+
+	path := files[0]
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	follower := &followReader{path: path, f: f}
+	defer func() { _ = follower.f.Close() }()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	offset, err := lastLinesOffset(f, info.Size(), *lines)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	var in io.Reader = f
+	if *follow {
+		in = follower
+	}
+
+	h, err := flags.handler(os.Stdout)
+	if err != nil {
+		return err
+	}
+	return prettyLines(in, os.Stdout, h, where.match)
+}
+
+// parseInterspersed parses args with fs, accepting flags after the
+// positional arguments like "tail app.log -level warn", and returns the
+// positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// lastLinesOffset returns the offset of the last n lines of the first size
+// bytes of r, read backwards from the end, 0 if n is negative. Trailing
+// newlines don't count as lines
+func lastLinesOffset(r io.ReaderAt, size int64, n int) (int64, error) {
+	switch {
+	case n < 0:
+		return 0, nil
+	case n == 0:
+		return size, nil
+	}
+
+	buf := make([]byte, 32<<10)
+	trailing := true
+	for end := size; end > 0; {
+		start := max(0, end-int64(len(buf)))
+		chunk := buf[:end-start]
+		if _, err := r.ReadAt(chunk, start); err != nil {
+			return 0, err
+		}
+		for i := len(chunk) - 1; i >= 0; i-- {
+			if chunk[i] != '\n' {
+				trailing = false
+				continue
+			}
+			if trailing {
+				continue
+			}
+			if n--; n == 0 {
+				return start + int64(i) + 1, nil
+			}
+		}
+		end = start
+	}
+	return 0, nil
+}
+
+// followReader reads a growing file and never returns io.EOF. It reopens
+// the path when the file is replaced by rotation, once the old file is
+// read to its end, or rewinds it when it is truncated
+type followReader struct {
+	path string
+	f    *os.File
+	next *os.File // The file now at path, read once f is drained
+}
+
+// Read reads from the file, waiting for data at its end
+func (r *followReader) Read(p []byte) (int, error) {
+	for {
+		n, err := r.f.Read(p)
+		if n > 0 || err != nil && !errors.Is(err, io.EOF) {
+			return n, err
+		}
+		if r.next != nil {
+			_ = r.f.Close()
+			r.f, r.next = r.next, nil
+			continue
+		}
+		time.Sleep(pollInterval)
+		if err := r.reopenIfRotated(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// reopenIfRotated opens the path as the next file if it is another file
+// now, or rewinds the file if it was truncated
+func (r *followReader) reopenIfRotated() error {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return nil //nolint:nilerr // Rotated away, the new file isn't created yet
+	}
+	current, err := r.f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if !os.SameFile(info, current) {
+		f, err := os.Open(r.path)
+		if err != nil {
+			return nil //nolint:nilerr // Retried on the next poll
+		}
+		r.next = f
+		return nil
+	}
+	if offset, err := r.f.Seek(0, io.SeekCurrent); err == nil && info.Size() < offset {
+		_, err = r.f.Seek(0, io.SeekStart)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package grovelog
+
+import (
+	"io"
+	"os"
+)
+
+// ColorMode controls whether the Color format emits ANSI codes
+type ColorMode int
+
+const (
+	// ColorAuto enables colors following the NO_COLOR and FORCE_COLOR
+	// conventions and the terminal detection of the writer
+	ColorAuto ColorMode = iota
+	// ColorAlways enables colors regardless of the writer and environment
+	ColorAlways
+	// ColorNever disables colors regardless of the writer and environment
+	ColorNever
+)
+
+// colorEnabled reports whether the Color format should emit ANSI codes to out.
+// An explicit mode wins over the environment. In ColorAuto mode a non-empty
+// NO_COLOR disables colors and a non-empty FORCE_COLOR enables them even when
+// out isn't a terminal; NO_COLOR takes precedence. Writers without a file
+// descriptor, like buffers, aren't terminals
+func colorEnabled(out io.Writer, mode ColorMode) bool {
+	switch mode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	if os.Getenv("FORCE_COLOR") != "" {
+		return true
+	}
+	terminal, _ := isTerminal(out)
+	return terminal
+}
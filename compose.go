@@ -0,0 +1,93 @@
+package grovelog
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+)
+
+// Middleware wraps a handler, e.g. to transform, filter or route records
+type Middleware func(next slog.Handler) slog.Handler
+
+// Pipe composes middlewares into one. Records pass the middlewares in the
+// given order, so the first one is the outermost:
+//
+//	h := grovelog.Pipe(redact, sample)(grovelog.NewHandler(os.Stdout, opts))
+func Pipe(middlewares ...Middleware) Middleware {
+	return func(next slog.Handler) slog.Handler {
+		for _, mw := range slices.Backward(middlewares) {
+			next = mw(next)
+		}
+		return next
+	}
+}
+
+// Chain wraps h with middlewares, like Pipe(middlewares...)(h)
+func Chain(h slog.Handler, middlewares ...Middleware) slog.Handler {
+	return Pipe(middlewares...)(h)
+}
+
+// Fanout returns a handler passing every record to all handlers enabled
+// for its level. Errors of the handlers are joined
+func Fanout(handlers ...slog.Handler) slog.Handler {
+	return &fanoutHandler{handlers: slices.Clone(handlers)}
+}
+
+// fanoutHandler passes records to several handlers
+type fanoutHandler struct {
+	handlers []slog.Handler
+}
+
+// Enabled reports whether any handler handles level
+func (h *fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, next := range h.handlers {
+		if next.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handle passes a copy of the record to every enabled handler
+func (h *fanoutHandler) Handle(ctx context.Context, r slog.Record) error { //nolint:gocritic
+	var errs []error
+	for _, next := range h.handlers {
+		if next.Enabled(ctx, r.Level) {
+			if err := next.Handle(ctx, r.Clone()); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WithAttrs returns a fanoutHandler of the handlers with attrs
+func (h *fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithAttrs(attrs) })
+}
+
+// WithGroup returns a fanoutHandler of the grouped handlers
+func (h *fanoutHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithGroup(name) })
+}
+
+// with returns a fanoutHandler of the handlers transformed by op
+func (h *fanoutHandler) with(op func(slog.Handler) slog.Handler) *fanoutHandler {
+	handlers := make([]slog.Handler, len(h.handlers))
+	for i, next := range h.handlers {
+		handlers[i] = op(next)
+	}
+	return &fanoutHandler{handlers: handlers}
+}
+
+// Drain drains all handlers
+func (h *fanoutHandler) Drain(ctx context.Context) error {
+	var errs []error
+	for _, next := range h.handlers {
+		if err := drain(ctx, next); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,120 @@
+package grovelog
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// maxEncryptedRecord bounds the frame size accepted by DecryptReader
+const maxEncryptedRecord = 64 << 20
+
+// ErrCorruptRecord is returned by DecryptReader for frames that fail to decrypt
+var ErrCorruptRecord = errors.New("grovelog: corrupt encrypted record")
+
+// EncryptWriter encrypts every record with AES-GCM before writing it to
+// the wrapped writer, for logs that must be unreadable at rest. Records
+// are framed as a 4 byte big endian length followed by the random nonce
+// and the sealed record; DecryptReader reverses it
+type EncryptWriter struct {
+	out  io.Writer
+	aead cipher.AEAD
+
+	mu sync.Mutex
+}
+
+// NewEncryptWriter returns an EncryptWriter writing to out, key is an
+// AES-128, AES-192 or AES-256 key of 16, 24 or 32 bytes
+func NewEncryptWriter(out io.Writer, key []byte) (*EncryptWriter, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &EncryptWriter{out: out, aead: aead}, nil
+}
+
+// Write encrypts the record p and writes it as one frame
+func (w *EncryptWriter) Write(p []byte) (int, error) {
+	size := w.aead.NonceSize() + len(p) + w.aead.Overhead()
+	frame := make([]byte, 4+w.aead.NonceSize(), 4+size)
+	binary.BigEndian.PutUint32(frame, uint32(size)) //nolint:gosec
+	if _, err := rand.Read(frame[4:]); err != nil {
+		return 0, err
+	}
+	frame = w.aead.Seal(frame, frame[4:], p, nil)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.out.Write(frame); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// DecryptReader reads the plain records of a stream written by EncryptWriter
+type DecryptReader struct {
+	in   *bufio.Reader
+	aead cipher.AEAD
+	buf  []byte // Decrypted bytes not read yet
+}
+
+// NewDecryptReader returns a DecryptReader reading frames from in
+func NewDecryptReader(in io.Reader, key []byte) (*DecryptReader, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &DecryptReader{in: bufio.NewReader(in), aead: aead}, nil
+}
+
+// Read reads decrypted records, it fails with ErrCorruptRecord
+// for frames that were tampered with or use another key
+func (r *DecryptReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		record, err := r.next()
+		if err != nil {
+			return 0, err
+		}
+		r.buf = record
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// next reads and decrypts the next frame
+func (r *DecryptReader) next() ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r.in, header[:]); err != nil {
+		return nil, err // io.EOF at a frame boundary
+	}
+	size := int(binary.BigEndian.Uint32(header[:]))
+	if size < r.aead.NonceSize()+r.aead.Overhead() || size > maxEncryptedRecord {
+		return nil, ErrCorruptRecord
+	}
+
+	frame := make([]byte, size)
+	if _, err := io.ReadFull(r.in, frame); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCorruptRecord, err)
+	}
+	nonce, sealed := frame[:r.aead.NonceSize()], frame[r.aead.NonceSize():]
+	record, err := r.aead.Open(sealed[:0], nonce, sealed, nil)
+	if err != nil {
+		return nil, ErrCorruptRecord
+	}
+	return record, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
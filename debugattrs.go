@@ -0,0 +1,58 @@
+package grovelog
+
+import (
+	"context"
+	"log/slog"
+)
+
+// WithDebugAttrs returns a Logger adding the attributes, given like for
+// With, to every record while the handler is enabled for Debug. Production
+// records at Info stay lean while development records carry the verbose
+// context. The attributes aren't resolved unless Debug is enabled
+func (l *Logger) WithDebugAttrs(args ...any) *Logger {
+	attrs := slog.Group("", args...).Value.Group()
+	if len(attrs) == 0 {
+		return l
+	}
+	h := &debugAttrsHandler{
+		derived: newDerived(l.Handler()),
+		attrs:   attrs,
+	}
+	return &Logger{Logger: slog.New(h)}
+}
+
+// debugAttrsHandler adds attributes to records, at the level of base,
+// while the handler is enabled for Debug. Like lazyHandler, it derives
+// its handlers once and adds the attributes to the records
+type debugAttrsHandler struct {
+	derived
+	attrs []slog.Attr
+}
+
+// Enabled reports whether the wrapped handler handles level
+func (h *debugAttrsHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle passes the record on, with the attributes if Debug is enabled
+func (h *debugAttrsHandler) Handle(ctx context.Context, r slog.Record) error { //nolint:gocritic
+	if !h.next.Enabled(ctx, slog.LevelDebug) {
+		return h.next.Handle(ctx, r)
+	}
+	return h.handle(ctx, r, h.attrs...)
+}
+
+// WithAttrs returns a debugAttrsHandler adding the attributes before attrs
+func (h *debugAttrsHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &debugAttrsHandler{derived: h.withAttrs(attrs), attrs: h.attrs}
+}
+
+// WithGroup returns a debugAttrsHandler adding the attributes outside the group
+func (h *debugAttrsHandler) WithGroup(name string) slog.Handler {
+	return &debugAttrsHandler{derived: h.withGroup(name), attrs: h.attrs}
+}
+
+// Drain drains the wrapped handler
+func (h *debugAttrsHandler) Drain(ctx context.Context) error {
+	return drain(ctx, h.next)
+}
@@ -0,0 +1,94 @@
+// Package decode parses records written by the grovelog JSON, Plain,
+// MsgPack and Protobuf formats, or other logfmt and JSON lines, back into
+// structured records for tooling such as the grovelog command, tests and replays
+package decode
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// ErrNotRecord is returned for lines that aren't records
+var ErrNotRecord = errors.New("decode: not a record")
+
+// Record is a decoded record
+type Record struct {
+	Time    time.Time
+	Level   slog.Level
+	Message string
+	// Attrs are the remaining attributes in their original order,
+	// nested JSON objects and dotted logfmt keys become groups
+	Attrs []slog.Attr
+}
+
+// Slog returns r as a slog.Record, e.g. to pass it to a handler
+func (r *Record) Slog() slog.Record {
+	sr := slog.NewRecord(r.Time, r.Level, r.Message, 0)
+	sr.AddAttrs(r.Attrs...)
+	return sr
+}
+
+// Line decodes a JSON line if it starts with '{' and a logfmt line otherwise
+func Line(line []byte) (Record, error) {
+	if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 && trimmed[0] == '{' {
+		return JSON(trimmed)
+	}
+	return Logfmt(line)
+}
+
+// Decoder reads records line by line, or message by message for the binary formats
+type Decoder struct {
+	sc     *bufio.Scanner
+	decode func([]byte) (Record, error)
+}
+
+// NewDecoder returns a Decoder reading from in with decode,
+// e.g. Line, JSON or Logfmt
+func NewDecoder(in io.Reader, decode func([]byte) (Record, error)) *Decoder {
+	sc := bufio.NewScanner(in)
+	sc.Buffer(nil, 16<<20)
+	return &Decoder{sc: sc, decode: decode}
+}
+
+// Next returns the next record and the raw line. The error wraps
+// ErrNotRecord for lines that aren't records, decoding can continue
+// after it. It is io.EOF at the end of the input
+func (d *Decoder) Next() (Record, []byte, error) {
+	if !d.sc.Scan() {
+		if err := d.sc.Err(); err != nil {
+			return Record{}, nil, err
+		}
+		return Record{}, nil, io.EOF
+	}
+	r, err := d.decode(d.sc.Bytes())
+	return r, d.sc.Bytes(), err
+}
+
+// builtin stores a built-in attribute in r and reports whether a is one
+func (r *Record) builtin(a slog.Attr) bool { //nolint:gocritic
+	switch a.Key {
+	case slog.TimeKey:
+		if a.Value.Kind() == slog.KindTime {
+			r.Time = a.Value.Time()
+			break
+		}
+		t, err := time.Parse(time.RFC3339Nano, a.Value.String())
+		if err != nil {
+			return false
+		}
+		r.Time = t
+	case slog.LevelKey:
+		if err := r.Level.UnmarshalText([]byte(a.Value.String())); err != nil {
+			return false
+		}
+	case slog.MessageKey:
+		r.Message = a.Value.String()
+	default:
+		return false
+	}
+	return true
+}
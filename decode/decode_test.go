@@ -0,0 +1,54 @@
+package decode_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/AlonMell/grovelog"
+	"github.com/AlonMell/grovelog/decode"
+)
+
+// TestDecode tests decoding the JSON and Plain formats back into records
+func TestDecode(t *testing.T) {
+	for _, format := range []grovelog.Format{grovelog.JSON, grovelog.Plain} {
+		var buf bytes.Buffer
+		opts := grovelog.NewOptions(slog.LevelInfo, "", format)
+		grovelog.NewLogger(&buf, opts).WithGroup("req").Warn("slow request",
+			"id", "a b", "attempt", 2, "ratio", 0.5, "cached", false)
+		buf.WriteString("not a record\n")
+
+		dec := decode.NewDecoder(&buf, decode.Line)
+		rec, _, err := dec.Next()
+		if err != nil {
+			t.Fatalf("Format %v: %v", format, err)
+		}
+		if rec.Level != slog.LevelWarn || rec.Message != "slow request" || rec.Time.IsZero() {
+			t.Errorf("Format %v: unexpected built-in fields %+v", format, rec)
+		}
+		if len(rec.Attrs) != 1 || rec.Attrs[0].Key != "req" {
+			t.Fatalf("Format %v: expected the req group, got %v", format, rec.Attrs)
+		}
+		expected := []slog.Attr{
+			slog.String("id", "a b"), slog.Int64("attempt", 2), slog.Float64("ratio", 0.5), slog.Bool("cached", false),
+		}
+		group := rec.Attrs[0].Value.Group()
+		if len(group) != len(expected) {
+			t.Fatalf("Format %v: unexpected group %v", format, group)
+		}
+		for i, a := range expected {
+			if !group[i].Equal(a) {
+				t.Errorf("Format %v: expected %v, got %v", format, a, group[i])
+			}
+		}
+
+		if _, line, err := dec.Next(); !errors.Is(err, decode.ErrNotRecord) || string(line) != "not a record" {
+			t.Errorf("Format %v: expected ErrNotRecord for %q, got %v", format, line, err)
+		}
+		if _, _, err := dec.Next(); !errors.Is(err, io.EOF) {
+			t.Errorf("Format %v: expected io.EOF, got %v", format, err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package decode
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+)
+
+// JSON decodes a JSON line like the ones of the JSON format, keeping the
+// attribute order and turning nested objects into groups
+func JSON(line []byte) (Record, error) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return Record{}, ErrNotRecord
+	}
+	attrs, err := parseObject(dec)
+	if err != nil {
+		return Record{}, fmt.Errorf("%w: %w", ErrNotRecord, err)
+	}
+
+	var r Record
+	for _, a := range attrs {
+		if !r.builtin(a) {
+			r.Attrs = append(r.Attrs, a)
+		}
+	}
+	return r, nil
+}
+
+// parseObject parses the members of a JSON object after its opening brace
+func parseObject(dec *json.Decoder) ([]slog.Attr, error) {
+	var attrs []slog.Attr
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+		value, err := parseValue(dec)
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, slog.Attr{Key: key, Value: value})
+	}
+	_, err := dec.Token() // Closing brace
+	return attrs, err
+}
+
+// parseValue parses the next JSON value, objects become groups
+func parseValue(dec *json.Decoder) (slog.Value, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return slog.Value{}, err
+	}
+
+	switch x := tok.(type) {
+	case json.Delim:
+		if x == '{' {
+			attrs, err := parseObject(dec)
+			return slog.GroupValue(attrs...), err
+		}
+		var arr []any
+		for dec.More() {
+			v, err := parseValue(dec)
+			if err != nil {
+				return slog.Value{}, err
+			}
+			arr = append(arr, v.Any())
+		}
+		_, err := dec.Token() // Closing bracket
+		return slog.AnyValue(arr), err
+	case json.Number:
+		if n, err := x.Int64(); err == nil {
+			return slog.Int64Value(n), nil
+		}
+		f, err := x.Float64()
+		return slog.Float64Value(f), err
+	case nil:
+		return slog.AnyValue(nil), nil
+	default:
+		return slog.AnyValue(x), nil
+	}
+}
@@ -0,0 +1,94 @@
+package decode
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// Logfmt decodes a line of space separated key=value pairs, like the ones
+// of the Plain format. Quoted values are unquoted, numbers and booleans
+// are typed and dotted keys become groups
+func Logfmt(line []byte) (Record, error) {
+	s := strings.TrimSpace(string(line))
+	var flat []slog.Attr
+	for s != "" {
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 || strings.ContainsAny(s[:eq], " \"") {
+			return Record{}, ErrNotRecord
+		}
+		key := s[:eq]
+		s = s[eq+1:]
+
+		var raw string
+		if strings.HasPrefix(s, `"`) {
+			quoted, err := strconv.QuotedPrefix(s)
+			if err != nil {
+				return Record{}, fmt.Errorf("%w: %w", ErrNotRecord, err)
+			}
+			raw, _ = strconv.Unquote(quoted)
+			s = s[len(quoted):]
+			flat = append(flat, slog.String(key, raw))
+		} else {
+			end := strings.IndexByte(s, ' ')
+			if end < 0 {
+				end = len(s)
+			}
+			raw, s = s[:end], s[end:]
+			flat = append(flat, slog.Attr{Key: key, Value: typedValue(raw)})
+		}
+		s = strings.TrimLeft(s, " ")
+	}
+	if len(flat) == 0 {
+		return Record{}, ErrNotRecord
+	}
+
+	var r Record
+	var attrs []slog.Attr
+	for _, a := range flat {
+		if !r.builtin(a) {
+			attrs = append(attrs, a)
+		}
+	}
+	r.Attrs = nest(attrs)
+	return r, nil
+}
+
+// typedValue returns raw as an integer, float or boolean value if it is one
+func typedValue(raw string) slog.Value {
+	if n, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return slog.Int64Value(n)
+	}
+	if f, err := strconv.ParseFloat(raw, 64); err == nil {
+		return slog.Float64Value(f)
+	}
+	if b, err := strconv.ParseBool(raw); err == nil && (raw == "true" || raw == "false") {
+		return slog.BoolValue(b)
+	}
+	return slog.StringValue(raw)
+}
+
+// nest turns attributes with dotted keys into groups, in the order
+// of the first appearance of every group
+func nest(attrs []slog.Attr) []slog.Attr {
+	var out []slog.Attr
+	index := make(map[string]int)
+	members := make(map[string][]slog.Attr)
+	for _, a := range attrs {
+		group, rest, ok := strings.Cut(a.Key, ".")
+		if !ok || group == "" || rest == "" {
+			out = append(out, a)
+			continue
+		}
+		if _, seen := index[group]; !seen {
+			index[group] = len(out)
+			out = append(out, slog.Attr{Key: group})
+		}
+		members[group] = append(members[group], slog.Attr{Key: rest, Value: a.Value})
+	}
+	for group, i := range index {
+		out[i].Value = slog.GroupValue(nest(members[group])...)
+	}
+	return out
+}
@@ -0,0 +1,253 @@
+package decode
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// MsgPack decodes a record written by the MsgPack format, or another
+// MessagePack map, keeping the entry order and turning nested maps into groups
+func MsgPack(data []byte) (Record, error) {
+	d := msgpackReader{b: data}
+	v, err := d.value()
+	if err != nil {
+		return Record{}, fmt.Errorf("%w: %w", ErrNotRecord, err)
+	}
+	if v.Kind() != slog.KindGroup || d.off != len(data) {
+		return Record{}, ErrNotRecord
+	}
+
+	var r Record
+	for _, a := range v.Group() {
+		if !r.builtin(a) {
+			r.Attrs = append(r.Attrs, a)
+		}
+	}
+	return r, nil
+}
+
+// NewMsgPackDecoder returns a Decoder reading the records of the MsgPack
+// format from in. The raw bytes returned by Next hold one encoded record
+func NewMsgPackDecoder(in io.Reader) *Decoder {
+	d := NewDecoder(in, MsgPack)
+	d.sc.Split(splitMsgPack)
+	return d
+}
+
+// splitMsgPack is a bufio.SplitFunc returning one MessagePack value per token
+func splitMsgPack(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	d := msgpackReader{b: data}
+	if _, err := d.value(); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) && !atEOF {
+			return 0, nil, nil // Request more data
+		}
+		return 0, nil, err
+	}
+	return d.off, data[:d.off], nil
+}
+
+// maxMsgPackDepth limits the nesting of arrays and maps, like encoding/json does
+const maxMsgPackDepth = 10000
+
+// msgpackReader decodes MessagePack values from b
+type msgpackReader struct {
+	b     []byte
+	off   int
+	depth int
+}
+
+// next consumes n bytes
+func (d *msgpackReader) next(n int) ([]byte, error) {
+	if n < 0 || len(d.b)-d.off < n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	p := d.b[d.off : d.off+n]
+	d.off += n
+	return p, nil
+}
+
+// uint consumes a big endian unsigned integer of n bytes
+func (d *msgpackReader) uint(n int) (uint64, error) {
+	p, err := d.next(n)
+	if err != nil {
+		return 0, err
+	}
+	var u uint64
+	for _, c := range p {
+		u = u<<8 | uint64(c)
+	}
+	return u, nil
+}
+
+// length consumes a length of n bytes
+func (d *msgpackReader) length(n int) (int, error) {
+	u, err := d.uint(n)
+	if err != nil {
+		return 0, err
+	}
+	if u > uint64(len(d.b)) {
+		return 0, io.ErrUnexpectedEOF // Can't fit in the remaining data
+	}
+	return int(u), nil
+}
+
+// value decodes the next value. Maps become groups, arrays []any,
+// positive integers int64 unless they overflow it, and timestamps times
+func (d *msgpackReader) value() (slog.Value, error) { //nolint:cyclop,funlen
+	p, err := d.next(1)
+	if err != nil {
+		return slog.Value{}, err
+	}
+	c := p[0]
+
+	switch {
+	case c <= 0x7f:
+		return slog.Int64Value(int64(c)), nil
+	case c >= 0xe0:
+		return slog.Int64Value(int64(int8(c))), nil //nolint:gosec
+	case c&0xf0 == 0x80:
+		return d.group(int(c & 0x0f))
+	case c&0xf0 == 0x90:
+		return d.array(int(c & 0x0f))
+	case c&0xe0 == 0xa0:
+		return d.str(int(c & 0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return slog.AnyValue(nil), nil
+	case 0xc2, 0xc3:
+		return slog.BoolValue(c == 0xc3), nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.length(1 << (c - 0xc4))
+		if err != nil {
+			return slog.Value{}, err
+		}
+		b, err := d.next(n)
+		return slog.AnyValue(append([]byte(nil), b...)), err
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.length(1 << (c - 0xc7))
+		if err != nil {
+			return slog.Value{}, err
+		}
+		return d.ext(n)
+	case 0xca:
+		u, err := d.uint(4)
+		return slog.Float64Value(float64(math.Float32frombits(uint32(u)))), err //nolint:gosec
+	case 0xcb:
+		u, err := d.uint(8)
+		return slog.Float64Value(math.Float64frombits(u)), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := d.uint(1 << (c - 0xcc))
+		if u <= math.MaxInt64 {
+			return slog.Int64Value(int64(u)), err
+		}
+		return slog.Uint64Value(u), err
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		u, err := d.uint(size)
+		shift := 64 - 8*size
+		return slog.Int64Value(int64(u<<shift) >> shift), err //nolint:gosec
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.ext(1 << (c - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.length(1 << (c - 0xd9))
+		if err != nil {
+			return slog.Value{}, err
+		}
+		return d.str(n)
+	case 0xdc, 0xdd:
+		n, err := d.length(2 << (c - 0xdc))
+		if err != nil {
+			return slog.Value{}, err
+		}
+		return d.array(n)
+	case 0xde, 0xdf:
+		n, err := d.length(2 << (c - 0xde))
+		if err != nil {
+			return slog.Value{}, err
+		}
+		return d.group(n)
+	}
+	return slog.Value{}, fmt.Errorf("invalid MessagePack type %#x", c)
+}
+
+func (d *msgpackReader) str(n int) (slog.Value, error) {
+	b, err := d.next(n)
+	return slog.StringValue(string(b)), err
+}
+
+func (d *msgpackReader) array(n int) (slog.Value, error) {
+	if d.depth++; d.depth > maxMsgPackDepth {
+		return slog.Value{}, errors.New("MessagePack nesting too deep")
+	}
+	defer func() { d.depth-- }()
+	arr := make([]any, 0, min(n, len(d.b)-d.off))
+	for range n {
+		v, err := d.value()
+		if err != nil {
+			return slog.Value{}, err
+		}
+		arr = append(arr, v.Any())
+	}
+	return slog.AnyValue(arr), nil
+}
+
+func (d *msgpackReader) group(n int) (slog.Value, error) {
+	if d.depth++; d.depth > maxMsgPackDepth {
+		return slog.Value{}, errors.New("MessagePack nesting too deep")
+	}
+	defer func() { d.depth-- }()
+	attrs := make([]slog.Attr, 0, min(n, len(d.b)-d.off))
+	for range n {
+		key, err := d.value()
+		if err != nil {
+			return slog.Value{}, err
+		}
+		if key.Kind() != slog.KindString {
+			return slog.Value{}, fmt.Errorf("non-string MessagePack key %v", key)
+		}
+		value, err := d.value()
+		if err != nil {
+			return slog.Value{}, err
+		}
+		attrs = append(attrs, slog.Attr{Key: key.String(), Value: value})
+	}
+	return slog.GroupValue(attrs...), nil
+}
+
+// ext decodes an extension value with n data bytes. Timestamps (type -1)
+// become times, other extensions their raw data
+func (d *msgpackReader) ext(n int) (slog.Value, error) {
+	typ, err := d.next(1)
+	if err != nil {
+		return slog.Value{}, err
+	}
+	data, err := d.next(n)
+	if err != nil || int8(typ[0]) != -1 { //nolint:gosec
+		return slog.AnyValue(append([]byte(nil), data...)), err
+	}
+
+	var sec, nsec int64
+	switch n {
+	case 4:
+		sec = int64(binary.BigEndian.Uint32(data))
+	case 8:
+		u := binary.BigEndian.Uint64(data)
+		sec, nsec = int64(u&(1<<34-1)), int64(u>>34) //nolint:gosec
+	case 12:
+		nsec = int64(binary.BigEndian.Uint32(data))
+		sec = int64(binary.BigEndian.Uint64(data[4:])) //nolint:gosec
+	default:
+		return slog.Value{}, fmt.Errorf("invalid MessagePack timestamp length %d", n)
+	}
+	return slog.TimeValue(time.Unix(sec, nsec).UTC()), nil
+}
@@ -0,0 +1,239 @@
+package decode
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// maxVarintLen is the maximum length of a varint
+const maxVarintLen = 10
+
+// Protobuf decodes a Record message of the Protobuf format without its
+// size prefix. The source becomes a group, like in the JSON format, and
+// attributes holding JSON are decoded like JSON values
+func Protobuf(data []byte) (Record, error) {
+	var r Record
+	err := consumeFields(data, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return n, nil
+			}
+			t, err := protoTime(v)
+			r.Time = t
+			return n, err
+		case num == 2 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			r.Level = slog.Level(protowire.DecodeZigZag(v))
+			return n, nil
+		case num == 3 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			r.Message = string(v)
+			return n, nil
+		case num == 4 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return n, nil
+			}
+			src, err := protoSource(v)
+			r.Attrs = append(r.Attrs, src)
+			return n, err
+		case num == 5 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return n, nil
+			}
+			a, err := protoAttr(v)
+			r.Attrs = append(r.Attrs, a)
+			return n, err
+		}
+		return protowire.ConsumeFieldValue(num, typ, b), nil
+	})
+	if err != nil {
+		return Record{}, fmt.Errorf("%w: %w", ErrNotRecord, err)
+	}
+	return r, nil
+}
+
+// NewProtobufDecoder returns a Decoder reading the size prefixed records
+// of the Protobuf format from in. The raw bytes returned by Next hold one
+// Record message without its prefix
+func NewProtobufDecoder(in io.Reader) *Decoder {
+	d := NewDecoder(in, Protobuf)
+	d.sc.Split(splitProtobuf)
+	return d
+}
+
+// splitProtobuf is a bufio.SplitFunc returning one size prefixed message per token
+func splitProtobuf(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	size, n := protowire.ConsumeVarint(data)
+	if n < 0 || uint64(len(data)-n) < size {
+		if !atEOF && (n >= 0 || len(data) < maxVarintLen) {
+			return 0, nil, nil // Request more data
+		}
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+	end := n + int(size) //nolint:gosec
+	return end, data[n:end], nil
+}
+
+// consumeFields calls field for every field of the message b. field returns
+// the length of the field value or a negative protowire error code
+func consumeFields(b []byte, field func(num protowire.Number, typ protowire.Type, b []byte) (int, error)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		m, err := field(num, typ, b)
+		if err != nil {
+			return err
+		}
+		if m < 0 {
+			return protowire.ParseError(m)
+		}
+		b = b[m:]
+	}
+	return nil
+}
+
+// protoSecondsNanos decodes a google.protobuf.Timestamp or Duration message
+func protoSecondsNanos(b []byte) (seconds, nanos int64, err error) {
+	err = consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if (num == 1 || num == 2) && typ == protowire.VarintType {
+			v, n := protowire.ConsumeVarint(b)
+			if num == 1 {
+				seconds = int64(v) //nolint:gosec
+			} else {
+				nanos = int64(int32(v)) //nolint:gosec
+			}
+			return n, nil
+		}
+		return protowire.ConsumeFieldValue(num, typ, b), nil
+	})
+	return seconds, nanos, err
+}
+
+func protoTime(b []byte) (time.Time, error) {
+	seconds, nanos, err := protoSecondsNanos(b)
+	return time.Unix(seconds, nanos).UTC(), err
+}
+
+func protoSource(b []byte) (slog.Attr, error) {
+	var src slog.Source
+	err := consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			src.Function = string(v)
+			return n, nil
+		case num == 2 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			src.File = string(v)
+			return n, nil
+		case num == 3 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			src.Line = int(int64(v)) //nolint:gosec
+			return n, nil
+		}
+		return protowire.ConsumeFieldValue(num, typ, b), nil
+	})
+	return slog.Group(slog.SourceKey,
+		slog.String("function", src.Function), slog.String("file", src.File), slog.Int("line", src.Line),
+	), err
+}
+
+// protoAttr decodes an Attr message
+func protoAttr(b []byte) (slog.Attr, error) { //nolint:cyclop,funlen
+	var a slog.Attr
+	err := consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if num == 1 && typ == protowire.BytesType {
+			v, n := protowire.ConsumeBytes(b)
+			a.Key = string(v)
+			return n, nil
+		}
+
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			switch num {
+			case 3:
+				a.Value = slog.Int64Value(protowire.DecodeZigZag(v))
+			case 4:
+				a.Value = slog.Uint64Value(v)
+			case 6:
+				a.Value = slog.BoolValue(protowire.DecodeBool(v))
+			}
+			return n, nil
+		case protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			if num == 5 {
+				a.Value = slog.Float64Value(math.Float64frombits(v))
+			}
+			return n, nil
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return n, nil
+			}
+			var err error
+			switch num {
+			case 2:
+				a.Value = slog.StringValue(string(v))
+			case 7:
+				var t time.Time
+				t, err = protoTime(v)
+				a.Value = slog.TimeValue(t)
+			case 8:
+				var seconds, nanos int64
+				seconds, nanos, err = protoSecondsNanos(v)
+				a.Value = slog.DurationValue(time.Duration(seconds)*time.Second + time.Duration(nanos))
+			case 9:
+				var members []slog.Attr
+				err = consumeFields(v, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+					if num != 1 || typ != protowire.BytesType {
+						return protowire.ConsumeFieldValue(num, typ, b), nil
+					}
+					v, n := protowire.ConsumeBytes(b)
+					if n < 0 {
+						return n, nil
+					}
+					member, err := protoAttr(v)
+					members = append(members, member)
+					return n, err
+				})
+				a.Value = slog.GroupValue(members...)
+			case 10:
+				a.Value = slog.AnyValue(append([]byte(nil), v...))
+			case 11:
+				a.Value, err = jsonValue(v)
+			}
+			return n, err
+		}
+		return protowire.ConsumeFieldValue(num, typ, b), nil
+	})
+	return a, err
+}
+
+// jsonValue decodes a JSON value like the JSON decoder does
+func jsonValue(b []byte) (slog.Value, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	v, err := parseValue(dec)
+	if err != nil {
+		return slog.Value{}, fmt.Errorf("invalid JSON attribute: %w", err)
+	}
+	return v, nil
+}
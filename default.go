@@ -0,0 +1,16 @@
+package grovelog
+
+import (
+	"log"
+	"log/slog"
+)
+
+// SetDefault installs logger as slog.Default and redirects the output of
+// the standard log package to it at level, so libraries calling log.Printf
+// go through the same handler. The log flags are cleared as the handler
+// adds its own time and source
+func SetDefault(logger *slog.Logger, level slog.Level) {
+	slog.SetDefault(logger)
+	log.SetOutput(slog.NewLogLogger(logger.Handler(), level).Writer())
+	log.SetFlags(0)
+}
@@ -0,0 +1,111 @@
+package grovelog
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Drainer is implemented by handlers and writers that can be shut down
+// gracefully. Handlers returned by NewHandler and AsyncWriter implement it
+type Drainer interface {
+	// Drain stops accepting records, flushes buffered ones and releases
+	// the sink, giving up when ctx is done
+	Drain(ctx context.Context) error
+}
+
+// Drain drains the handler of l, see Drainer, to be called in the
+// shutdown sequence of a service:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+//	defer cancel()
+//	_ = logger.Drain(ctx)
+func (l *Logger) Drain(ctx context.Context) error {
+	return drain(ctx, l.Handler())
+}
+
+// drain drains h if it implements Drainer
+func drain(ctx context.Context, h slog.Handler) error {
+	if d, ok := h.(Drainer); ok {
+		return d.Drain(ctx)
+	}
+	return nil
+}
+
+// drainState is shared by a handler and its derivatives
+type drainState struct {
+	drained atomic.Bool  // Set first, so no new records start
+	mu      sync.RWMutex // Held for reading by in-flight records
+	closed  bool         // The writer is drained or flushed, guarded by mu
+	out     io.Writer
+}
+
+// drainHandler stops handling records once drained and then drains or
+// flushes the writer given to NewHandler
+type drainHandler struct {
+	next  slog.Handler
+	state *drainState
+}
+
+// Enabled reports whether the handler isn't drained and the wrapped handler handles level
+func (h *drainHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return !h.state.drained.Load() && h.next.Enabled(ctx, level)
+}
+
+// Handle passes the record on unless the handler is drained
+func (h *drainHandler) Handle(ctx context.Context, r slog.Record) error { //nolint:gocritic
+	if h.state.drained.Load() {
+		return nil
+	}
+	h.state.mu.RLock()
+	defer h.state.mu.RUnlock()
+	if h.state.drained.Load() {
+		return nil
+	}
+	return h.next.Handle(ctx, r)
+}
+
+// WithAttrs returns a drainHandler wrapping the handler with attrs
+func (h *drainHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &drainHandler{next: h.next.WithAttrs(attrs), state: h.state}
+}
+
+// WithGroup returns a drainHandler wrapping the grouped handler
+func (h *drainHandler) WithGroup(name string) slog.Handler {
+	return &drainHandler{next: h.next.WithGroup(name), state: h.state}
+}
+
+// Drain stops new records, waits for in-flight ones until ctx is done,
+// then drains writers implementing Drainer, like AsyncWriter, or flushes
+// writers with a Flush method, like bufio.Writer. Other writers, e.g.
+// os.Stdout, are left open
+func (h *drainHandler) Drain(ctx context.Context) error {
+	h.state.drained.Store(true)
+	if !h.state.mu.TryLock() {
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for !h.state.mu.TryLock() {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+			}
+		}
+	}
+	defer h.state.mu.Unlock()
+	if h.state.closed {
+		return nil
+	}
+	h.state.closed = true
+
+	switch out := h.state.out.(type) {
+	case Drainer:
+		return out.Drain(ctx)
+	case interface{ Flush() error }:
+		return out.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package grovelog
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+)
+
+// encodeHandler implements the binary formats. It resolves records like
+// the JSON format and passes the built-in attributes and the attributes,
+// groups nested, to encode
+type encodeHandler struct {
+	out     io.Writer
+	mu      *sync.Mutex
+	opts    *slog.HandlerOptions
+	replace replaceAttrFunc
+	levels  []encodeLevel // The root followed by one level per open group
+	encode  recordEncoder
+}
+
+// recordEncoder appends a record given its resolved built-in attributes
+type recordEncoder func(buf []byte, builtins, attrs []slog.Attr) ([]byte, error)
+
+// encodeLevel holds the attributes bound at a group nesting level
+type encodeLevel struct {
+	group string
+	attrs []slog.Attr // Resolved and replaced
+}
+
+// newEncodeHandler creates a handler writing records encoded with encode
+func newEncodeHandler(out io.Writer, opts Options, encode recordEncoder) *encodeHandler { //nolint:gocritic
+	so := slogOptions(opts)
+	return &encodeHandler{
+		out:     out,
+		mu:      new(sync.Mutex),
+		opts:    so,
+		replace: so.ReplaceAttr,
+		levels:  []encodeLevel{{}},
+		encode:  encode,
+	}
+}
+
+// Enabled reports whether level reaches the configured level
+func (h *encodeHandler) Enabled(_ context.Context, level slog.Level) bool {
+	minLevel := slog.LevelInfo
+	if h.opts.Level != nil {
+		minLevel = h.opts.Level.Level()
+	}
+	return level >= minLevel
+}
+
+// Handle encodes the record and writes it with a single Write call
+func (h *encodeHandler) Handle(_ context.Context, r slog.Record) error { //nolint:gocritic
+	builtins := make([]slog.Attr, 0, 4)
+	if !r.Time.IsZero() {
+		builtins = append(builtins, slog.Time(slog.TimeKey, r.Time.Round(0)))
+	}
+	builtins = append(builtins, slog.Any(slog.LevelKey, r.Level), slog.String(slog.MessageKey, r.Message))
+	if h.opts.AddSource && r.PC != 0 {
+		builtins = append(builtins, slog.Any(slog.SourceKey, recordSource(r.PC)))
+	}
+	builtins = h.resolveAttrs(nil, builtins, false)
+
+	// Record attributes belong to the innermost group
+	last := len(h.levels) - 1
+	attrs := h.levels[last].attrs
+	if r.NumAttrs() > 0 {
+		recordAttrs := make([]slog.Attr, 0, r.NumAttrs())
+		r.Attrs(func(a slog.Attr) bool {
+			recordAttrs = append(recordAttrs, a)
+			return true
+		})
+		attrs = append(slices.Clip(attrs), h.resolveAttrs(h.groups(), recordAttrs, true)...)
+	}
+	for i := last; i > 0; i-- {
+		if len(attrs) > 0 {
+			attrs = []slog.Attr{{Key: h.levels[i].group, Value: slog.GroupValue(attrs...)}}
+		}
+		attrs = append(slices.Clip(h.levels[i-1].attrs), attrs...)
+	}
+
+	buf, err := h.encode(make([]byte, 0, 256), builtins, attrs)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err = h.out.Write(buf)
+	return err
+}
+
+// WithAttrs returns a encodeHandler with attrs bound to the innermost group
+func (h *encodeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	resolved := h.resolveAttrs(h.groups(), attrs, true)
+	if len(resolved) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.levels = slices.Clone(h.levels)
+	last := &h2.levels[len(h2.levels)-1]
+	last.attrs = append(slices.Clip(last.attrs), resolved...)
+	return &h2
+}
+
+// WithGroup returns a encodeHandler nesting later attributes in the group
+func (h *encodeHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.levels = append(slices.Clip(h.levels), encodeLevel{group: name})
+	return &h2
+}
+
+// groups returns the names of the open groups
+func (h *encodeHandler) groups() []string {
+	groups := make([]string, 0, len(h.levels)-1)
+	for _, level := range h.levels[1:] {
+		groups = append(groups, level.group)
+	}
+	return groups
+}
+
+// resolveAttrs resolves attrs and applies ReplaceAttr to them, dropping
+// attributes with empty keys and empty groups, and inlining the members
+// of groups with empty keys. user tells user attributes from the built-in ones
+func (h *encodeHandler) resolveAttrs(groups []string, attrs []slog.Attr, user bool) []slog.Attr {
+	out := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		a.Value = a.Value.Resolve()
+		if h.replace != nil && a.Value.Kind() != slog.KindGroup {
+			if user {
+				a = markUserAttr(groups, a)
+			}
+			a = h.replace(groups, a)
+			a.Value = a.Value.Resolve()
+		}
+
+		if a.Value.Kind() == slog.KindGroup {
+			if a.Key == "" {
+				out = append(out, h.resolveAttrs(groups, a.Value.Group(), user)...)
+				continue
+			}
+			members := h.resolveAttrs(append(slices.Clip(groups), a.Key), a.Value.Group(), user)
+			if len(members) > 0 {
+				out = append(out, slog.Attr{Key: a.Key, Value: slog.GroupValue(members...)})
+			}
+			continue
+		}
+		if a.Key != "" {
+			out = append(out, a)
+		}
+	}
+	return out
+}
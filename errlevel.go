@@ -0,0 +1,61 @@
+package grovelog
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/AlonMell/grovelog/util"
+)
+
+// errorLevelHandler lets error attributes drive the record level via
+// util.LevelError and adds a "<key>.code" attribute for util.CodedError
+type errorLevelHandler struct {
+	next    slog.Handler
+	metrics *Metrics // Counts the dropped records, may be nil
+}
+
+// Enabled reports whether the wrapped handler handles level
+func (h *errorLevelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle adjusts the record level and codes, then passes the record on.
+// Records lowered below the minimum level of the wrapped handler are dropped
+func (h *errorLevelHandler) Handle(ctx context.Context, r slog.Record) error { //nolint:gocritic
+	level := r.Level
+	var codes []slog.Attr
+	r.Attrs(func(a slog.Attr) bool {
+		err, ok := a.Value.Any().(error)
+		if a.Value.Kind() != slog.KindAny || !ok || err == nil {
+			return true
+		}
+		level = util.ErrLevel(err, level)
+		if code, ok := util.ErrCode(err); ok {
+			codes = append(codes, slog.String(a.Key+".code", code))
+		}
+		return true
+	})
+
+	if level == r.Level && len(codes) == 0 {
+		return h.next.Handle(ctx, r)
+	}
+	if !h.next.Enabled(ctx, level) {
+		h.metrics.drop()
+		return nil
+	}
+
+	r = r.Clone()
+	r.Level = level
+	r.AddAttrs(codes...)
+	return h.next.Handle(ctx, r)
+}
+
+// WithAttrs returns an errorLevelHandler wrapping the handler with attrs
+func (h *errorLevelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &errorLevelHandler{next: h.next.WithAttrs(attrs), metrics: h.metrics}
+}
+
+// WithGroup returns an errorLevelHandler wrapping the grouped handler
+func (h *errorLevelHandler) WithGroup(name string) slog.Handler {
+	return &errorLevelHandler{next: h.next.WithGroup(name), metrics: h.metrics}
+}
@@ -0,0 +1,37 @@
+package grovelog
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/AlonMell/grovelog/util"
+)
+
+// Keys of the group rendered for error values when Options.ExpandErrors is set
+const (
+	ErrorMessageKey = util.ErrorMessageKey
+	ErrorTypeKey    = util.ErrorTypeKey
+	ErrorCodeKey    = util.ErrorCodeKey
+	ErrorChainKey   = util.ErrorChainKey
+	ErrorVerboseKey = "verbose"
+)
+
+// expandErrors replaces error values with the group of util.ErrDetailed.
+// With verbose, errors implementing fmt.Formatter also get their %+v rendering
+func expandErrors(verbose bool) replaceAttrFunc {
+	return func(_ []string, a slog.Attr) slog.Attr {
+		if a.Value.Kind() != slog.KindAny {
+			return a
+		}
+		err, ok := a.Value.Any().(error)
+		if !ok || err == nil {
+			return a
+		}
+
+		attrs := util.ErrAttrs(err)
+		if _, ok := err.(fmt.Formatter); ok && verbose {
+			attrs = append(attrs, slog.String(ErrorVerboseKey, fmt.Sprintf("%+v", err)))
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(attrs...)}
+	}
+}
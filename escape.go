@@ -0,0 +1,48 @@
+package grovelog
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// escapeControl replaces control characters such as \n, \r and ANSI escape
+// sequences with their Go escape notation, so untrusted input can't forge
+// log lines or recolor the terminal. Tabs are kept as is
+func escapeControl(s string) string {
+	if !hasControl(s) {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s) + 8)
+	for _, r := range s {
+		if r == '\t' || !unicode.IsControl(r) {
+			b.WriteRune(r)
+			continue
+		}
+		q := strconv.QuoteRune(r)
+		b.WriteString(q[1 : len(q)-1])
+	}
+	return b.String()
+}
+
+func hasControl(s string) bool {
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if (c < 0x20 && c != '\t') || c == 0x7f {
+				return true
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if unicode.IsControl(r) {
+			return true
+		}
+		i += size
+	}
+	return false
+}
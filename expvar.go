@@ -0,0 +1,67 @@
+package grovelog
+
+import (
+	"context"
+	"expvar"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// ExpvarBytesKey is the key of the bytes written in the expvar map
+// published by Options.Expvar, next to the "debug", "info", "warn"
+// and "error" record counts
+const ExpvarBytesKey = "bytes"
+
+var expvarMu sync.Mutex
+
+// expvarMap returns the map published under name, publishing it if needed
+func expvarMap(name string) *expvar.Map {
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+	if m, ok := expvar.Get(name).(*expvar.Map); ok {
+		return m
+	}
+	return expvar.NewMap(name)
+}
+
+// expvarWriter adds the number of bytes written to a map
+type expvarWriter struct {
+	out io.Writer
+	m   *expvar.Map
+}
+
+// Write writes p to the wrapped writer and counts the bytes written
+func (w *expvarWriter) Write(p []byte) (int, error) {
+	n, err := w.out.Write(p)
+	w.m.Add(ExpvarBytesKey, int64(n))
+	return n, err
+}
+
+// expvarHandler counts handled records per level in a map
+type expvarHandler struct {
+	next slog.Handler
+	m    *expvar.Map
+}
+
+// Enabled reports whether the wrapped handler handles level
+func (h *expvarHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle counts the record by level and passes it on
+func (h *expvarHandler) Handle(ctx context.Context, r slog.Record) error { //nolint:gocritic
+	h.m.Add(strings.ToLower(metricLevels[metricLevel(r.Level)].String()), 1)
+	return h.next.Handle(ctx, r)
+}
+
+// WithAttrs returns an expvarHandler wrapping the handler with attrs
+func (h *expvarHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &expvarHandler{next: h.next.WithAttrs(attrs), m: h.m}
+}
+
+// WithGroup returns an expvarHandler wrapping the grouped handler
+func (h *expvarHandler) WithGroup(name string) slog.Handler {
+	return &expvarHandler{next: h.next.WithGroup(name), m: h.m}
+}
@@ -0,0 +1,75 @@
+package grovelog
+
+import (
+	"log/slog"
+	"os"
+	"runtime/debug"
+)
+
+// StandardFields selects the process attributes stamped on every record.
+// They are computed once when the handler is created
+type StandardFields struct {
+	Hostname bool   // Adds "hostname"
+	PID      bool   // Adds "pid"
+	Service  string // Adds "service" if not empty
+	Version  string // Adds "version" if not empty
+	// BuildInfo adds the WithBuildInfo group
+	BuildInfo bool
+}
+
+// attrs returns the attributes selected by f
+func (f *StandardFields) attrs() []slog.Attr {
+	var attrs []slog.Attr
+	if f.Hostname {
+		if host, err := os.Hostname(); err == nil {
+			attrs = append(attrs, slog.String("hostname", host))
+		}
+	}
+	if f.PID {
+		attrs = append(attrs, slog.Int("pid", os.Getpid()))
+	}
+	if f.Service != "" {
+		attrs = append(attrs, slog.String("service", f.Service))
+	}
+	if f.Version != "" {
+		attrs = append(attrs, slog.String("version", f.Version))
+	}
+	if f.BuildInfo {
+		if build := WithBuildInfo(); len(build.Value.Group()) > 0 {
+			attrs = append(attrs, build)
+		}
+	}
+	return attrs
+}
+
+// BuildInfoKey is the key of the group added by WithBuildInfo
+const BuildInfoKey = "build"
+
+// WithBuildInfo returns a BuildInfoKey group with the main module version,
+// the VCS revision and the dirty flag read from debug.ReadBuildInfo, so
+// records can be traced back to a build:
+//
+//	logger = logger.With(grovelog.WithBuildInfo())
+//
+// Unknown values are omitted; the attribute is empty, and thus ignored by
+// handlers, for binaries built without module support
+func WithBuildInfo() slog.Attr {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return slog.Attr{}
+	}
+
+	var attrs []slog.Attr
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		attrs = append(attrs, slog.String("version", v))
+	}
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			attrs = append(attrs, slog.String("revision", s.Value))
+		case "vcs.modified":
+			attrs = append(attrs, slog.Bool("dirty", s.Value == "true"))
+		}
+	}
+	return slog.Attr{Key: BuildInfoKey, Value: slog.GroupValue(attrs...)}
+}
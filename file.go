@@ -0,0 +1,308 @@
+package grovelog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileOptions configures NewFileWriter
+type FileOptions struct {
+	// Lock takes an advisory exclusive lock (flock on Unix, LockFileEx on
+	// Windows) around every record, for log files shared by several
+	// processes, e.g. prefork servers, on file systems where appends of
+	// concurrent processes may interleave
+	Lock bool
+
+	// FileMode is the permission of a created file, 0644 if zero
+	FileMode os.FileMode
+	// DirMode is the permission of missing parent directories,
+	// which are created, 0755 if zero
+	DirMode os.FileMode
+	// Owner changes the owner of the file, on Unix only. Nil keeps the
+	// owner of the process
+	Owner *FileOwner
+
+	// Sync selects when written records are flushed to stable storage
+	Sync SyncPolicy
+
+	// MaxSize rotates the file before a record would grow it beyond
+	// MaxSize bytes, 0 disables rotation by size. Only the records of
+	// this writer are counted after the file is opened
+	MaxSize int64
+	// MaxAge rotates the file once the writer has been writing to it for
+	// MaxAge, 0 disables rotation by age
+	MaxAge time.Duration
+	// OnRotateError is called with the errors of automatic rotations.
+	// Records keep going to the current file and the rotation is retried
+	// after another MaxSize bytes or MaxAge
+	OnRotateError func(err error)
+	// Location is the time zone of the dates in backup file names, local
+	// time if nil. NewFileHandler sets it from Options.TimeLocation and
+	// Options.UTC if it is nil
+	Location *time.Location
+}
+
+// SyncPolicy trades throughput for durability. The zero value never syncs
+// and leaves flushing to the operating system. Combine with
+// Options.SyncLevel to sync after Error records
+type SyncPolicy struct {
+	// Every syncs after every n-th record, 1 syncs after every record
+	Every int
+	// Interval syncs records at most Interval after they were written
+	Interval time.Duration
+}
+
+// FileOwner is the user and group owning a log file
+type FileOwner struct {
+	UID int
+	GID int
+}
+
+// RotatingWriter is a log file sink that can be rotated, implemented by
+// FileWriter, which also rotates by size and age, see FileOptions.MaxSize
+// and FileOptions.MaxAge. It is compatible with lumberjack.Logger, so
+// custom rotators can be passed to NewFileHandler
+type RotatingWriter interface {
+	io.WriteCloser
+	// Rotate closes the current file, moves it aside and opens a new one
+	Rotate() error
+}
+
+// FileWriter appends records to a file. Every record is written with a
+// single write call to a file opened with O_APPEND, so records of
+// concurrent processes don't interleave mid-line on local file systems
+type FileWriter struct {
+	path string
+	opts FileOptions
+
+	mu       sync.Mutex
+	f        *os.File
+	size     int64       // Size of the file, for MaxSize
+	opened   time.Time   // Time the file was opened, for MaxAge
+	unsynced int         // Records written since the last sync
+	timer    *time.Timer // Pending Interval sync
+}
+
+// NewFileWriter opens or creates the file at path, and its parent
+// directories, for appending
+func NewFileWriter(path string, opts FileOptions) (*FileWriter, error) {
+	if opts.FileMode == 0 {
+		opts.FileMode = 0o644
+	}
+	if opts.DirMode == 0 {
+		opts.DirMode = 0o755
+	}
+
+	f, err := openLogFile(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &FileWriter{path: path, opts: opts, f: f, size: info.Size(), opened: time.Now()}, nil
+}
+
+// openLogFile opens or creates the file at path, and its parent
+// directories, for appending
+func openLogFile(path string, opts FileOptions) (*os.File, error) { //nolint:gocritic
+	if err := os.MkdirAll(filepath.Dir(path), opts.DirMode); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, opts.FileMode) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+	if opts.Owner != nil {
+		if err := chownFile(f, opts.Owner.UID, opts.Owner.GID); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// Write appends the record p with a single write call, rotating the file
+// first if it reached FileOptions.MaxSize or FileOptions.MaxAge
+func (w *FileWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.rotationDue(len(p)) {
+		if err := w.rotate(); err != nil {
+			w.size, w.opened = 0, time.Now()
+			if w.opts.OnRotateError != nil {
+				w.opts.OnRotateError(err)
+			}
+		}
+	}
+	if w.opts.Lock {
+		if err := lockFile(w.f); err != nil {
+			return 0, err
+		}
+		defer unlockFile(w.f) //nolint:errcheck
+	}
+	n, err := w.f.Write(p)
+	w.size += int64(n)
+	if err != nil {
+		return n, err
+	}
+
+	w.unsynced++
+	switch {
+	case w.opts.Sync.Every > 0 && w.unsynced >= w.opts.Sync.Every:
+		err = w.sync()
+	case w.opts.Sync.Interval > 0 && w.timer == nil:
+		w.timer = time.AfterFunc(w.opts.Sync.Interval, func() {
+			_ = w.Sync()
+		})
+	}
+	return n, err
+}
+
+// Sync flushes the written records to stable storage
+func (w *FileWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.sync()
+}
+
+func (w *FileWriter) sync() error {
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	if w.unsynced == 0 {
+		return nil
+	}
+	w.unsynced = 0
+	return w.f.Sync()
+}
+
+// Close syncs pending records according to the sync policy and closes the file
+func (w *FileWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var err error
+	if w.opts.Sync != (SyncPolicy{}) {
+		err = w.sync()
+	}
+	return errors.Join(err, w.f.Close())
+}
+
+// Rotate renames the file like lumberjack does, inserting the current
+// time before the extension, e.g. app-2006-01-02T15-04-05.000.log, and
+// reopens path. If renaming or reopening fails, writing continues to the
+// current file at path
+func (w *FileWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.rotate()
+}
+
+// rotationDue reports whether a record of n bytes triggers a rotation,
+// empty files are never rotated
+func (w *FileWriter) rotationDue(n int) bool {
+	if w.size == 0 {
+		return false
+	}
+	if w.opts.MaxSize > 0 && w.size+int64(n) > w.opts.MaxSize {
+		return true
+	}
+	return w.opts.MaxAge > 0 && time.Since(w.opened) >= w.opts.MaxAge
+}
+
+func (w *FileWriter) rotate() error {
+	if w.opts.Sync != (SyncPolicy{}) {
+		if err := w.sync(); err != nil {
+			return err
+		}
+	}
+	backup := w.backupPath()
+	if err := os.Rename(w.path, backup); err != nil {
+		return err
+	}
+
+	f, err := openLogFile(w.path, w.opts)
+	if err != nil {
+		// Move the file back, records would go to the backup otherwise
+		if restoreErr := os.Rename(backup, w.path); restoreErr != nil {
+			return errors.Join(err, fmt.Errorf("grovelog: records continue to %s: %w", backup, restoreErr))
+		}
+		return err
+	}
+	err = w.f.Close()
+	w.f, w.size, w.opened = f, 0, time.Now()
+	return err
+}
+
+// backupPath returns the name of a backup of the file, adding a counter
+// if a backup of the same millisecond exists
+func (w *FileWriter) backupPath() string {
+	ext := filepath.Ext(w.path)
+	now := time.Now()
+	if w.opts.Location != nil {
+		now = now.In(w.opts.Location)
+	}
+	name := strings.TrimSuffix(w.path, ext) + "-" + now.Format("2006-01-02T15-04-05.000")
+	backup := name + ext
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(backup); err != nil {
+			return backup
+		}
+		backup = name + "-" + strconv.Itoa(i) + ext
+	}
+}
+
+// Drain closes the file, see Drainer
+func (w *FileWriter) Drain(_ context.Context) error {
+	return w.Close()
+}
+
+// NewFileHandler returns a handler writing records to w, a FileWriter or
+// another RotatingWriter such as lumberjack.Logger. Draining the handler
+// closes w, unless w implements Drainer itself. A FileWriter without a
+// FileOptions.Location dates its backups in the time zone of opts
+func NewFileHandler(w RotatingWriter, opts Options) slog.Handler { //nolint:gocritic
+	if fw, ok := w.(*FileWriter); ok && opts.location() != nil {
+		fw.mu.Lock()
+		if fw.opts.Location == nil {
+			fw.opts.Location = opts.location()
+		}
+		fw.mu.Unlock()
+	}
+	if _, ok := w.(Drainer); ok {
+		return NewHandler(w, opts)
+	}
+	return NewHandler(&rotatingSink{RotatingWriter: w}, opts)
+}
+
+// rotatingSink closes a RotatingWriter on Drain
+type rotatingSink struct {
+	RotatingWriter
+}
+
+// Sync syncs the writer if it can, see Options.SyncLevel
+func (s *rotatingSink) Sync() error {
+	if sw, ok := s.RotatingWriter.(syncer); ok {
+		return sw.Sync()
+	}
+	return nil
+}
+
+// Drain closes the writer, see Drainer
+func (s *rotatingSink) Drain(_ context.Context) error {
+	return s.Close()
+}
@@ -0,0 +1,20 @@
+//go:build !unix && !windows
+
+package grovelog
+
+import "os"
+
+// lockFile does nothing, file locking isn't supported on this platform
+func lockFile(*os.File) error {
+	return nil
+}
+
+// unlockFile does nothing, file locking isn't supported on this platform
+func unlockFile(*os.File) error {
+	return nil
+}
+
+// chownFile does nothing, file owners aren't supported on this platform
+func chownFile(*os.File, int, int) error {
+	return nil
+}
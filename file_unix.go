@@ -0,0 +1,23 @@
+//go:build unix
+
+package grovelog
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive flock on f, blocking until it is available
+func lockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX) //nolint:gosec
+}
+
+// unlockFile releases the flock on f
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN) //nolint:gosec
+}
+
+// chownFile changes the owner of f
+func chownFile(f *os.File, uid, gid int) error {
+	return f.Chown(uid, gid)
+}
@@ -0,0 +1,27 @@
+//go:build windows
+
+package grovelog
+
+import (
+	"math"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockFile takes an exclusive lock on f, blocking until it is available
+func lockFile(f *os.File) error {
+	ol := new(windows.Overlapped)
+	return windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, math.MaxUint32, math.MaxUint32, ol)
+}
+
+// unlockFile releases the lock on f
+func unlockFile(f *os.File) error {
+	ol := new(windows.Overlapped)
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, math.MaxUint32, math.MaxUint32, ol)
+}
+
+// chownFile does nothing, Windows has no Unix file owners
+func chownFile(*os.File, int, int) error {
+	return nil
+}
@@ -0,0 +1,142 @@
+package grovelog
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// FingerprintKey is the key of the fingerprint attribute added to error records
+const FingerprintKey = "error.fingerprint"
+
+// ErrorStat aggregates the error records sharing a fingerprint
+type ErrorStat struct {
+	Fingerprint string
+	Message     string // Message of the first record
+	Function    string // Function that logged the first record
+	Count       int64
+	First       time.Time
+	Last        time.Time
+}
+
+// ErrorTracker fingerprints error records and counts them per fingerprint,
+// enabling "top errors" views without an external service.
+// It is safe for concurrent use
+type ErrorTracker struct {
+	mu    sync.Mutex
+	stats map[string]*ErrorStat
+}
+
+// NewErrorTracker creates an empty ErrorTracker
+func NewErrorTracker() *ErrorTracker {
+	return &ErrorTracker{stats: make(map[string]*ErrorStat)}
+}
+
+// Count returns the number of records seen with fingerprint
+func (t *ErrorTracker) Count(fingerprint string) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if s, ok := t.stats[fingerprint]; ok {
+		return s.Count
+	}
+	return 0
+}
+
+// Top returns up to n stats ordered by descending count, n <= 0 returns all
+func (t *ErrorTracker) Top(n int) []ErrorStat {
+	t.mu.Lock()
+	stats := make([]ErrorStat, 0, len(t.stats))
+	for _, s := range t.stats {
+		stats = append(stats, *s)
+	}
+	t.mu.Unlock()
+
+	slices.SortFunc(stats, func(a, b ErrorStat) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Fingerprint, b.Fingerprint))
+	})
+	if n > 0 && n < len(stats) {
+		stats = stats[:n]
+	}
+	return stats
+}
+
+// Reset forgets all counted fingerprints
+func (t *ErrorTracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	clear(t.stats)
+}
+
+// track counts the record and returns its fingerprint
+func (t *ErrorTracker) track(r *slog.Record) string {
+	function := ""
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		function = frame.Function
+	}
+	fingerprint := Fingerprint(r.Message, function)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.stats[fingerprint]
+	if !ok {
+		s = &ErrorStat{Fingerprint: fingerprint, Message: r.Message, Function: function, First: r.Time}
+		t.stats[fingerprint] = s
+	}
+	s.Count++
+	s.Last = r.Time
+	return fingerprint
+}
+
+// Fingerprint returns a stable fingerprint of a message template and the
+// function that logged it. Digits in the message are ignored, so messages
+// differing only in numbers share a fingerprint
+func Fingerprint(message, function string) string {
+	template := strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return '#'
+		}
+		return r
+	}, message)
+
+	sum := sha256.Sum256([]byte(template + "\x00" + function))
+	return hex.EncodeToString(sum[:8])
+}
+
+// fingerprintHandler fingerprints and counts records at or above Error
+type fingerprintHandler struct {
+	next    slog.Handler
+	tracker *ErrorTracker
+}
+
+// Enabled reports whether the wrapped handler handles level
+func (h *fingerprintHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle adds the fingerprint to error records and passes them on
+func (h *fingerprintHandler) Handle(ctx context.Context, r slog.Record) error { //nolint:gocritic
+	if r.Level >= slog.LevelError {
+		r = r.Clone()
+		r.AddAttrs(slog.String(FingerprintKey, h.tracker.track(&r)))
+	}
+	return h.next.Handle(ctx, r)
+}
+
+// WithAttrs returns a fingerprintHandler wrapping the handler with attrs
+func (h *fingerprintHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &fingerprintHandler{next: h.next.WithAttrs(attrs), tracker: h.tracker}
+}
+
+// WithGroup returns a fingerprintHandler wrapping the grouped handler
+func (h *fingerprintHandler) WithGroup(name string) slog.Handler {
+	return &fingerprintHandler{next: h.next.WithGroup(name), tracker: h.tracker}
+}
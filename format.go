@@ -0,0 +1,83 @@
+package grovelog
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// formatNames are the names of the formats, as parsed by ParseFormat
+var formatNames = map[Format]string{
+	JSON:     "json",
+	Plain:    "plain",
+	Color:    "color",
+	Auto:     "auto",
+	MsgPack:  "msgpack",
+	Protobuf: "protobuf",
+}
+
+// String returns the name of the format
+func (f Format) String() string {
+	if name, ok := formatNames[f]; ok {
+		return name
+	}
+	return "Format(" + strconv.Itoa(int(f)) + ")"
+}
+
+// ParseFormat parses a format name: "json", "plain" (or "text"),
+// "color", "auto", "msgpack" or "protobuf", case insensitively
+func ParseFormat(s string) (Format, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if name == "text" {
+		return Plain, nil
+	}
+	for f, n := range formatNames {
+		if n == name {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("grovelog: unknown format %q", s)
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (f Format) MarshalText() ([]byte, error) {
+	return []byte(f.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, see ParseFormat
+func (f *Format) UnmarshalText(text []byte) error {
+	parsed, err := ParseFormat(string(text))
+	if err != nil {
+		return err
+	}
+	*f = parsed
+	return nil
+}
+
+// Set implements flag.Value, so a Format can be a command line flag:
+//
+//	format := grovelog.Color
+//	flag.Var(&format, "log-format", "json, plain, color, auto, msgpack or protobuf")
+func (f *Format) Set(s string) error {
+	return f.UnmarshalText([]byte(s))
+}
+
+// ParseLevel parses a level name like "debug", "WARN", "warning" or
+// "error+2", case insensitively, or a numeric level like "-4". Use
+// flag.TextVar with a slog.Level for level flags
+func ParseLevel(s string) (slog.Level, error) {
+	name := strings.TrimSpace(s)
+	if n, err := strconv.Atoi(name); err == nil {
+		return slog.Level(n), nil
+	}
+	if rest, ok := strings.CutPrefix(strings.ToLower(name), "warning"); ok {
+		name = "warn" + rest
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("grovelog: unknown level %q", s)
+	}
+	return level, nil
+}
@@ -0,0 +1,57 @@
+package grovelog
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"runtime"
+	"strconv"
+)
+
+// GoroutineKey is the key of the goroutine ID attribute added by Options.AddGoroutineID
+const GoroutineKey = "goroutine"
+
+// goroutineID parses the ID of the calling goroutine from the header of
+// its stack trace, "goroutine 42 [running]:"
+func goroutineID() (uint64, bool) {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b, ok := bytes.CutPrefix(b, []byte("goroutine "))
+	if !ok {
+		return 0, false
+	}
+	if i := bytes.IndexByte(b, ' '); i > 0 {
+		b = b[:i]
+	}
+	id, err := strconv.ParseUint(string(b), 10, 64)
+	return id, err == nil
+}
+
+// goroutineHandler adds the ID of the logging goroutine to every record
+type goroutineHandler struct {
+	next slog.Handler
+}
+
+// Enabled reports whether the wrapped handler handles level
+func (h *goroutineHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle adds the goroutine attribute and passes the record on
+func (h *goroutineHandler) Handle(ctx context.Context, r slog.Record) error { //nolint:gocritic
+	if id, ok := goroutineID(); ok {
+		r = r.Clone()
+		r.AddAttrs(slog.Uint64(GoroutineKey, id))
+	}
+	return h.next.Handle(ctx, r)
+}
+
+// WithAttrs returns a goroutineHandler wrapping the handler with attrs
+func (h *goroutineHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &goroutineHandler{next: h.next.WithAttrs(attrs)}
+}
+
+// WithGroup returns a goroutineHandler wrapping the grouped handler
+func (h *goroutineHandler) WithGroup(name string) slog.Handler {
+	return &goroutineHandler{next: h.next.WithGroup(name)}
+}
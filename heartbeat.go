@@ -0,0 +1,91 @@
+package grovelog
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// UptimeKey is the key of the uptime attribute of heartbeat records
+const UptimeKey = "uptime"
+
+// HeartbeatOptions configures a Heartbeat
+type HeartbeatOptions struct {
+	// Interval between records, one minute if not positive
+	Interval time.Duration
+	// Message of the records, "alive" if empty
+	Message string
+	// Level of the records
+	Level slog.Level
+	// Gauges returns custom attributes added to every record, e.g. queue sizes
+	Gauges func() []slog.Attr
+}
+
+// Heartbeat periodically logs an "alive" record with the uptime,
+// for services whose only health signal is their log stream
+type Heartbeat struct {
+	logger *slog.Logger
+	opts   HeartbeatOptions
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Heartbeat returns a stopped Heartbeat logging to l
+func (l *Logger) Heartbeat(opts HeartbeatOptions) *Heartbeat {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Minute
+	}
+	if opts.Message == "" {
+		opts.Message = "alive"
+	}
+	return &Heartbeat{logger: l.Logger, opts: opts}
+}
+
+// Start starts logging in a background goroutine, the uptime is measured
+// from Start. Starting a running Heartbeat does nothing
+func (hb *Heartbeat) Start() {
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+	if hb.stop != nil {
+		return
+	}
+
+	hb.stop = make(chan struct{})
+	hb.done = make(chan struct{})
+	go hb.run(time.Now(), hb.stop, hb.done)
+}
+
+// Stop stops logging and waits for the background goroutine to exit
+func (hb *Heartbeat) Stop() {
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+	if hb.stop == nil {
+		return
+	}
+
+	close(hb.stop)
+	<-hb.done
+	hb.stop, hb.done = nil, nil
+}
+
+func (hb *Heartbeat) run(start time.Time, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(hb.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			attrs := []slog.Attr{slog.Duration(UptimeKey, time.Since(start).Round(time.Millisecond))}
+			if hb.opts.Gauges != nil {
+				attrs = append(attrs, hb.opts.Gauges()...)
+			}
+			hb.logger.LogAttrs(context.Background(), hb.opts.Level, hb.opts.Message, attrs...)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package grovelog
+
+import (
+	"log/slog"
+	"slices"
+)
+
+// HighlightRule paints the values of matching attributes in Color output,
+// making important attributes stand out. The first matching rule wins
+type HighlightRule struct {
+	// Key reports whether the rule applies to the attribute with the full
+	// key (group names joined with ".")
+	Key func(key string) bool
+	// Match reports whether the value should be highlighted,
+	// nil matches every value
+	Match func(v slog.Value) bool
+	Style Style
+}
+
+// KeyIs returns a HighlightRule key matcher accepting any of keys
+func KeyIs(keys ...string) func(key string) bool {
+	return func(key string) bool {
+		return slices.Contains(keys, key)
+	}
+}
+
+// valueStyle returns the style of the value of the field key
+func (h *Handler) valueStyle(key string, value any) Style {
+	for _, rule := range h.opts.Highlights {
+		if rule.Key != nil && !rule.Key(key) {
+			continue
+		}
+		if rule.Match == nil || rule.Match(slog.AnyValue(value)) {
+			return rule.Style
+		}
+	}
+	return h.theme.Value
+}
+
+// downsampleRules converts the truecolor styles of rules to profile
+func downsampleRules(rules []HighlightRule, profile colorProfile) []HighlightRule {
+	if len(rules) == 0 || profile == profileTrueColor {
+		return rules
+	}
+	out := make([]HighlightRule, len(rules))
+	for i, rule := range rules {
+		rule.Style = rule.Style.downsample(profile)
+		out[i] = rule
+	}
+	return out
+}
@@ -0,0 +1,32 @@
+package grovelog
+
+import (
+	"time"
+
+	"github.com/AlonMell/grovelog/util"
+)
+
+// ValueFormatter renders attribute values in Color output.
+// It returns false to leave v to the default rendering
+type ValueFormatter func(v any) (string, bool)
+
+// HumanValues is the default ValueFormatter: durations render like 1.42s
+// and util.ByteSize values like 3.2 MiB. The JSON and Plain formats keep
+// their own encoding, so durations and sizes stay numeric in JSON
+func HumanValues(v any) (string, bool) {
+	switch x := v.(type) {
+	case time.Duration:
+		return x.String(), true
+	case util.ByteSize:
+		return x.String(), true
+	}
+	return "", false
+}
+
+// humanize applies the configured value formatter to v
+func (h *Handler) humanize(v any) (string, bool) {
+	if h.opts.ValueFormatter != nil {
+		return h.opts.ValueFormatter(v)
+	}
+	return HumanValues(v)
+}
@@ -0,0 +1,99 @@
+package grovelog
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// LevelIcons maps levels to glyphs prefixed to the level label in Color format
+type LevelIcons map[slog.Level]string
+
+// UnicodeIcons returns icons using plain Unicode symbols
+func UnicodeIcons() LevelIcons {
+	return LevelIcons{
+		slog.LevelDebug: "✓",
+		slog.LevelInfo:  "ℹ",
+		slog.LevelWarn:  "⚠",
+		slog.LevelError: "✖",
+	}
+}
+
+// NerdFontIcons returns icons from the Nerd Fonts Font Awesome set,
+// which require a patched terminal font
+func NerdFontIcons() LevelIcons {
+	return LevelIcons{
+		slog.LevelDebug: "\uf188", // nf-fa-bug
+		slog.LevelInfo:  "\uf05a", // nf-fa-info_circle
+		slog.LevelWarn:  "\uf071", // nf-fa-warning
+		slog.LevelError: "\uf057", // nf-fa-times_circle
+	}
+}
+
+// levelLabel returns the level label with its icon, if any
+func (h *Handler) levelLabel(level slog.Level) string {
+	label := level.String() + ":"
+	if icon, ok := h.opts.LevelIcons[level]; ok && icon != "" {
+		return icon + " " + label
+	}
+	return label
+}
+
+// LoggerKey is the key of the top-level attribute holding the logger
+// name, rendered as a column after the level in Color format when
+// Options.AlignColumns is set, e.g. set with
+// logger.With(grovelog.LoggerKey, "db")
+const LoggerKey = "logger"
+
+// levelWidth returns the width of the widest standard level label
+func (h *Handler) levelWidth() int {
+	width := 0
+	for _, level := range []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError} {
+		width = max(width, utf8.RuneCountInString(h.levelLabel(level)))
+	}
+	return width
+}
+
+// levelPadding returns the spaces aligning label with the widest
+// standard level label when Options.AlignColumns is set
+func (h *Handler) levelPadding(label string) string {
+	if !h.opts.AlignColumns {
+		return ""
+	}
+	return padding(h.labelWidth, label)
+}
+
+// loggerName removes the top-level LoggerKey field from fields when
+// Options.AlignColumns is set and returns the logger name column, escaped
+// and truncated like the message and padded to Options.LoggerNameWidth.
+// truncated reports whether the name was truncated
+func (h *Handler) loggerName(fields []field) (column string, rest []field, truncated bool) {
+	if !h.opts.AlignColumns {
+		return "", fields, false
+	}
+
+	name := ""
+	if i := slices.IndexFunc(fields, func(f field) bool { return len(f.groups) == 0 && f.key == LoggerKey }); i >= 0 {
+		name = fmt.Sprint(fields[i].value)
+		fields = slices.Delete(fields, i, i+1)
+	}
+	name, truncated = truncateString(name, h.opts.MaxAttrValueLen)
+	if !h.opts.DisableEscaping {
+		name = escapeControl(name)
+	}
+
+	if name == "" && h.opts.LoggerNameWidth == 0 {
+		return "", fields, truncated
+	}
+	return h.paint(h.theme.Key, name) + padding(h.opts.LoggerNameWidth, name), fields, truncated
+}
+
+// padding returns the spaces padding s to width
+func padding(width int, s string) string {
+	if n := width - utf8.RuneCountInString(s); n > 0 {
+		return strings.Repeat(" ", n)
+	}
+	return ""
+}
@@ -0,0 +1,163 @@
+package grovelog
+
+import (
+	"encoding/json"
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONValue appends v encoded like encoding/json, indented with
+// prefix if indent is set. Strings, numbers, booleans, times, durations and
+// groups are encoded without reflection, other values fall back to encoding/json
+func appendJSONValue(buf []byte, v any, prefix string, indent bool) ([]byte, error) {
+	switch x := v.(type) {
+	case nil:
+		return append(buf, "null"...), nil
+	case string:
+		return appendJSONString(buf, x), nil
+	case bool:
+		return strconv.AppendBool(buf, x), nil
+	case int:
+		return strconv.AppendInt(buf, int64(x), 10), nil
+	case int64:
+		return strconv.AppendInt(buf, x, 10), nil
+	case uint64:
+		return strconv.AppendUint(buf, x, 10), nil
+	case time.Duration:
+		return strconv.AppendInt(buf, int64(x), 10), nil
+	case float64:
+		if !math.IsNaN(x) && !math.IsInf(x, 0) {
+			return appendJSONFloat(buf, x), nil
+		}
+	case time.Time:
+		if y := x.Year(); y >= 0 && y <= 9999 {
+			buf = append(buf, '"')
+			buf = x.AppendFormat(buf, time.RFC3339Nano)
+			return append(buf, '"'), nil
+		}
+	case []slog.Attr:
+		return appendJSONGroup(buf, x, prefix, indent)
+	}
+
+	// Unsupported values like NaN get the encoding/json error
+	var b []byte
+	var err error
+	if indent {
+		b, err = json.MarshalIndent(v, prefix, "  ")
+	} else {
+		b, err = json.Marshal(v)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(buf, b...), nil
+}
+
+// appendJSONGroup appends attrs as a JSON object
+func appendJSONGroup(buf []byte, attrs []slog.Attr, prefix string, indent bool) ([]byte, error) {
+	if len(attrs) == 0 {
+		return append(buf, "{}"...), nil
+	}
+
+	var err error
+	buf = append(buf, '{')
+	for i, a := range attrs {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		if indent {
+			buf = append(buf, '\n')
+			buf = append(buf, prefix...)
+			buf = append(buf, "  "...)
+		}
+		buf = appendJSONString(buf, a.Key)
+		buf = append(buf, ':')
+		if indent {
+			buf = append(buf, ' ')
+		}
+		if buf, err = appendJSONValue(buf, a.Value.Resolve().Any(), prefix+"  ", indent); err != nil {
+			return nil, err
+		}
+	}
+	if indent {
+		buf = append(buf, '\n')
+		buf = append(buf, prefix...)
+	}
+	return append(buf, '}'), nil
+}
+
+// appendJSONFloat appends f like encoding/json: exponent notation
+// only for very small and very large magnitudes
+func appendJSONFloat(buf []byte, f float64) []byte {
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	buf = strconv.AppendFloat(buf, f, format, -1, 64)
+	if format == 'e' {
+		// Clean up e-09 to e-9
+		n := len(buf)
+		if n >= 4 && buf[n-4] == 'e' && buf[n-3] == '-' && buf[n-2] == '0' {
+			buf[n-2] = buf[n-1]
+			buf = buf[:n-1]
+		}
+	}
+	return buf
+}
+
+// appendJSONString appends s as a JSON string, escaped like encoding/json
+// including its HTML escaping, with invalid UTF-8 replaced by U+FFFD
+func appendJSONString(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= ' ' && c != '"' && c != '\\' && c != '<' && c != '>' && c != '&' {
+				i++
+				continue
+			}
+			buf = append(buf, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				buf = append(buf, '\\', c)
+			case '\b':
+				buf = append(buf, '\\', 'b')
+			case '\f':
+				buf = append(buf, '\\', 'f')
+			case '\n':
+				buf = append(buf, '\\', 'n')
+			case '\r':
+				buf = append(buf, '\\', 'r')
+			case '\t':
+				buf = append(buf, '\\', 't')
+			default:
+				buf = append(buf, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, `\ufffd`...)
+		case r == '\u2028' || r == '\u2029':
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, '\\', 'u', '2', '0', '2', hexDigits[r&0xF])
+		default:
+			i += size
+			continue
+		}
+		i += size
+		start = i
+	}
+	buf = append(buf, s[start:]...)
+	return append(buf, '"')
+}
@@ -0,0 +1,43 @@
+package grovelog
+
+import (
+	"log/slog"
+	"regexp"
+
+	"github.com/AlonMell/grovelog/util"
+)
+
+// klogHeader matches the "Lmmdd hh:mm:ss.uuuuuu threadid file:line] msg"
+// header written by klog and glog
+var klogHeader = regexp.MustCompile(`^([IWEF])\d{4} \d{2}:\d{2}:\d{2}\.\d+\s+\d+ ([^\]]+)\] ?(.*)$`)
+
+// KlogWriter returns a LineWriter re-emitting klog and glog output, e.g.
+// of Kubernetes client-go, as records with the parsed severity and a
+// util.CallerKey attribute with the file:line of the call:
+//
+//	klog.LogToStderr(false)
+//	klog.SetOutput(grovelog.KlogWriter(logger))
+//
+// Lines without a klog header, like continuation lines, are logged at Info
+func KlogWriter(logger *slog.Logger) *LineWriter {
+	return &LineWriter{logger: logger, level: slog.LevelInfo, parse: parseKlogLine}
+}
+
+// parseKlogLine parses a klog header, Fatal maps to a level above Error
+func parseKlogLine(line string, fallback slog.Level) (slog.Level, string, []slog.Attr) {
+	m := klogHeader.FindStringSubmatch(line)
+	if m == nil {
+		return fallback, line, nil
+	}
+
+	level := slog.LevelInfo
+	switch m[1] {
+	case "W":
+		level = slog.LevelWarn
+	case "E":
+		level = slog.LevelError
+	case "F":
+		level = slog.LevelError + 4
+	}
+	return level, m[3], []slog.Attr{slog.String(util.CallerKey, m[2])}
+}
@@ -0,0 +1,264 @@
+package grovelog
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Layout selects how the Color format renders record attributes
+type Layout int
+
+const (
+	// LayoutIndented renders attributes as an indented JSON object
+	LayoutIndented Layout = iota
+	// LayoutKeyValue renders attributes as key=value pairs on the message line
+	LayoutKeyValue
+	// LayoutJSONLine renders attributes as minified JSON on the message line
+	LayoutJSONLine
+	// LayoutExpanded renders every attribute on its own indented line
+	// beneath the message, with nested groups indented further
+	LayoutExpanded
+)
+
+// field is an attribute collected for rendering
+type field struct {
+	name   string   // Full key, group names joined with "."
+	groups []string // Groups the attribute is nested in
+	key    string
+	value  any
+}
+
+// appendFields appends the collected fields according to Options.Layout
+func (h *Handler) appendFields(buf []byte, fields []field) ([]byte, error) {
+	if h.opts.SortKeys {
+		slices.SortFunc(fields, func(a, b field) int {
+			return strings.Compare(a.name, b.name)
+		})
+	}
+
+	switch h.opts.Layout {
+	case LayoutKeyValue:
+		return h.appendKeyValues(buf, fields)
+	case LayoutExpanded:
+		return h.appendExpanded(buf, fields)
+	case LayoutJSONLine:
+		if h.opts.NestGroups {
+			return h.appendNestedJSON(buf, fields, 0, false)
+		}
+		return h.appendJSONObject(buf, fields, false)
+	default:
+		if h.opts.NestGroups {
+			return h.appendNestedJSON(buf, fields, 0, true)
+		}
+		return h.appendJSONObject(buf, fields, true)
+	}
+}
+
+// appendJSONObject appends fields as a JSON object with keys and values
+// painted according to the theme, one field per line if indent is set
+func (h *Handler) appendJSONObject(buf []byte, fields []field, indent bool) ([]byte, error) {
+	var err error
+	buf = append(buf, '{')
+	for i, f := range fields {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		if indent {
+			buf = append(buf, "\n  "...)
+		}
+		buf = h.appendStyle(buf, h.theme.Key)
+		buf = appendJSONString(buf, f.name)
+		buf = h.appendReset(buf, h.theme.Key)
+		buf = append(buf, ':')
+		if indent {
+			buf = append(buf, ' ')
+		}
+
+		style := h.valueStyle(f.name, f.value)
+		buf = h.appendStyle(buf, style)
+		if buf, err = h.appendValue(buf, f.value, "  ", indent); err != nil {
+			return nil, err
+		}
+		buf = h.appendReset(buf, style)
+	}
+	if indent {
+		buf = append(buf, '\n')
+	}
+	return append(buf, '}'), nil
+}
+
+// appendNestedJSON appends fields as a JSON object where groups from
+// depth on become nested objects, in order of their first appearance
+func (h *Handler) appendNestedJSON(buf []byte, fields []field, depth int, indent bool) ([]byte, error) {
+	pad := strings.Repeat("  ", depth+1)
+	buf = append(buf, '{')
+	done := make(map[string]bool)
+	n := 0
+	for _, f := range fields {
+		name := f.key
+		if len(f.groups) > depth {
+			name = f.groups[depth]
+			if done[name] {
+				continue
+			}
+			done[name] = true
+		}
+
+		if n > 0 {
+			buf = append(buf, ',')
+		}
+		n++
+		if indent {
+			buf = append(buf, '\n')
+			buf = append(buf, pad...)
+		}
+		buf = h.appendStyle(buf, h.theme.Key)
+		buf = appendJSONString(buf, name)
+		buf = h.appendReset(buf, h.theme.Key)
+		buf = append(buf, ':')
+		if indent {
+			buf = append(buf, ' ')
+		}
+
+		var err error
+		if len(f.groups) > depth {
+			members := slices.DeleteFunc(slices.Clone(fields), func(m field) bool {
+				return len(m.groups) <= depth || m.groups[depth] != name
+			})
+			if buf, err = h.appendNestedJSON(buf, members, depth+1, indent); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		style := h.valueStyle(f.name, f.value)
+		buf = h.appendStyle(buf, style)
+		if buf, err = h.appendValue(buf, f.value, pad, indent); err != nil {
+			return nil, err
+		}
+		buf = h.appendReset(buf, style)
+	}
+	if indent && n > 0 {
+		buf = append(buf, '\n')
+		buf = append(buf, pad[2:]...)
+	}
+	return append(buf, '}'), nil
+}
+
+// appendKeyValues appends fields as space separated key=value pairs
+func (h *Handler) appendKeyValues(buf []byte, fields []field) ([]byte, error) {
+	for i, f := range fields {
+		value, err := h.formatValue(f.value)
+		if err != nil {
+			return nil, err
+		}
+
+		if i > 0 {
+			buf = append(buf, ' ')
+		}
+		buf = append(buf, h.paint(h.theme.Key, quoteIfNeeded(f.name))...)
+		buf = append(buf, '=')
+		buf = append(buf, h.paint(h.valueStyle(f.name, f.value), value)...)
+	}
+	return buf, nil
+}
+
+// appendExpanded appends fields one per line, each preceded by a newline
+// and indented by its nesting depth. Fields are clustered by group and
+// group names are written as headers
+func (h *Handler) appendExpanded(buf []byte, fields []field) ([]byte, error) {
+	indent := h.opts.Indent
+	if indent == "" {
+		indent = "  "
+	}
+
+	clustered := slices.Clone(fields)
+	slices.SortStableFunc(clustered, func(a, b field) int {
+		return slices.Compare(a.groups, b.groups)
+	})
+
+	var prev []string
+	for _, f := range clustered {
+		common := 0
+		for common < len(prev) && common < len(f.groups) && prev[common] == f.groups[common] {
+			common++
+		}
+		for depth := common; depth < len(f.groups); depth++ {
+			buf = append(buf, '\n')
+			buf = append(buf, strings.Repeat(indent, depth+1)...)
+			buf = append(buf, h.paint(h.theme.Key, quoteIfNeeded(f.groups[depth]))...)
+			buf = append(buf, ':')
+		}
+		prev = f.groups
+
+		value, err := h.formatValue(f.value)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, '\n')
+		buf = append(buf, strings.Repeat(indent, len(f.groups)+1)...)
+		buf = append(buf, h.paint(h.theme.Key, quoteIfNeeded(f.key))...)
+		buf = append(buf, ": "...)
+		buf = append(buf, h.paint(h.valueStyle(f.name, f.value), value)...)
+	}
+	return buf, nil
+}
+
+// appendValue appends v encoded as JSON, indented with prefix if indent
+// is set. Values handled by the value formatter are encoded as JSON strings
+func (h *Handler) appendValue(buf []byte, v any, prefix string, indent bool) ([]byte, error) {
+	if s, ok := h.humanize(v); ok {
+		return appendJSONString(buf, s), nil
+	}
+	if err, ok := v.(error); ok {
+		return appendJSONString(buf, err.Error()), nil // Like the JSON format does
+	}
+	return appendJSONValue(buf, v, prefix, indent)
+}
+
+// formatValue renders v for the text layouts: scalars as text,
+// strings quoted when needed, anything else as minified JSON
+func (h *Handler) formatValue(v any) (string, error) {
+	if s, ok := h.humanize(v); ok {
+		return quoteIfNeeded(s), nil
+	}
+
+	switch x := v.(type) {
+	case time.Time:
+		return x.Format(time.RFC3339Nano), nil
+	case error:
+		return quoteIfNeeded(x.Error()), nil
+	case fmt.Stringer:
+		return quoteIfNeeded(x.String()), nil
+	}
+
+	sv := slog.AnyValue(v)
+	switch sv.Kind() {
+	case slog.KindString:
+		return quoteIfNeeded(sv.String()), nil
+	case slog.KindAny, slog.KindGroup, slog.KindLogValuer:
+		b, err := appendJSONValue(nil, v, "", false)
+		return string(b), err
+	default:
+		return sv.String(), nil
+	}
+}
+
+// quoteIfNeeded quotes s if it is empty or contains spaces,
+// quotes, '=' or non-printable characters
+func quoteIfNeeded(s string) string {
+	if s == "" {
+		return `""`
+	}
+	for _, r := range s {
+		if r == ' ' || r == '=' || r == '"' || !unicode.IsPrint(r) {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
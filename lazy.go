@@ -0,0 +1,130 @@
+package grovelog
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+)
+
+// Lazy is an attribute value computed only when a handler encodes it,
+// so expensive values cost nothing for disabled records:
+//
+//	logger.Debug("request", slog.Any("body", grovelog.Lazy(func() slog.Value {
+//		return slog.StringValue(dump(req))
+//	})))
+type Lazy func() slog.Value
+
+// LogValue implements slog.LogValuer
+func (f Lazy) LogValue() slog.Value {
+	return f()
+}
+
+// WithLazy returns a Logger adding a Lazy attribute to every record.
+// Unlike With, which lets handlers resolve values up front, the value
+// is computed per record and only for enabled records
+func (l *Logger) WithLazy(key string, f func() slog.Value) *Logger {
+	h := &lazyHandler{
+		derived: newDerived(l.Handler()),
+		attr:    slog.Any(key, Lazy(f)),
+	}
+	return &Logger{Logger: slog.New(h)}
+}
+
+// lazyHandler adds a lazy attribute to records. As handlers may resolve
+// attributes passed to WithAttrs, the attribute is added to every record
+// instead, at the level of base
+type lazyHandler struct {
+	derived
+	attr slog.Attr
+}
+
+// Enabled reports whether the wrapped handler handles level
+func (h *lazyHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle passes the record on with the lazy attribute
+func (h *lazyHandler) Handle(ctx context.Context, r slog.Record) error { //nolint:gocritic
+	return h.handle(ctx, r, h.attr)
+}
+
+// WithAttrs returns a lazyHandler adding the lazy attribute before attrs
+func (h *lazyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &lazyHandler{derived: h.withAttrs(attrs), attr: h.attr}
+}
+
+// WithGroup returns a lazyHandler adding the lazy attribute outside the group
+func (h *lazyHandler) WithGroup(name string) slog.Handler {
+	return &lazyHandler{derived: h.withGroup(name), attr: h.attr}
+}
+
+// Drain drains the wrapped handler
+func (h *lazyHandler) Drain(ctx context.Context) error {
+	return drain(ctx, h.next)
+}
+
+// derived is a handler derived from base by WithAttrs and WithGroup
+// calls, which adds attributes to records at the level of base. The
+// calls before the first WithGroup are applied to top once, the groups
+// opened afterwards and their attributes are added to every record
+type derived struct {
+	next   slog.Handler // base with every call applied
+	top    slog.Handler // base with the calls before the first WithGroup applied
+	groups []derivedGroup
+}
+
+// derivedGroup is a group opened on a derived handler with its attributes
+type derivedGroup struct {
+	name  string
+	attrs []slog.Attr
+}
+
+// newDerived returns base as a derived handler
+func newDerived(base slog.Handler) derived {
+	return derived{next: base, top: base}
+}
+
+func (d derived) withAttrs(attrs []slog.Attr) derived {
+	d.next = d.next.WithAttrs(attrs)
+	if len(d.groups) == 0 {
+		d.top = d.top.WithAttrs(attrs)
+		return d
+	}
+	d.groups = slices.Clone(d.groups)
+	last := &d.groups[len(d.groups)-1]
+	last.attrs = append(slices.Clip(last.attrs), attrs...)
+	return d
+}
+
+func (d derived) withGroup(name string) derived {
+	if name == "" {
+		return d
+	}
+	d.next = d.next.WithGroup(name)
+	d.groups = append(slices.Clip(d.groups), derivedGroup{name: name})
+	return d
+}
+
+// handle passes r to top with extra added at its level, and the
+// attributes of r nested in the open groups
+func (d derived) handle(ctx context.Context, r slog.Record, extra ...slog.Attr) error { //nolint:gocritic
+	if len(d.groups) == 0 {
+		r = r.Clone()
+		r.AddAttrs(extra...)
+		return d.top.Handle(ctx, r)
+	}
+
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	for i := len(d.groups) - 1; i >= 0; i-- {
+		members := append(slices.Clip(d.groups[i].attrs), attrs...)
+		attrs = []slog.Attr{{Key: d.groups[i].name, Value: slog.GroupValue(members...)}}
+	}
+	nested := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	nested.AddAttrs(extra...)
+	nested.AddAttrs(attrs...)
+	return d.top.Handle(ctx, nested)
+}
@@ -0,0 +1,60 @@
+package grovelog
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LevelFile routes records at Level and above to the file at Path
+type LevelFile struct {
+	Path  string
+	Level slog.Leveler
+}
+
+// LevelFileOptions configures NewLevelFileHandler
+type LevelFileOptions struct {
+	// Options encodes the records of every file, its level is replaced
+	// by the level of each file
+	Options
+	// Files are the files and the levels routed to them
+	Files []LevelFile
+	// FileOptions configures the FileWriter of every file, including its
+	// rotation policy. Ignored if NewWriter is set
+	FileOptions FileOptions
+	// NewWriter opens the writer of the file at path, e.g. a
+	// lumberjack.Logger, so every file shares its rotation policy.
+	// Nil opens a FileWriter with FileOptions
+	NewWriter func(path string) (RotatingWriter, error)
+}
+
+// NewLevelFileHandler returns a handler writing every record, encoded
+// according to opts, to each file whose level it reaches, e.g. debug.log
+// for everything, app.log for Info and above and error.log for Error and
+// above. Drain the handler to close the files
+func NewLevelFileHandler(opts LevelFileOptions) (slog.Handler, error) { //nolint:gocritic
+	newWriter := opts.NewWriter
+	if newWriter == nil {
+		newWriter = func(path string) (RotatingWriter, error) {
+			return NewFileWriter(path, opts.FileOptions)
+		}
+	}
+
+	handlers := make([]slog.Handler, 0, len(opts.Files))
+	for _, lf := range opts.Files {
+		w, err := newWriter(lf.Path)
+		if err != nil {
+			_ = drain(context.Background(), Fanout(handlers...))
+			return nil, err
+		}
+
+		fo := opts.Options
+		so := slog.HandlerOptions{}
+		if opts.SlogOpts != nil {
+			so = *opts.SlogOpts
+		}
+		so.Level = lf.Level
+		fo.SlogOpts = &so
+		handlers = append(handlers, NewFileHandler(w, fo))
+	}
+	return Fanout(handlers...), nil
+}
@@ -0,0 +1,247 @@
+package grovelog
+
+import (
+	"context"
+	"log/slog"
+	"unicode/utf8"
+)
+
+// TruncatedKey is the key of the marker attribute added to records
+// whose message or attribute values were truncated
+const TruncatedKey = "truncated"
+
+// AttrsDroppedKey is the key of the marker attribute holding the number
+// of attributes dropped from a record by Options.MaxAttrs
+const AttrsDroppedKey = "attrs_dropped"
+
+const ellipsis = "…"
+
+// limitHandler enforces the size limits from Options before passing
+// records to the wrapped handler. The markers are added at the top level,
+// outside the groups opened with WithGroup. The Color handler applies the
+// limits itself, see limitFields
+type limitHandler struct {
+	derived
+	maxMsg   int
+	maxValue int
+	maxAttrs int
+
+	bound     int  // leaf attributes already bound via WithAttrs
+	dropped   int  // leaf attributes dropped in WithAttrs
+	truncated bool // an attribute bound via WithAttrs was truncated
+}
+
+// Enabled reports whether the wrapped handler handles level
+func (h *limitHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle truncates the record message and attributes and passes it on
+func (h *limitHandler) Handle(ctx context.Context, r slog.Record) error { //nolint:gocritic
+	msg, truncated := truncateString(r.Message, h.maxMsg)
+
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	attrs, t, dropped := h.limitAttrs(attrs, h.bound)
+
+	nr := slog.NewRecord(r.Time, r.Level, msg, r.PC)
+	nr.AddAttrs(attrs...)
+	var markers []slog.Attr
+	if truncated || t || h.truncated {
+		markers = append(markers, slog.Bool(TruncatedKey, true))
+	}
+	if dropped += h.dropped; dropped > 0 {
+		markers = append(markers, slog.Int(AttrsDroppedKey, dropped))
+	}
+	if len(markers) == 0 {
+		return h.next.Handle(ctx, nr)
+	}
+	return h.handle(ctx, nr, markers...)
+}
+
+// WithAttrs limits attrs before binding them to the wrapped handler
+func (h *limitHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	limited, truncated, dropped := h.limitAttrs(attrs, h.bound)
+
+	h2 := *h
+	h2.derived = h.withAttrs(limited)
+	h2.dropped += dropped
+	h2.truncated = h.truncated || truncated
+	for _, a := range limited {
+		h2.bound += countLeaves(a)
+	}
+	return &h2
+}
+
+// WithGroup returns a limitHandler wrapping the grouped handler
+func (h *limitHandler) WithGroup(name string) slog.Handler {
+	h2 := *h
+	h2.derived = h.withGroup(name)
+	return &h2
+}
+
+// limitFields applies Options.MaxAttrValueLen and Options.MaxAttrs to the
+// collected fields of a Color record, including the context attributes,
+// and appends the top-level markers. truncated reports whether the
+// message was truncated
+func limitFields(fields []field, maxValue, maxAttrs int, truncated bool) []field {
+	for i := range fields {
+		if s, ok := truncateValue(fields[i].value, maxValue); ok {
+			fields[i].value = s
+			truncated = true
+		}
+	}
+	dropped := 0
+	if maxAttrs > 0 && len(fields) > maxAttrs {
+		dropped = len(fields) - maxAttrs
+		fields = fields[:maxAttrs]
+	}
+
+	if truncated {
+		fields = append(fields, field{name: TruncatedKey, key: TruncatedKey, value: true})
+	}
+	if dropped > 0 {
+		fields = append(fields, field{name: AttrsDroppedKey, key: AttrsDroppedKey, value: int64(dropped)})
+	}
+	return fields
+}
+
+// truncateValue truncates string and []byte field values
+func truncateValue(v any, limit int) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return truncateString(v, limit)
+	case []byte:
+		if limit > 0 && len(v) > limit {
+			return truncateString(string(v[:limit+1]), limit)
+		}
+	}
+	return "", false
+}
+
+// limitAttrs truncates attrs and caps their leaf count so that together
+// with the used leaves already bound it doesn't exceed maxAttrs
+func (h *limitHandler) limitAttrs(attrs []slog.Attr, used int) (limited []slog.Attr, truncated bool, dropped int) {
+	budget := -1
+	if h.maxAttrs > 0 {
+		budget = max(h.maxAttrs-used, 0)
+	}
+
+	limited = make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		a, t := h.truncateAttr(a)
+		truncated = truncated || t
+
+		var d int
+		a, d = capAttr(a, &budget)
+		dropped += d
+		if a.Key != "" {
+			limited = append(limited, a)
+		}
+	}
+	return limited, truncated, dropped
+}
+
+// truncateAttr truncates string and []byte values, descending into groups
+func (h *limitHandler) truncateAttr(a slog.Attr) (slog.Attr, bool) {
+	if h.maxValue <= 0 {
+		return a, false
+	}
+
+	a.Value = a.Value.Resolve()
+	switch a.Value.Kind() {
+	case slog.KindString:
+		s, t := truncateString(a.Value.String(), h.maxValue)
+		if t {
+			a.Value = slog.StringValue(s)
+		}
+		return a, t
+	case slog.KindGroup:
+		group := a.Value.Group()
+		limited := make([]slog.Attr, len(group))
+		truncated := false
+		for i, ga := range group {
+			var t bool
+			limited[i], t = h.truncateAttr(ga)
+			truncated = truncated || t
+		}
+		if truncated {
+			a.Value = slog.GroupValue(limited...)
+		}
+		return a, truncated
+	case slog.KindAny:
+		if b, ok := a.Value.Any().([]byte); ok && len(b) > h.maxValue {
+			s, _ := truncateString(string(b[:h.maxValue+1]), h.maxValue)
+			a.Value = slog.StringValue(s)
+			return a, true
+		}
+	}
+	return a, false
+}
+
+// capAttr keeps at most budget leaf attributes of a, flattening groups.
+// It returns the kept part of a and the number of dropped leaves.
+// A negative budget means unlimited
+func capAttr(a slog.Attr, budget *int) (slog.Attr, int) {
+	if *budget < 0 || a.Key == "" {
+		return a, 0
+	}
+
+	if a.Value.Kind() != slog.KindGroup {
+		if *budget == 0 {
+			return slog.Attr{}, 1
+		}
+		*budget--
+		return a, 0
+	}
+
+	group := a.Value.Group()
+	kept := make([]slog.Attr, 0, len(group))
+	dropped := 0
+	for _, ga := range group {
+		ga, d := capAttr(ga, budget)
+		dropped += d
+		if ga.Key != "" {
+			kept = append(kept, ga)
+		}
+	}
+	if dropped == 0 {
+		return a, 0
+	}
+	if len(kept) == 0 {
+		return slog.Attr{}, dropped
+	}
+	return slog.Attr{Key: a.Key, Value: slog.GroupValue(kept...)}, dropped
+}
+
+// countLeaves returns the number of non-group attributes in a
+func countLeaves(a slog.Attr) int {
+	if a.Key == "" {
+		return 0
+	}
+	if a.Value.Kind() != slog.KindGroup {
+		return 1
+	}
+	n := 0
+	for _, ga := range a.Value.Group() {
+		n += countLeaves(ga)
+	}
+	return n
+}
+
+// truncateString cuts s to at most limit bytes on a rune boundary and
+// appends an ellipsis. A limit <= 0 disables truncation
+func truncateString(s string, limit int) (string, bool) {
+	if limit <= 0 || len(s) <= limit {
+		return s, false
+	}
+
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + ellipsis, true
+}
@@ -2,11 +2,8 @@ package grovelog
 
 import (
 	"context"
-	"encoding/json"
 	"io"
-	stdLog "log"
 	"log/slog"
-	"strings"
 	"sync"
 	"time"
 
@@ -26,37 +23,204 @@ const (
 	Plain
 	// Color format outputs logs with color highlighting
 	Color
+	// Auto format uses Color when the writer is an interactive terminal
+	// and Options.AutoFallback otherwise
+	Auto
+	// MsgPack format outputs each record as a MessagePack map shaped like
+	// the JSON format, which the decode package reads back
+	MsgPack
+	// Protobuf format outputs each record as a size prefixed Record message
+	// of proto/record.proto, which the decode package reads back
+	Protobuf
 )
 
 // DefaultTimeFormat is the default time format
 const DefaultTimeFormat = "[15:05:05.000]"
 
-type colorFn func(format string, a ...any) string
-
-var levelColorMap = map[slog.Level]colorFn{
-	slog.LevelDebug: color.BlueString,
-	slog.LevelInfo:  color.GreenString,
-	slog.LevelWarn:  color.YellowString,
-	slog.LevelError: color.RedString,
-}
-
 // Options holds configuration options for the logger
 type Options struct {
-	SlogOpts   *slog.HandlerOptions
+	// SlogOpts holds the level and source options. Its ReplaceAttr is
+	// applied to the attributes of all formats, and to the built-in time,
+	// level and message attributes of JSON and Plain
+	SlogOpts *slog.HandlerOptions
+	// TimeFormat is the time layout of the Color format. The UnixSeconds,
+	// UnixMillis and UnixNanos sentinels produce numeric timestamps in all formats
 	TimeFormat string
 	Format     Format
+	// AutoFallback is the format used by Auto when the writer isn't a terminal
+	AutoFallback Format
+	// ColorMode forces colors of the Color format on or off per handler,
+	// ColorAuto detects them from the writer and environment
+	ColorMode ColorMode
+
+	// TimeLocation converts record timestamps to the given location,
+	// nil keeps the local time of the host
+	TimeLocation *time.Location
+	// UTC is a shortcut for TimeLocation = time.UTC and takes precedence
+	UTC bool
+
+	// TrimSourcePrefix is trimmed from source file paths when AddSource is
+	// enabled, e.g. the module root, to emit relative paths
+	TrimSourcePrefix string
+	// ShortSource keeps only the package directory and file name of
+	// source file paths, like zap's TrimmedPath
+	ShortSource bool
+
+	// TimeKey, LevelKey and MessageKey rename the built-in attributes,
+	// e.g. to "ts", "severity" and "message". Empty keys keep the slog
+	// defaults. The Color format, which renders these fields without
+	// keys, labels the renamed ones, e.g. "severity=INFO:"
+	TimeKey    string
+	LevelKey   string
+	MessageKey string
+
+	// AllowedKeys enables strict allowlist mode when non-nil: only attributes
+	// whose full key (group names joined with ".") is listed are emitted
+	AllowedKeys []string
+	// ValidateAttr is called with every attribute being encoded, after
+	// SlogOpts.ReplaceAttr. Attributes it returns an error for are dropped,
+	// e.g. to reject forbidden keys, enforce naming conventions or bound
+	// value types. The built-in attributes aren't validated
+	ValidateAttr func(groups []string, a slog.Attr) error
+	// OnDrop is called with the full key of every attribute dropped by
+	// AllowedKeys or ValidateAttr
+	OnDrop func(key string)
+
+	// MaxMessageLen truncates longer messages, 0 means unlimited
+	MaxMessageLen int
+	// MaxAttrValueLen truncates longer string and []byte attribute values,
+	// 0 means unlimited. Records with truncated content get a TruncatedKey=true attribute
+	MaxAttrValueLen int
+	// MaxAttrs caps the number of attributes per record after group
+	// flattening, 0 means unlimited. Records with dropped attributes get
+	// an AttrsDroppedKey=N attribute
+	MaxAttrs int
+
+	// Theme sets the colors of the Color format. When nil, the built-in theme
+	// named by ThemeName or the GROVELOG_THEME environment variable is used,
+	// falling back to DefaultTheme
+	Theme *Theme
+	// ThemeName selects a built-in theme, see ThemeByName
+	ThemeName string
+	// LevelIcons prefixes level labels with glyphs in Color format,
+	// see UnicodeIcons and NerdFontIcons. Nil disables icons
+	LevelIcons LevelIcons
+	// AlignColumns pads level labels and logger names in Color format to
+	// fixed widths so messages line up vertically across records
+	AlignColumns bool
+	// LoggerNameWidth is the width AlignColumns pads the logger name
+	// column to, records without a LoggerKey attribute included. Zero
+	// leaves the names unpadded
+	LoggerNameWidth int
+	// SortKeys emits Color attributes in alphabetical key order
+	// instead of insertion order
+	SortKeys bool
+	// NestGroups renders Color attributes of LayoutIndented and LayoutJSONLine
+	// as nested JSON objects, like the JSON format does, instead of dotted keys
+	NestGroups bool
+	// Layout selects how the Color format renders attributes
+	Layout Layout
+	// Indent is the indentation per nesting level of LayoutExpanded,
+	// two spaces if empty
+	Indent string
+	// ValueFormatter renders attribute values in Color format,
+	// nil means HumanValues
+	ValueFormatter ValueFormatter
+	// Highlights are rules painting selected attribute values in Color format
+	Highlights []HighlightRule
+
+	// ExpandErrors renders error values as a group with the message, the type
+	// and the messages of the unwrap chain instead of a plain string
+	ExpandErrors bool
+	// VerboseErrors adds the %+v rendering of errors implementing
+	// fmt.Formatter, e.g. with stack traces, to expanded errors
+	VerboseErrors bool
+
+	// StandardFields stamps host and process attributes on every record
+	StandardFields *StandardFields
+
+	// AddGoroutineID adds the ID of the logging goroutine as a GoroutineKey
+	// attribute to every record, to correlate records of concurrent code
+	AddGoroutineID bool
+
+	// AddCaller adds the file:line of the call site as a util.CallerKey
+	// attribute to every record. Frames of wrappers registered with
+	// util.SkipCallerPackages are skipped
+	AddCaller bool
+
+	// ErrorLevels lets error attributes implementing util.LevelError set the
+	// record level and adds a "<key>.code" attribute for util.CodedError.
+	// The level can only change for records already enabled at their
+	// original level, see util.ErrLevel to pick the level up front
+	ErrorLevels bool
+
+	// ErrorTracker, when set, fingerprints records at or above Error,
+	// adds a FingerprintKey attribute and counts them per fingerprint
+	ErrorTracker *ErrorTracker
+
+	// Metrics, when set, counts records by level, handler errors, dropped
+	// records and Handle latency. Metrics can be shared by several handlers
+	Metrics *Metrics
+
+	// Expvar, when set, publishes an expvar.Map under this name with the
+	// records handled per level and the bytes written, see ExpvarBytesKey.
+	// Handlers using the same name share the map
+	Expvar string
+
+	// SyncLevel syncs the writer to stable storage after every record at
+	// or above the level, if it has a Sync method like FileWriter and
+	// *os.File. Nil disables level based syncing, see also FileOptions.Sync
+	SyncLevel slog.Leveler
+
+	// StackTraceLevel attaches a stack trace, trimmed of slog and grovelog
+	// frames, as a StackKey attribute to records at or above the level.
+	// Nil disables stack traces
+	StackTraceLevel slog.Leveler
+
+	// BufferSize is the initial capacity of the pooled buffers the Color
+	// format builds records in, 1 KiB if not positive
+	BufferSize int
+	// MaxBufferSize is the largest capacity of buffers returned to the pool,
+	// so a single huge record doesn't pin a large buffer, 64 KiB if not positive
+	MaxBufferSize int
+
+	// DisableEscaping writes Color messages and logger names verbatim
+	// instead of escaping control characters. Use it only for trusted multi-line output.
+	// JSON and Plain always escape as part of their encoding
+	DisableEscaping bool
+}
+
+// boundAttr is an attribute added with WithAttrs
+// together with the groups that were open at that time
+type boundAttr struct {
+	groups []string
+	attr   slog.Attr
 }
 
 // Handler implements the slog.Handler interface with custom formatting
 type Handler struct {
-	opts Options
-	l    *stdLog.Logger
+	opts    Options
+	out     io.Writer
+	outMu   *sync.Mutex // Serializes the writes of the handler and its derivatives
+	replace replaceAttrFunc
+	color   bool
+	theme   *Theme
+
+	labelWidth int // Width of the widest standard level label
 
 	groups []string // Stores the group hierarchy
-	attrs  []slog.Attr
+	attrs  []boundAttr
 
-	bufferPool *sync.Pool
-	mu         sync.RWMutex
+	buffers *bufferPool // Shared with derived handlers
+	mu      sync.RWMutex
+}
+
+// location returns the location timestamps are converted to, or nil
+func (o Options) location() *time.Location { //nolint:gocritic
+	if o.UTC {
+		return time.UTC
+	}
+	return o.TimeLocation
 }
 
 // NewOptions creates Options with the specified level, time format, and output format
@@ -94,21 +258,55 @@ func NewHandler(out io.Writer, opts Options) slog.Handler {
 		opts.TimeFormat = DefaultTimeFormat
 	}
 
-	switch opts.Format {
-	case JSON:
-		return slog.NewJSONHandler(out, opts.SlogOpts)
-	case Plain:
-		return slog.NewTextHandler(out, opts.SlogOpts)
+	h := wrapHandler(newFormatHandler(out, opts), out, opts)
+	h = &verboseHandler{next: h}
+	return &drainHandler{next: h, state: &drainState{out: out}}
+}
+
+// newFormatHandler creates the handler that encodes records in opts.Format
+func newFormatHandler(out io.Writer, opts Options) slog.Handler {
+	format := resolveFormat(out, opts)
+	colored := format == Color && colorEnabled(out, opts.ColorMode)
+	if colored {
+		out = prepareColorWriter(out)
+	}
+	if opts.Expvar != "" {
+		out = &expvarWriter{out: out, m: expvarMap(opts.Expvar)}
+	}
+
+	switch format {
+	case JSON, Plain:
+		so := slogOptions(opts)
+		marked := so.ReplaceAttr != nil
+		var h slog.Handler
+		if format == JSON {
+			h = slog.NewJSONHandler(out, so)
+		} else {
+			so.ReplaceAttr = errorMessages(so.ReplaceAttr)
+			h = slog.NewTextHandler(out, so)
+		}
+		if marked {
+			h = &userKeyHandler{next: h}
+		}
+		return h
+	case MsgPack:
+		return newEncodeHandler(out, opts, encodeMsgPack)
+	case Protobuf:
+		return newEncodeHandler(out, opts, encodeProtobuf)
 	default:
+		profile := detectColorProfile()
+		opts.Theme = resolveTheme(opts).downsample(profile)
+		opts.Highlights = downsampleRules(opts.Highlights, profile)
 		h := &Handler{
-			l:    stdLog.New(out, "", 0),
-			opts: opts,
-			bufferPool: &sync.Pool{
-				New: func() any {
-					return new([]byte)
-				},
-			},
+			out:     out,
+			outMu:   new(sync.Mutex),
+			opts:    opts,
+			replace: buildReplaceAttr(opts),
+			color:   colored,
+			theme:   opts.Theme,
+			buffers: newBufferPool(opts.BufferSize, opts.MaxBufferSize),
 		}
+		h.labelWidth = h.levelWidth()
 		return h
 	}
 }
@@ -124,74 +322,64 @@ func (h *Handler) Handle(ctx context.Context, r slog.Record) error { //nolint:go
 	}
 
 	timeStr := h.formatTime(r.Time)
-	logMsg := r.Message
-	formatLevel := r.Level.String() + ":"
-	fields := h.collectFields(r)
-
-	var output string
-	if len(fields) > 0 {
-		jsonOutput, err := h.marshalFields(fields)
-		if err != nil {
-			return err
-		}
-		output = string(jsonOutput)
+	logMsg, truncated := truncateString(r.Message, h.opts.MaxMessageLen)
+	if !h.opts.DisableEscaping {
+		logMsg = escapeControl(logMsg)
 	}
+	formatLevel := h.levelLabel(r.Level)
+	name, fields, nameTruncated := h.loggerName(h.collectFields(r))
+	fields = limitFields(fields, h.opts.MaxAttrValueLen, h.opts.MaxAttrs, truncated || nameTruncated)
 
-	levelColorFunc, ok := levelColorMap[r.Level]
+	levelStyle, ok := h.theme.Levels[r.Level]
 	if !ok {
-		levelColorFunc = color.WhiteString // Default color for unknown levels
+		levelStyle = Style{color.FgWhite} // Default color for unknown levels
 	}
 
-	level := levelColorFunc(formatLevel)
-	msg := color.CyanString(logMsg)
-	atrs := color.WhiteString(output)
-
-	h.l.Println(timeStr, level, msg, atrs)
-	return nil
-}
-
-type jsonWriter struct {
-	buf *[]byte
-}
-
-func (w *jsonWriter) Write(p []byte) (n int, err error) {
-	*w.buf = append(*w.buf, p...)
-	return len(p), nil
-}
-
-// marshalFields optimizes JSON serialization of fields
-func (h *Handler) marshalFields(fields map[string]any) ([]byte, error) {
-	if h.bufferPool != nil {
-		bufPtr, ok := h.bufferPool.Get().(*[]byte)
-		if !ok || bufPtr == nil {
-			return json.MarshalIndent(fields, "", "  ")
-		}
-
-		*bufPtr = (*bufPtr)[:0]
-
-		encoder := json.NewEncoder(&jsonWriter{buf: bufPtr})
-		encoder.SetIndent("", "  ")
-
-		err := encoder.Encode(fields)
-		jsonData := *bufPtr
+	timeStr = h.builtinLabel(h.opts.TimeKey) + h.paint(h.theme.Time, timeStr)
+	level := h.builtinLabel(h.opts.LevelKey) + h.paint(levelStyle, formatLevel) + h.levelPadding(formatLevel)
+	msg := h.builtinLabel(h.opts.MessageKey) + h.paint(h.theme.Message, logMsg)
+	if h.opts.SlogOpts.AddSource && r.PC != 0 {
+		msg += h.sourceSuffix(r.PC)
+	}
 
-		if err != nil {
-			h.bufferPool.Put(bufPtr)
-			return nil, err
+	// Build the whole line in a pooled buffer to write it with a single call
+	buf := h.buffers.get()
+	defer h.buffers.put(buf)
+
+	line := append((*buf)[:0], timeStr...)
+	line = append(line, ' ')
+	line = append(line, level...)
+	line = append(line, ' ')
+	if name != "" {
+		line = append(line, name...)
+		line = append(line, ' ')
+	}
+	line = append(line, msg...)
+	if len(fields) > 0 {
+		if h.opts.Layout != LayoutExpanded { // Expanded attributes start on their own lines
+			line = append(line, ' ')
 		}
-
-		if len(jsonData) > 0 && jsonData[len(jsonData)-1] == '\n' {
-			jsonData = jsonData[:len(jsonData)-1]
+		var err error
+		if line, err = h.appendFields(line, fields); err != nil {
+			return err
 		}
+	}
+	line = append(line, '\n')
+	*buf = line
 
-		result := make([]byte, len(jsonData))
-		copy(result, jsonData)
+	h.outMu.Lock()
+	defer h.outMu.Unlock()
+	_, err := h.out.Write(line)
+	return err
+}
 
-		h.bufferPool.Put(bufPtr)
-		return result, nil
+// builtinLabel returns the "key=" label of a built-in field renamed to
+// key, nothing if it isn't renamed
+func (h *Handler) builtinLabel(key string) string {
+	if key == "" {
+		return ""
 	}
-
-	return json.MarshalIndent(fields, "", "  ")
+	return h.paint(h.theme.Key, key+"=")
 }
 
 func (h *Handler) formatTime(t time.Time) string {
@@ -202,47 +390,67 @@ func (h *Handler) formatTime(t time.Time) string {
 	if format == "" {
 		format = DefaultTimeFormat
 	}
+	if loc := h.opts.location(); loc != nil {
+		t = t.In(loc)
+	}
 
-	return t.Format(format)
+	return formatTimestamp(t, format)
 }
 
-func (h *Handler) collectFields(r slog.Record) map[string]any { //nolint:gocritic
-	fields := make(map[string]any, r.NumAttrs()+len(h.attrs))
+// collectFields flattens the handler and record attributes in insertion
+// order. A repeated key keeps its first position and its last value
+func (h *Handler) collectFields(r slog.Record) []field { //nolint:gocritic
+	fields := make([]field, 0, r.NumAttrs()+len(h.attrs))
+	index := make(map[string]int, cap(fields))
 
 	h.mu.RLock()
-	groupPrefix := ""
-	if len(h.groups) > 0 {
-		groupPrefix = strings.Join(h.groups, ".") + "."
-	}
-
-	var processAttr func(a slog.Attr, prefix string)
-	processAttr = func(a slog.Attr, prefix string) {
+	var processAttr func(a slog.Attr, groups []string)
+	processAttr = func(a slog.Attr, groups []string) {
 		if a.Key == "" {
 			return
 		}
-
-		fullKey := prefix + a.Key
+		a.Value = a.Value.Resolve()
 
 		if a.Value.Kind() == slog.KindGroup {
-			group := a.Value.Group()
-			for _, groupAttr := range group {
+			nested := append(slices.Clip(groups), a.Key)
+			for _, groupAttr := range a.Value.Group() {
 				if groupAttr.Key != "" {
-					processAttr(groupAttr, fullKey+".")
+					processAttr(groupAttr, nested)
 				}
 			}
-		} else {
-			fields[fullKey] = a.Value.Any()
+			return
+		}
+
+		if h.replace != nil {
+			if a = h.replace(groups, markUserAttr(groups, a)); a.Key == "" {
+				return
+			}
+			if a.Value.Kind() == slog.KindGroup {
+				nested := append(slices.Clip(groups), a.Key)
+				for _, groupAttr := range a.Value.Group() {
+					processAttr(groupAttr, nested)
+				}
+				return
+			}
 		}
+
+		f := field{name: joinKey(groups, a.Key), groups: groups, key: a.Key, value: a.Value.Any()}
+		if i, ok := index[f.name]; ok {
+			fields[i] = f
+			return
+		}
+		index[f.name] = len(fields)
+		fields = append(fields, f)
+	}
+
+	for _, b := range h.attrs {
+		processAttr(b.attr, b.groups)
 	}
 
 	r.Attrs(func(a slog.Attr) bool {
-		processAttr(a, groupPrefix)
+		processAttr(a, h.groups)
 		return true
 	})
-
-	for _, a := range h.attrs {
-		processAttr(a, groupPrefix)
-	}
 	h.mu.RUnlock()
 
 	return fields
@@ -262,10 +470,10 @@ func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
 
 // WithAttrs returns a new Handler with the given attributes added
 func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
-	validAttrs := make([]slog.Attr, 0, len(attrs))
+	validAttrs := make([]boundAttr, 0, len(attrs))
 	for _, attr := range attrs {
 		if attr.Key != "" {
-			validAttrs = append(validAttrs, attr)
+			validAttrs = append(validAttrs, boundAttr{groups: h.groups, attr: attr})
 		}
 	}
 
@@ -277,11 +485,17 @@ func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
 	defer h.mu.RUnlock()
 
 	return &Handler{
-		l:          h.l,
-		opts:       h.opts,
-		groups:     slices.Clone(h.groups),
-		bufferPool: h.bufferPool,
-		attrs:      slices.Concat(slices.Clone(h.attrs), validAttrs),
+		out:     h.out,
+		outMu:   h.outMu,
+		opts:    h.opts,
+		replace: h.replace,
+		color:   h.color,
+		theme:   h.theme,
+		groups:  slices.Clone(h.groups),
+		buffers: h.buffers,
+		attrs:   slices.Concat(slices.Clone(h.attrs), validAttrs),
+
+		labelWidth: h.labelWidth,
 	}
 }
 
@@ -296,11 +510,17 @@ func (h *Handler) WithGroup(name string) slog.Handler {
 
 	// Create a new handler with the same attributes but a new group
 	newHandler := &Handler{
-		l:          h.l,
-		opts:       h.opts,
-		attrs:      slices.Clone(h.attrs),
-		groups:     append(slices.Clone(h.groups), name),
-		bufferPool: h.bufferPool,
+		out:     h.out,
+		outMu:   h.outMu,
+		opts:    h.opts,
+		replace: h.replace,
+		color:   h.color,
+		theme:   h.theme,
+		attrs:   slices.Clone(h.attrs),
+		groups:  append(slices.Clone(h.groups), name),
+		buffers: h.buffers,
+
+		labelWidth: h.labelWidth,
 	}
 
 	return newHandler
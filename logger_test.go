@@ -3,16 +3,34 @@ package grovelog_test
 import (
 	"bytes"
 	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
+	"expvar"
+	"flag"
+	"fmt"
 	"io"
+	"log"
 	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"regexp"
+	"runtime"
+	"slices"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/AlonMell/grovelog"
+	"github.com/AlonMell/grovelog/decode"
+	"github.com/AlonMell/grovelog/util"
+	"github.com/fatih/color"
 )
 
 // TestNewLogger tests the creation of loggers with different formats
@@ -485,3 +503,3277 @@ func TestJSONFormat(t *testing.T) {
 		t.Errorf("Expected key field to be 'value', got %v", jsonMap["key"])
 	}
 }
+
+// TestAllowedKeys tests that strict allowlist mode drops unlisted attributes
+func TestAllowedKeys(t *testing.T) {
+	for _, format := range []grovelog.Format{grovelog.JSON, grovelog.Plain, grovelog.Color, grovelog.MsgPack} {
+		var buf bytes.Buffer
+		var dropped []string
+
+		opts := grovelog.NewOptions(slog.LevelInfo, "", format)
+		opts.AllowedKeys = []string{"user_id", "http.method"}
+		opts.OnDrop = func(key string) { dropped = append(dropped, key) }
+		logger := grovelog.NewLogger(&buf, opts)
+
+		// User attributes named like the built-ins aren't exempt
+		logger.With("msg", "spoofed").Info("allowlist", "user_id", 42, "password", "hunter2", "level", "forged",
+			slog.Group("http", slog.String("method", "GET"), slog.String("token", "abc")))
+
+		logOutput := buf.String()
+		if !strings.Contains(logOutput, "user_id") || !strings.Contains(logOutput, "GET") {
+			t.Errorf("format %d: allowed attributes missing. Got: %s", format, logOutput)
+		}
+		for _, value := range []string{"hunter2", "abc", "spoofed", "forged"} {
+			if strings.Contains(logOutput, value) {
+				t.Errorf("format %d: unlisted attribute %s emitted. Got: %s", format, value, logOutput)
+			}
+		}
+		if !strings.Contains(logOutput, "allowlist") || !strings.Contains(logOutput, "INFO") {
+			t.Errorf("format %d: built-in attributes dropped. Got: %s", format, logOutput)
+		}
+		if len(dropped) != 4 {
+			t.Errorf("format %d: expected 4 dropped keys, got %v", format, dropped)
+		}
+	}
+}
+
+// TestReplaceAttr tests that SlogOpts.ReplaceAttr reaches every format
+func TestReplaceAttr(t *testing.T) {
+	for _, format := range []grovelog.Format{grovelog.JSON, grovelog.Plain, grovelog.Color} {
+		var buf bytes.Buffer
+		opts := grovelog.NewOptions(slog.LevelInfo, "", format)
+		opts.SlogOpts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+			switch {
+			case a.Key == "password":
+				return slog.Attr{}
+			case len(groups) > 0 && a.Key == "id":
+				a.Key = "user_id"
+			}
+			return a
+		}
+		grovelog.NewLogger(&buf, opts).Info("replaced", "password", "hunter2", slog.Group("user", slog.Int("id", 7)))
+
+		logOutput := buf.String()
+		if strings.Contains(logOutput, "hunter2") || !strings.Contains(logOutput, "user_id") {
+			t.Errorf("format %d: ReplaceAttr not applied. Got: %s", format, logOutput)
+		}
+	}
+}
+
+// TestValidateAttr tests dropping attributes rejected by Options.ValidateAttr
+func TestValidateAttr(t *testing.T) {
+	snakeCase := regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+	for _, format := range []grovelog.Format{grovelog.JSON, grovelog.Plain, grovelog.Color} {
+		var buf bytes.Buffer
+		var dropped []string
+
+		opts := grovelog.NewOptions(slog.LevelInfo, "", format)
+		opts.ValidateAttr = func(groups []string, a slog.Attr) error {
+			if !snakeCase.MatchString(a.Key) {
+				return fmt.Errorf("key %q isn't snake case", a.Key)
+			}
+			if a.Value.Kind() == slog.KindAny {
+				return fmt.Errorf("key %q has an unbounded value type", a.Key)
+			}
+			return nil
+		}
+		opts.OnDrop = func(key string) { dropped = append(dropped, key) }
+		logger := grovelog.NewLogger(&buf, opts)
+
+		logger.Info("validated", "user_id", 42, "userName", "mallory",
+			slog.Group("http", slog.String("method", "GET"), slog.Any("body", map[string]string{"k": "secret"})),
+			slog.Any("source", map[string]string{"file": "forged.go"}))
+
+		logOutput := buf.String()
+		if !strings.Contains(logOutput, "user_id") || !strings.Contains(logOutput, "GET") {
+			t.Errorf("format %d: valid attributes missing. Got: %s", format, logOutput)
+		}
+		if strings.Contains(logOutput, "mallory") || strings.Contains(logOutput, "secret") || strings.Contains(logOutput, "forged") {
+			t.Errorf("format %d: invalid attributes emitted. Got: %s", format, logOutput)
+		}
+		if !slices.Equal(dropped, []string{"userName", "http.body", "source"}) {
+			t.Errorf("format %d: expected userName, http.body and source dropped, got %v", format, dropped)
+		}
+	}
+}
+
+// TestControlCharEscaping tests that control characters can't forge log lines
+func TestControlCharEscaping(t *testing.T) {
+	for _, format := range []grovelog.Format{grovelog.JSON, grovelog.Plain, grovelog.Color} {
+		var buf bytes.Buffer
+		opts := grovelog.NewOptions(slog.LevelInfo, "", format)
+		logger := grovelog.NewLogger(&buf, opts)
+
+		logger.Info("login ok\n[00:00:00.000] INFO: forged\x1b[31m", "user", "eve\r\nadmin")
+
+		logOutput := buf.String()
+		if strings.Contains(logOutput, "\n[00:00:00.000]") || strings.Contains(logOutput, "\r") ||
+			strings.Contains(logOutput, "\nadmin") {
+			t.Errorf("format %d: raw line break in output. Got: %q", format, logOutput)
+		}
+		if strings.Contains(logOutput, "\x1b[31m") {
+			t.Errorf("format %d: raw ANSI escape in output. Got: %q", format, logOutput)
+		}
+	}
+
+	var buf bytes.Buffer
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.Color)
+	opts.DisableEscaping = true
+	grovelog.NewLogger(&buf, opts).Info("line1\nline2")
+	if !strings.Contains(buf.String(), "line1\nline2") {
+		t.Errorf("DisableEscaping should keep multi-line messages. Got: %q", buf.String())
+	}
+}
+
+// TestTruncation tests message and attribute value truncation limits
+func TestTruncation(t *testing.T) {
+	var buf bytes.Buffer
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON)
+	opts.MaxMessageLen = 5
+	opts.MaxAttrValueLen = 4
+	logger := grovelog.NewLogger(&buf, opts)
+
+	logger.Info("hello world", "body", bytes.Repeat([]byte("x"), 1<<20), "short", "ok")
+
+	var jsonMap map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &jsonMap); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	if jsonMap["msg"] != "hello…" {
+		t.Errorf("Expected truncated message, got %v", jsonMap["msg"])
+	}
+	if jsonMap["body"] != "xxxx…" {
+		t.Errorf("Expected truncated body, got %v", jsonMap["body"])
+	}
+	if jsonMap["short"] != "ok" {
+		t.Errorf("Expected short value untouched, got %v", jsonMap["short"])
+	}
+	if jsonMap[grovelog.TruncatedKey] != true {
+		t.Errorf("Expected %s marker, got %v", grovelog.TruncatedKey, jsonMap)
+	}
+
+	buf.Reset()
+	logger.Info("short")
+	if strings.Contains(buf.String(), grovelog.TruncatedKey) {
+		t.Errorf("Unexpected truncation marker. Got: %s", buf.String())
+	}
+}
+
+// TestMaxAttrs tests the per record attribute cap
+func TestMaxAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON)
+	opts.MaxAttrs = 3
+	logger := grovelog.NewLogger(&buf, opts).With("preset", 1)
+
+	logger.Info("wide record", "a", 1,
+		slog.Group("g", slog.Int("b", 2), slog.Int("c", 3), slog.Int("d", 4)), "e", 5)
+
+	var jsonMap map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &jsonMap); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	if jsonMap["preset"] == nil || jsonMap["a"] == nil {
+		t.Errorf("Expected first attributes to be kept, got %v", jsonMap)
+	}
+	group, _ := jsonMap["g"].(map[string]any)
+	if len(group) != 1 || group["b"] == nil {
+		t.Errorf("Expected group trimmed to one attribute, got %v", jsonMap["g"])
+	}
+	if _, ok := jsonMap["e"]; ok {
+		t.Errorf("Expected attribute e to be dropped, got %v", jsonMap)
+	}
+	if jsonMap[grovelog.AttrsDroppedKey] != float64(3) {
+		t.Errorf("Expected %s=3, got %v", grovelog.AttrsDroppedKey, jsonMap[grovelog.AttrsDroppedKey])
+	}
+}
+
+// TestLimitMarkers tests that the limit markers stay at the top level
+// and that the Color format limits the context attributes too
+func TestLimitMarkers(t *testing.T) {
+	var buf bytes.Buffer
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON)
+	opts.MaxAttrValueLen = 4
+	opts.MaxAttrs = 1
+	grovelog.NewLogger(&buf, opts).WithGroup("req").Info("grouped", "path", "/users", "id", 7)
+
+	var jsonMap map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &jsonMap); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	group, _ := jsonMap["req"].(map[string]any)
+	if len(group) != 1 || group["path"] != "/use…" {
+		t.Errorf("Expected the limited group, got %v", jsonMap["req"])
+	}
+	if jsonMap[grovelog.TruncatedKey] != true || jsonMap[grovelog.AttrsDroppedKey] != float64(1) {
+		t.Errorf("Expected top-level markers, got %v", jsonMap)
+	}
+
+	buf.Reset()
+	opts.Format = grovelog.Color
+	opts.ColorMode = grovelog.ColorNever
+	opts.Layout = grovelog.LayoutKeyValue
+	ctx := util.WithLogAttrs(context.Background(), slog.String("trace", "0123456789"), slog.Int("shard", 7))
+	grovelog.NewLogger(&buf, opts).InfoContext(ctx, "context")
+	out := buf.String()
+	if !strings.Contains(out, "trace=0123…") || strings.Contains(out, "shard") {
+		t.Errorf("Expected limited context attributes, got %s", out)
+	}
+	if !strings.Contains(out, grovelog.TruncatedKey+"=true") || !strings.Contains(out, grovelog.AttrsDroppedKey+"=1") {
+		t.Errorf("Expected markers, got %s", out)
+	}
+}
+
+// TestColorEnv tests the NO_COLOR and FORCE_COLOR conventions
+func TestColorEnv(t *testing.T) {
+	tests := []struct {
+		name      string
+		noColor   string
+		force     string
+		wantColor bool
+	}{
+		{name: "ForceColor", force: "1", wantColor: true},
+		{name: "NoColor", noColor: "1", wantColor: false},
+		{name: "NoColorWins", noColor: "1", force: "1", wantColor: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NO_COLOR", tt.noColor)
+			t.Setenv("FORCE_COLOR", tt.force)
+
+			var buf bytes.Buffer
+			opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.Color)
+			grovelog.NewLogger(&buf, opts).Info("colors")
+
+			if got := strings.Contains(buf.String(), "\x1b["); got != tt.wantColor {
+				t.Errorf("Expected color=%v. Got: %q", tt.wantColor, buf.String())
+			}
+		})
+	}
+}
+
+// TestColorMode tests that handlers in one process decide colors independently
+func TestColorMode(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+
+	var colored, plain bytes.Buffer
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.Color)
+	opts.ColorMode = grovelog.ColorAlways
+	grovelog.NewLogger(&colored, opts).Info("colors")
+	opts.ColorMode = grovelog.ColorNever
+	grovelog.NewLogger(&plain, opts).Info("colors")
+
+	if !strings.Contains(colored.String(), "\x1b[32mINFO:") || !strings.HasSuffix(colored.String(), "\x1b[0m\n") {
+		t.Errorf("Expected colors with ColorAlways. Got: %q", colored.String())
+	}
+	if strings.Contains(plain.String(), "\x1b[") {
+		t.Errorf("Expected no colors with ColorNever. Got: %q", plain.String())
+	}
+}
+
+// TestAutoFormat tests that Auto falls back for non-terminal writers
+func TestAutoFormat(t *testing.T) {
+	var buf bytes.Buffer
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.Auto)
+	grovelog.NewLogger(&buf, opts).Info("auto", "key", "value")
+
+	var jsonMap map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &jsonMap); err != nil {
+		t.Fatalf("Expected JSON fallback, got %s", buf.String())
+	}
+
+	buf.Reset()
+	opts.AutoFallback = grovelog.Plain
+	grovelog.NewLogger(&buf, opts).Info("auto", "key", "value")
+	if !strings.Contains(buf.String(), `msg=auto key=value`) {
+		t.Errorf("Expected Plain fallback, got %s", buf.String())
+	}
+}
+
+// TestTheme tests that a custom theme changes the Color output
+func TestTheme(t *testing.T) {
+	t.Setenv("FORCE_COLOR", "1")
+
+	var buf bytes.Buffer
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.Color)
+	opts.Theme = &grovelog.Theme{
+		Levels:  map[slog.Level]grovelog.Style{slog.LevelInfo: {color.FgMagenta}},
+		Message: grovelog.Style{color.Bold},
+		Key:     grovelog.Style{color.FgBlue},
+	}
+	grovelog.NewLogger(&buf, opts).Info("themed", "key", "value")
+
+	logOutput := buf.String()
+	for _, want := range []string{"\x1b[35mINFO:", "\x1b[1mthemed", "\x1b[34m\"key\"", `: "value"`} {
+		if !strings.Contains(logOutput, want) {
+			t.Errorf("Expected output to contain %q. Got: %q", want, logOutput)
+		}
+	}
+}
+
+// TestThemePresets tests built-in themes and their color depth fallback
+func TestThemePresets(t *testing.T) {
+	tests := []struct {
+		name      string
+		colorTerm string
+		term      string
+		want      string
+	}{
+		{name: "TrueColor", colorTerm: "truecolor", want: "\x1b[38;2;80;250;123mINFO:"},
+		{name: "256Color", term: "xterm-256color", want: "\x1b[38;5;120mINFO:"},
+		{name: "Basic", term: "xterm", want: "\x1b[36mINFO:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("FORCE_COLOR", "1")
+			t.Setenv("COLORTERM", tt.colorTerm)
+			t.Setenv("TERM", tt.term)
+			t.Setenv(grovelog.ThemeEnv, grovelog.ThemeDracula)
+
+			var buf bytes.Buffer
+			opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.Color)
+			grovelog.NewLogger(&buf, opts).Info("preset")
+
+			if !strings.Contains(buf.String(), tt.want) {
+				t.Errorf("Expected output to contain %q. Got: %q", tt.want, buf.String())
+			}
+		})
+	}
+
+	if _, ok := grovelog.ThemeByName("no-such-theme"); ok {
+		t.Error("Expected unknown theme name to be rejected")
+	}
+}
+
+// TestHighlightRules tests per key highlighting in Color output
+func TestHighlightRules(t *testing.T) {
+	t.Setenv("FORCE_COLOR", "1")
+
+	var buf bytes.Buffer
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.Color)
+	opts.Highlights = []grovelog.HighlightRule{
+		{Key: grovelog.KeyIs("error"), Style: grovelog.Style{color.FgRed}},
+		{
+			Key:   grovelog.KeyIs("duration_ms"),
+			Match: func(v slog.Value) bool { return v.Kind() == slog.KindInt64 && v.Int64() > 500 },
+			Style: grovelog.Style{color.FgYellow},
+		},
+	}
+	logger := grovelog.NewLogger(&buf, opts)
+
+	logger.Info("slow", "error", "boom", "duration_ms", 900)
+	if !strings.Contains(buf.String(), "\x1b[31m\"boom\"") || !strings.Contains(buf.String(), "\x1b[33m900") {
+		t.Errorf("Expected highlighted values. Got: %q", buf.String())
+	}
+
+	buf.Reset()
+	logger.Info("fast", "duration_ms", 10)
+	if strings.Contains(buf.String(), "\x1b[33m") {
+		t.Errorf("Expected fast duration not to be highlighted. Got: %q", buf.String())
+	}
+}
+
+// TestLevelIcons tests the level icon prefixes of Color output
+func TestLevelIcons(t *testing.T) {
+	var buf bytes.Buffer
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.Color)
+	opts.LevelIcons = grovelog.UnicodeIcons()
+	logger := grovelog.NewLogger(&buf, opts)
+
+	logger.Warn("careful")
+	if !strings.Contains(buf.String(), "⚠ WARN:") {
+		t.Errorf("Expected warning icon. Got: %s", buf.String())
+	}
+
+	buf.Reset()
+	opts.LevelIcons = nil
+	grovelog.NewLogger(&buf, opts).Warn("careful")
+	if strings.Contains(buf.String(), "⚠") {
+		t.Errorf("Expected no icon when disabled. Got: %s", buf.String())
+	}
+}
+
+// TestAlignColumns tests that messages line up across levels
+func TestAlignColumns(t *testing.T) {
+	var buf bytes.Buffer
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.Color)
+	opts.AlignColumns = true
+	logger := grovelog.NewLogger(&buf, opts)
+
+	logger.Info("message")
+	logger.Error("message")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || strings.Index(lines[0], "message") != strings.Index(lines[1], "message") {
+		t.Errorf("Expected aligned messages. Got:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	opts.LoggerNameWidth = 8
+	opts.Layout = grovelog.LayoutKeyValue
+	logger = grovelog.NewLogger(&buf, opts)
+	logger.With(grovelog.LoggerKey, "db").Info("message", "rows", 3)
+	logger.With(grovelog.LoggerKey, "http").Warn("message")
+	logger.Error("message")
+
+	lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[0], " db ") || strings.Contains(lines[0], grovelog.LoggerKey) {
+		t.Fatalf("Expected the logger name column. Got:\n%s", buf.String())
+	}
+	for _, line := range lines[1:] {
+		if strings.Index(line, "message") != strings.Index(lines[0], "message") {
+			t.Errorf("Expected aligned messages with logger names. Got:\n%s", buf.String())
+		}
+	}
+
+	buf.Reset()
+	opts.MaxAttrValueLen = 4
+	logger = grovelog.NewLogger(&buf, opts)
+	logger.With(grovelog.LoggerKey, "a\x1b[2Jpool").Info("message")
+	if out := buf.String(); strings.Contains(out, "\x1b") || !strings.Contains(out, ` a\x1b[2… message`) {
+		t.Errorf("Expected an escaped and truncated logger name. Got: %q", out)
+	}
+
+	buf.Reset()
+	opts.AlignColumns = false
+	logger = grovelog.NewLogger(&buf, opts)
+	logger.With(grovelog.LoggerKey, "db").Info("message")
+	if out := buf.String(); !strings.Contains(out, "message logger=db") {
+		t.Errorf("Expected the logger name as an attribute without AlignColumns. Got: %q", out)
+	}
+}
+
+// TestLayouts tests the single line attribute layouts of Color output
+func TestLayouts(t *testing.T) {
+	tests := []struct {
+		name   string
+		layout grovelog.Layout
+		want   string
+	}{
+		{name: "KeyValue", layout: grovelog.LayoutKeyValue, want: `msg a=1 b="x y" d=1.5s s=[1,2] g.k=v`},
+		{name: "JSONLine", layout: grovelog.LayoutJSONLine, want: `msg {"a":1,"b":"x y","d":"1.5s","s":[1,2],"g.k":"v"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.Color)
+			opts.Layout = tt.layout
+			grovelog.NewLogger(&buf, opts).Info("msg", "a", 1, "b", "x y",
+				"d", 1500*time.Millisecond, "s", []int{1, 2}, slog.Group("g", "k", "v"))
+
+			logOutput := buf.String()
+			if strings.Count(logOutput, "\n") != 1 || !strings.Contains(logOutput, tt.want) {
+				t.Errorf("Expected single line containing %q. Got: %q", tt.want, logOutput)
+			}
+		})
+	}
+}
+
+// TestExpandedLayout tests the multi-line indented layout of Color output
+func TestExpandedLayout(t *testing.T) {
+	var buf bytes.Buffer
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.Color)
+	opts.Layout = grovelog.LayoutExpanded
+	grovelog.NewLogger(&buf, opts).WithGroup("api").Info("request",
+		"method", "GET", slog.Group("user", "id", 42, "name", "Jane Doe"))
+
+	want := "request\n" +
+		"  api:\n" +
+		"    method: GET\n" +
+		"    user:\n" +
+		"      id: 42\n" +
+		"      name: \"Jane Doe\"\n"
+	if !strings.HasSuffix(buf.String(), want) {
+		t.Errorf("Expected expanded layout:\n%s\nGot:\n%s", want, buf.String())
+	}
+}
+
+// TestSortKeys tests insertion and alphabetical ordering of Color attributes
+func TestSortKeys(t *testing.T) {
+	var buf bytes.Buffer
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.Color)
+	opts.Layout = grovelog.LayoutKeyValue
+	logger := grovelog.NewLogger(&buf, opts).With("preset", 0)
+
+	logger.Info("order", "zeta", 1, "alpha", 2, "mid", 3)
+	if !strings.Contains(buf.String(), "order preset=0 zeta=1 alpha=2 mid=3") {
+		t.Errorf("Expected insertion order. Got: %s", buf.String())
+	}
+
+	buf.Reset()
+	opts.SortKeys = true
+	grovelog.NewLogger(&buf, opts).With("preset", 0).Info("order", "zeta", 1, "alpha", 2, "mid", 3)
+	if !strings.Contains(buf.String(), "order alpha=2 mid=3 preset=0 zeta=1") {
+		t.Errorf("Expected sorted order. Got: %s", buf.String())
+	}
+}
+
+// TestNestGroups tests that nested Color attributes match the JSON format shape
+func TestNestGroups(t *testing.T) {
+	var jsonBuf, colorBuf bytes.Buffer
+	log := func(logger *slog.Logger) {
+		logger.With("service", "api").WithGroup("http").With("method", "GET").
+			Info("request", slog.Group("user", "id", 42), "status", 200)
+	}
+
+	log(grovelog.NewLogger(&jsonBuf, grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON)))
+
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.Color)
+	opts.Layout = grovelog.LayoutJSONLine
+	opts.NestGroups = true
+	log(grovelog.NewLogger(&colorBuf, opts))
+
+	var want map[string]any
+	if err := json.Unmarshal(jsonBuf.Bytes(), &want); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	delete(want, "time")
+	delete(want, "level")
+	delete(want, "msg")
+
+	line := colorBuf.String()
+	var got map[string]any
+	if err := json.Unmarshal([]byte(line[strings.Index(line, "{"):]), &got); err != nil {
+		t.Fatalf("Failed to parse Color attributes: %v\n%s", err, line)
+	}
+
+	wantJSON, _ := json.Marshal(want)
+	gotJSON, _ := json.Marshal(got)
+	if !bytes.Equal(wantJSON, gotJSON) {
+		t.Errorf("Shapes differ.\nJSON:  %s\nColor: %s", wantJSON, gotJSON)
+	}
+
+	colorBuf.Reset()
+	opts.Layout = grovelog.LayoutIndented
+	log(grovelog.NewLogger(&colorBuf, opts))
+	if !strings.Contains(colorBuf.String(), "\"http\": {\n    \"method\": \"GET\",") {
+		t.Errorf("Expected indented nested groups. Got:\n%s", colorBuf.String())
+	}
+}
+
+// TestBuiltinKeys tests renaming of the time, level and message keys
+func TestBuiltinKeys(t *testing.T) {
+	var buf bytes.Buffer
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON)
+	opts.TimeKey, opts.LevelKey, opts.MessageKey = "ts", "severity", "message"
+	grovelog.NewLogger(&buf, opts).With("level", "user").WithGroup("g").Info("renamed", "msg", "kept")
+
+	var jsonMap map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &jsonMap); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	for _, key := range []string{"ts", "severity", "message"} {
+		if _, ok := jsonMap[key]; !ok {
+			t.Errorf("Expected key %q in %v", key, jsonMap)
+		}
+	}
+	if group, _ := jsonMap["g"].(map[string]any); group["msg"] != "kept" {
+		t.Errorf("Expected grouped msg attribute to keep its key, got %v", jsonMap["g"])
+	}
+	if jsonMap["level"] != "user" || jsonMap["severity"] != "INFO" {
+		t.Errorf("Expected the user level attribute to keep its key, got %v", jsonMap)
+	}
+
+	buf.Reset()
+	opts.Format = grovelog.Plain
+	grovelog.NewLogger(&buf, opts).Info("renamed")
+	if !strings.Contains(buf.String(), "severity=INFO message=renamed") {
+		t.Errorf("Expected renamed Plain keys. Got: %s", buf.String())
+	}
+
+	buf.Reset()
+	opts.Format = grovelog.Color
+	opts.Layout = grovelog.LayoutKeyValue
+	grovelog.NewLogger(&buf, opts).Info("renamed", "msg", "kept")
+	if !strings.HasPrefix(buf.String(), "ts=[") || !strings.Contains(buf.String(), "severity=INFO: message=renamed msg=kept") {
+		t.Errorf("Expected renamed Color labels. Got: %s", buf.String())
+	}
+}
+
+// TestTimeLocation tests timestamp normalization to a fixed location
+func TestTimeLocation(t *testing.T) {
+	var buf bytes.Buffer
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON)
+	opts.TimeLocation = time.FixedZone("UTC+5", 5*60*60)
+	grovelog.NewLogger(&buf, opts).Info("zoned")
+	if !strings.Contains(buf.String(), `+05:00"`) {
+		t.Errorf("Expected +05:00 offset. Got: %s", buf.String())
+	}
+
+	buf.Reset()
+	opts.UTC = true
+	grovelog.NewLogger(&buf, opts).Info("utc")
+	if !strings.Contains(buf.String(), `Z"`) {
+		t.Errorf("Expected UTC timestamp. Got: %s", buf.String())
+	}
+
+	buf.Reset()
+	opts.Format = grovelog.Color
+	opts.TimeFormat = "-07:00"
+	grovelog.NewLogger(&buf, opts).Info("utc")
+	if !strings.HasPrefix(buf.String(), "+00:00") {
+		t.Errorf("Expected UTC offset in Color output. Got: %s", buf.String())
+	}
+}
+
+// TestEpochTimeFormat tests numeric epoch timestamps
+func TestEpochTimeFormat(t *testing.T) {
+	var buf bytes.Buffer
+	opts := grovelog.NewOptions(slog.LevelInfo, grovelog.UnixMillis, grovelog.JSON)
+	before := time.Now().UnixMilli()
+	grovelog.NewLogger(&buf, opts).Info("epoch")
+
+	var jsonMap map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &jsonMap); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	ts, ok := jsonMap["time"].(float64)
+	if !ok || int64(ts) < before || int64(ts) > time.Now().UnixMilli() {
+		t.Errorf("Expected numeric millisecond timestamp, got %v", jsonMap["time"])
+	}
+
+	buf.Reset()
+	opts = grovelog.NewOptions(slog.LevelInfo, grovelog.UnixSeconds, grovelog.Color)
+	grovelog.NewLogger(&buf, opts).Info("epoch")
+	if !regexp.MustCompile(`^\d{10} `).MatchString(buf.String()) {
+		t.Errorf("Expected epoch seconds in Color output. Got: %s", buf.String())
+	}
+}
+
+// TestHumanValues tests human friendly durations and sizes in Color output
+func TestHumanValues(t *testing.T) {
+	var buf bytes.Buffer
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.Color)
+	opts.Layout = grovelog.LayoutJSONLine
+	grovelog.NewLogger(&buf, opts).Info("human",
+		"took", 1420*time.Millisecond, "size", util.ByteSize(3355443))
+	if !strings.Contains(buf.String(), `{"took":"1.42s","size":"3.2 MiB"}`) {
+		t.Errorf("Expected human friendly values. Got: %s", buf.String())
+	}
+
+	buf.Reset()
+	opts.Format = grovelog.JSON
+	grovelog.NewLogger(&buf, opts).Info("numeric",
+		"took", 1420*time.Millisecond, "size", util.ByteSize(3355443))
+	if !strings.Contains(buf.String(), `"took":1420000000,"size":3355443`) {
+		t.Errorf("Expected numeric values in JSON. Got: %s", buf.String())
+	}
+}
+
+// TestExpandErrors tests structured rendering of error values
+func TestExpandErrors(t *testing.T) {
+	base := io.ErrUnexpectedEOF
+	err := fmt.Errorf("load config: %w", base)
+
+	for _, format := range []grovelog.Format{grovelog.JSON, grovelog.Color} {
+		var buf bytes.Buffer
+		opts := grovelog.NewOptions(slog.LevelInfo, "", format)
+		opts.ExpandErrors = true
+		opts.Layout = grovelog.LayoutJSONLine
+		opts.NestGroups = true
+		grovelog.NewLogger(&buf, opts).Error("failed", "err", err)
+
+		line := buf.String()
+		var jsonMap map[string]any
+		if err := json.Unmarshal([]byte(line[strings.Index(line, "{"):]), &jsonMap); err != nil {
+			t.Fatalf("format %d: failed to parse output: %v\n%s", format, err, line)
+		}
+		group, _ := jsonMap["err"].(map[string]any)
+		chain, _ := group["chain"].([]any)
+		if group["message"] != err.Error() || group["type"] != "*fmt.wrapError" ||
+			len(chain) != 1 || chain[0] != base.Error() {
+			t.Errorf("format %d: unexpected error group %v", format, jsonMap["err"])
+		}
+	}
+}
+
+// TestStackTraceLevel tests automatic stack traces on severe records
+func TestStackTraceLevel(t *testing.T) {
+	var buf bytes.Buffer
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON)
+	opts.StackTraceLevel = slog.LevelError
+	logger := grovelog.NewLogger(&buf, opts)
+
+	logger.Warn("warning")
+	if strings.Contains(buf.String(), grovelog.StackKey) {
+		t.Errorf("Unexpected stack on warning. Got: %s", buf.String())
+	}
+
+	buf.Reset()
+	logger.Error("failure")
+	var jsonMap map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &jsonMap); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	stack, _ := jsonMap[grovelog.StackKey].([]any)
+	if len(stack) == 0 {
+		t.Fatalf("Expected stack attribute. Got: %s", buf.String())
+	}
+	if top, _ := stack[0].(string); !strings.HasPrefix(top, "github.com/AlonMell/grovelog_test.TestStackTraceLevel ") {
+		t.Errorf("Expected stack to start at the caller, got %v", stack[0])
+	}
+}
+
+// stackError renders a stack trace with %+v, like github.com/pkg/errors
+type stackError struct{}
+
+func (stackError) Error() string { return "stack failure" }
+
+func (e stackError) Format(s fmt.State, verb rune) {
+	if s.Flag('+') {
+		_, _ = io.WriteString(s, "stack failure\nmain.main\n\tmain.go:10")
+		return
+	}
+	_, _ = io.WriteString(s, e.Error())
+}
+
+// TestErrMessage tests that every format renders util.Err as the message
+func TestErrMessage(t *testing.T) {
+	for _, format := range []grovelog.Format{grovelog.JSON, grovelog.Plain, grovelog.Color} {
+		var buf bytes.Buffer
+		grovelog.NewLogger(&buf, grovelog.NewOptions(slog.LevelInfo, "", format)).Error("failed", util.Err(stackError{}))
+		if out := buf.String(); !strings.Contains(out, "stack failure") || strings.Contains(out, "main.go") {
+			t.Errorf("Format %v: expected the error message only, got %s", format, out)
+		}
+	}
+}
+
+type domainError struct {
+	level slog.Level
+	code  string
+}
+
+func (e *domainError) Error() string        { return "domain failure" }
+func (e *domainError) LogLevel() slog.Level { return e.level }
+func (e *domainError) LogCode() string      { return e.code }
+
+// TestErrorLevels tests that domain errors drive level and code
+func TestErrorLevels(t *testing.T) {
+	var buf bytes.Buffer
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON)
+	opts.ErrorLevels = true
+	logger := grovelog.NewLogger(&buf, opts)
+
+	err := fmt.Errorf("handle: %w", &domainError{level: slog.LevelWarn, code: "E_QUOTA"})
+	logger.Error("request failed", util.Err(err))
+
+	var jsonMap map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &jsonMap); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	if jsonMap["level"] != "WARN" || jsonMap["error.code"] != "E_QUOTA" || jsonMap["error"] != err.Error() {
+		t.Errorf("Unexpected record %v", jsonMap)
+	}
+
+	buf.Reset()
+	logger.Error("ignored", util.Err(&domainError{level: slog.LevelDebug}))
+	if buf.Len() != 0 {
+		t.Errorf("Expected record lowered below the minimum level to be dropped. Got: %s", buf.String())
+	}
+
+	if level := util.ErrLevel(io.EOF, slog.LevelError); level != slog.LevelError {
+		t.Errorf("Expected fallback level, got %v", level)
+	}
+}
+
+// TestErrorTracker tests error fingerprinting and aggregation
+func TestErrorTracker(t *testing.T) {
+	var buf bytes.Buffer
+	tracker := grovelog.NewErrorTracker()
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON)
+	opts.ErrorTracker = tracker
+	logger := grovelog.NewLogger(&buf, opts)
+
+	for i := range 3 {
+		logger.Error(fmt.Sprintf("timeout after %ds", i+1))
+	}
+	logger.Error("disk full")
+	logger.Warn("not tracked")
+
+	top := tracker.Top(0)
+	if len(top) != 2 || top[0].Count != 3 || top[1].Count != 1 {
+		t.Fatalf("Unexpected stats %+v", top)
+	}
+	if !strings.Contains(buf.String(), `"`+grovelog.FingerprintKey+`":"`+top[0].Fingerprint+`"`) {
+		t.Errorf("Expected fingerprint attribute. Got: %s", buf.String())
+	}
+	if tracker.Count(top[1].Fingerprint) != 1 {
+		t.Errorf("Expected count 1 for %s", top[1].Fingerprint)
+	}
+
+	tracker.Reset()
+	if len(tracker.Top(0)) != 0 {
+		t.Error("Expected no stats after Reset")
+	}
+}
+
+// logThroughWrapper simulates an application logging wrapper.
+// It returns the line of its logging call
+func logThroughWrapper(logger *slog.Logger) int {
+	_, _, line, _ := runtime.Caller(0)
+	logger.Info("wrapped", util.Caller())
+	return line + 1
+}
+
+// TestCallerDetection tests automatic caller detection across wrappers
+func TestCallerDetection(t *testing.T) {
+	var buf bytes.Buffer
+	logger := grovelog.NewLogger(&buf, grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON))
+
+	line := logThroughWrapper(logger)
+	_, file, _, _ := runtime.Caller(0)
+	wrapperCaller := fmt.Sprintf("%s:%d", file, line)
+	if !strings.Contains(buf.String(), wrapperCaller) {
+		t.Fatalf("Expected caller %s. Got: %s", wrapperCaller, buf.String())
+	}
+
+	for function, internal := range map[string]bool{
+		"log/slog.(*Logger).Info":                       true,
+		"github.com/AlonMell/grovelog.(*Logger).Infof":  true,
+		"github.com/AlonMell/grovelog/util.Caller":      true,
+		"github.com/AlonMell/grovelog/example.main":     false,
+		"github.com/AlonMell/grovelog/cmd/grovelog.run": false,
+	} {
+		if util.IsInternalFrame(function) != internal {
+			t.Errorf("Expected IsInternalFrame(%q) = %v", function, internal)
+		}
+	}
+
+	buf.Reset()
+	util.SkipCallerPackages("github.com/AlonMell/grovelog_test.logThroughWrapper")
+	logThroughWrapper(logger)
+	_, _, line, _ = runtime.Caller(0)
+	if want := fmt.Sprintf("%s:%d", file, line-1); !strings.Contains(buf.String(), want) {
+		t.Errorf("Expected caller %s outside the registered wrapper. Got: %s", want, buf.String())
+	}
+}
+
+// TestTrimSource tests relative and shortened source paths and function names
+func TestTrimSource(t *testing.T) {
+	_, file, _, _ := runtime.Caller(0)
+	dir := file[:strings.LastIndex(file, "/")]
+
+	tests := []struct {
+		name   string
+		format grovelog.Format
+		prefix string
+		short  bool
+		want   string
+	}{
+		{name: "JSONPrefix", format: grovelog.JSON, prefix: dir, want: `"file":"logger_test.go"`},
+		{name: "JSONShort", format: grovelog.JSON, short: true, want: `"file":"` + filepath.Base(dir) + `/logger_test.go"`},
+		{name: "ColorPrefix", format: grovelog.Color, prefix: dir, want: `(grovelog_test.TestTrimSource.func1 logger_test.go:`},
+		{name: "JSONFunction", format: grovelog.JSON, want: `"function":"github.com/AlonMell/grovelog_test.TestTrimSource.func1"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			opts := grovelog.NewOptions(slog.LevelInfo, "", tt.format)
+			opts.SlogOpts.AddSource = true
+			opts.TrimSourcePrefix = tt.prefix
+			opts.ShortSource = tt.short
+			grovelog.NewLogger(&buf, opts).Info("source")
+
+			if !strings.Contains(buf.String(), tt.want) {
+				t.Errorf("Expected %s. Got: %s", tt.want, buf.String())
+			}
+		})
+	}
+}
+
+// TestGoroutineID tests the goroutine ID attribute
+func TestGoroutineID(t *testing.T) {
+	var buf bytes.Buffer
+	var mu sync.Mutex
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON)
+	opts.AddGoroutineID = true
+	logger := grovelog.NewLogger(&buf, opts)
+
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mu.Lock()
+			defer mu.Unlock()
+			logger.Info("concurrent")
+		}()
+	}
+	wg.Wait()
+
+	ids := make(map[float64]bool)
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var jsonMap map[string]any
+		if err := json.Unmarshal([]byte(line), &jsonMap); err != nil {
+			t.Fatalf("Failed to parse JSON output: %v", err)
+		}
+		id, ok := jsonMap[grovelog.GoroutineKey].(float64)
+		if !ok || id == 0 {
+			t.Fatalf("Expected goroutine ID in %v", jsonMap)
+		}
+		ids[id] = true
+	}
+	if len(ids) != 2 {
+		t.Errorf("Expected two distinct goroutine IDs, got %v", ids)
+	}
+}
+
+// TestStandardFields tests the host and process attributes
+func TestStandardFields(t *testing.T) {
+	var buf bytes.Buffer
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON)
+	opts.StandardFields = &grovelog.StandardFields{Hostname: true, PID: true, Service: "billing", Version: "1.2.3"}
+	grovelog.NewLogger(&buf, opts).Info("stamped")
+
+	var jsonMap map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &jsonMap); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	host, _ := os.Hostname()
+	if jsonMap["hostname"] != host || jsonMap["pid"] != float64(os.Getpid()) ||
+		jsonMap["service"] != "billing" || jsonMap["version"] != "1.2.3" {
+		t.Errorf("Unexpected standard fields %v", jsonMap)
+	}
+}
+
+// TestWithBuildInfo tests the build info group
+func TestWithBuildInfo(t *testing.T) {
+	attr := grovelog.WithBuildInfo()
+	if attr.Key != grovelog.BuildInfoKey && !attr.Equal(slog.Attr{}) {
+		t.Errorf("Unexpected build info key %q", attr.Key)
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		for _, a := range attr.Value.Group() {
+			switch a.Key {
+			case "version", "revision", "dirty":
+			default:
+				t.Errorf("Unexpected build info attribute %q", a.Key)
+			}
+		}
+	}
+}
+
+// TestFromEnvPreset tests the environment based preset selection
+func TestFromEnvPreset(t *testing.T) {
+	hooks := grovelog.PresetHooks{
+		Development: func(opts *grovelog.Options) { opts.ThemeName = grovelog.ThemeDracula },
+		Production:  func(opts *grovelog.Options) { opts.MaxAttrs = 10 },
+	}
+
+	testCases := []struct {
+		appEnv, goEnv string
+		format        grovelog.Format
+	}{
+		{"", "", grovelog.JSON},
+		{"development", "", grovelog.Color},
+		{"", "local", grovelog.Color},
+		{"production", "dev", grovelog.JSON},
+	}
+	for _, tc := range testCases {
+		t.Setenv(grovelog.AppEnv, tc.appEnv)
+		t.Setenv(grovelog.GoEnv, tc.goEnv)
+
+		opts := grovelog.FromEnvPreset(hooks)
+		if opts.Format != tc.format {
+			t.Errorf("APP_ENV=%q GO_ENV=%q: expected format %v, got %v", tc.appEnv, tc.goEnv, tc.format, opts.Format)
+		}
+		if tc.format == grovelog.Color && opts.ThemeName != grovelog.ThemeDracula {
+			t.Errorf("Development hook not applied")
+		}
+		if tc.format == grovelog.JSON && opts.MaxAttrs != 10 {
+			t.Errorf("Production hook not applied")
+		}
+	}
+}
+
+// TestSetDefault tests the slog default and standard log bridge
+func TestSetDefault(t *testing.T) {
+	prevLogger, prevOut, prevFlags := slog.Default(), log.Writer(), log.Flags()
+	t.Cleanup(func() {
+		slog.SetDefault(prevLogger)
+		log.SetOutput(prevOut)
+		log.SetFlags(prevFlags)
+	})
+
+	var buf bytes.Buffer
+	logger := grovelog.NewLogger(&buf, grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON))
+	grovelog.SetDefault(logger, slog.LevelWarn)
+
+	if slog.Default() != logger {
+		t.Error("Logger not installed as slog.Default")
+	}
+	log.Printf("legacy %d", 42)
+
+	var jsonMap map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &jsonMap); err != nil {
+		t.Fatalf("Failed to parse JSON output %q: %v", buf.String(), err)
+	}
+	if jsonMap["msg"] != "legacy 42" || jsonMap["level"] != "WARN" {
+		t.Errorf("Unexpected bridged record %v", jsonMap)
+	}
+}
+
+// TestLineWriter tests the io.Writer adapter
+func TestLineWriter(t *testing.T) {
+	var buf bytes.Buffer
+	logger := grovelog.Wrap(grovelog.NewLogger(&buf, grovelog.NewOptions(slog.LevelDebug, "", grovelog.JSON)))
+
+	w := logger.LevelWriter(slog.LevelInfo)
+	fmt.Fprint(w, "started\n[ERROR] disk full\nwarn: slow")
+	fmt.Fprint(w, " request\nkey: value\n")
+	fmt.Fprint(w, "partial")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct{ level, msg string }{
+		{"INFO", "started"},
+		{"ERROR", "disk full"},
+		{"WARN", "slow request"},
+		{"INFO", "key: value"},
+		{"INFO", "partial"},
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d records, got %d: %q", len(expected), len(lines), buf.String())
+	}
+	for i, line := range lines {
+		var jsonMap map[string]any
+		if err := json.Unmarshal([]byte(line), &jsonMap); err != nil {
+			t.Fatalf("Failed to parse JSON output: %v", err)
+		}
+		if jsonMap["level"] != expected[i].level || jsonMap["msg"] != expected[i].msg {
+			t.Errorf("Record %d: expected %v, got %v", i, expected[i], jsonMap)
+		}
+	}
+
+	buf.Reset()
+	fmt.Fprint(logger.Writer(slog.LevelWarn), "[ERROR] kept\n")
+	if out := buf.String(); !strings.Contains(out, `"level":"WARN","msg":"[ERROR] kept"`) {
+		t.Errorf("Expected the prefix kept without level parsing, got %q", out)
+	}
+}
+
+// TestKlogWriter tests re-emitting klog output as records
+func TestKlogWriter(t *testing.T) {
+	var buf bytes.Buffer
+	logger := grovelog.NewLogger(&buf, grovelog.NewOptions(slog.LevelDebug, "", grovelog.JSON))
+
+	w := grovelog.KlogWriter(logger)
+	fmt.Fprint(w, "I0102 15:04:05.123456   12345 reflector.go:289] Starting reflector\n")
+	fmt.Fprint(w, "E0102 15:04:05.223456   12345 leaderelection.go:330] error retrieving lease\n")
+	fmt.Fprint(w, "not a klog line\n")
+
+	expected := []struct{ level, msg, caller string }{
+		{"INFO", "Starting reflector", "reflector.go:289"},
+		{"ERROR", "error retrieving lease", "leaderelection.go:330"},
+		{"INFO", "not a klog line", ""},
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d records, got %d: %q", len(expected), len(lines), buf.String())
+	}
+	for i, line := range lines {
+		var jsonMap map[string]any
+		if err := json.Unmarshal([]byte(line), &jsonMap); err != nil {
+			t.Fatalf("Failed to parse JSON output: %v", err)
+		}
+		caller, _ := jsonMap[util.CallerKey].(string)
+		if jsonMap["level"] != expected[i].level || jsonMap["msg"] != expected[i].msg || caller != expected[i].caller {
+			t.Errorf("Record %d: expected %v, got %v", i, expected[i], jsonMap)
+		}
+	}
+}
+
+// TestSugaredLogger tests the printf-style methods
+func TestSugaredLogger(t *testing.T) {
+	var buf bytes.Buffer
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON)
+	opts.SlogOpts.AddSource = true
+	logger := grovelog.Wrap(grovelog.NewLogger(&buf, opts)).With("service", "billing")
+
+	logger.Debugf("hidden %d", 1)
+	logger.Warnf("user %s retried %d times", "alice", 3, slog.Int("id", 7))
+	_, file, _, _ := runtime.Caller(0)
+
+	var jsonMap map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &jsonMap); err != nil {
+		t.Fatalf("Failed to parse JSON output %q: %v", buf.String(), err)
+	}
+	source, _ := jsonMap["source"].(map[string]any)
+	if jsonMap["level"] != "WARN" || jsonMap["msg"] != "user alice retried 3 times" ||
+		jsonMap["id"] != float64(7) || jsonMap["service"] != "billing" || source["file"] != file {
+		t.Errorf("Unexpected sugared record %v", jsonMap)
+	}
+}
+
+// TestLazy tests lazy attribute evaluation
+func TestLazy(t *testing.T) {
+	for _, format := range []grovelog.Format{grovelog.JSON, grovelog.Color} {
+		var buf bytes.Buffer
+		calls := 0
+		lazy := func() slog.Value {
+			calls++
+			return slog.IntValue(42)
+		}
+
+		logger := grovelog.Wrap(grovelog.NewLogger(&buf, grovelog.NewOptions(slog.LevelInfo, "", format)))
+		logger.WithLazy("stats", lazy).WithGroup("req").Debug("hidden", slog.Any("body", grovelog.Lazy(lazy)))
+		if calls != 0 {
+			t.Errorf("Format %v: lazy values computed %d times for a disabled record", format, calls)
+		}
+
+		logger.WithLazy("stats", lazy).WithGroup("req").Info("shown", slog.Any("body", grovelog.Lazy(lazy)))
+		if calls != 2 {
+			t.Errorf("Format %v: expected 2 lazy computations, got %d", format, calls)
+		}
+		out := buf.String()
+		if !regexp.MustCompile(`"stats": ?42`).MatchString(out) || !regexp.MustCompile(`"(req\.)?body": ?42`).MatchString(out) {
+			t.Errorf("Format %v: unexpected output %q", format, out)
+		}
+	}
+}
+
+// derivingHandler counts the handlers derived from it with WithAttrs and WithGroup
+type derivingHandler struct {
+	slog.Handler
+	derived *int
+}
+
+func (h derivingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	*h.derived++
+	return derivingHandler{h.Handler.WithAttrs(attrs), h.derived}
+}
+
+func (h derivingHandler) WithGroup(name string) slog.Handler {
+	*h.derived++
+	return derivingHandler{h.Handler.WithGroup(name), h.derived}
+}
+
+// TestLazyDerivesOnce tests that records don't derive handlers per record
+func TestLazyDerivesOnce(t *testing.T) {
+	derived := 0
+	mem := grovelog.NewMemoryHandler(slog.LevelInfo)
+	logger := grovelog.Wrap(slog.New(derivingHandler{mem, &derived})).
+		WithLazy("stats", func() slog.Value { return slog.IntValue(42) }).
+		With("app", "api").WithGroup("req").With("id", 7)
+
+	before := derived
+	for range 3 {
+		logger.Info("served", "status", 200)
+	}
+	if derived != before {
+		t.Errorf("Expected no handlers derived per record, got %d", derived-before)
+	}
+	records := mem.Records().Where("stats", "=", 42).Where("app", "=", "api").Where("req.id", "=", 7).Where("req.status", "=", 200)
+	if len(records) != 3 {
+		t.Errorf("Unexpected records %v", mem.Records())
+	}
+}
+
+// TestOnceAndEvery tests the call site sampling helpers
+func TestOnceAndEvery(t *testing.T) {
+	var buf bytes.Buffer
+	logger := grovelog.Wrap(grovelog.NewLogger(&buf, grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON)))
+
+	once, every := logger.Once(), logger.Every(3)
+	for i := range 10 {
+		once.Warn("deprecated")
+		every.With("batch", 1).Info("progress", "i", i)
+	}
+	once.Warn("deprecated")
+
+	out := buf.String()
+	if n := strings.Count(out, `"msg":"deprecated"`); n != 2 {
+		t.Errorf("Expected one record per Once call site, got %d: %s", n, out)
+	}
+	for _, i := range []string{"0", "3", "6", "9"} {
+		if !strings.Contains(out, `"msg":"progress","batch":1,"i":`+i+"}") {
+			t.Errorf("Expected progress record %s, got %s", i, out)
+		}
+	}
+	if n := strings.Count(out, `"msg":"progress"`); n != 4 {
+		t.Errorf("Expected 4 progress records, got %d", n)
+	}
+
+	buf.Reset()
+	for range 3 {
+		logger.Once().Warn("in loop")
+		logger.Every(2).Info("every in loop")
+	}
+	if n := strings.Count(buf.String(), `"msg":"in loop"`); n != 1 {
+		t.Errorf("Expected one record of Once called in a loop, got %d: %s", n, buf.String())
+	}
+	if n := strings.Count(buf.String(), `"msg":"every in loop"`); n != 2 {
+		t.Errorf("Expected 2 records of Every called in a loop, got %d: %s", n, buf.String())
+	}
+}
+
+// TestOp tests the Begin/End operation records
+func TestOp(t *testing.T) {
+	var buf bytes.Buffer
+	logger := grovelog.Wrap(grovelog.NewLogger(&buf, grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON)))
+
+	op := logger.Begin(context.Background(), "sync_orders", slog.Int("batch", 3))
+	if attrs := util.ExtractLogAttrs(op.Context()); len(attrs) != 1 || attrs[0].Value.String() != "sync_orders" {
+		t.Errorf("Operation name not in log context: %v", attrs)
+	}
+	op.End(fmt.Errorf("conflict"))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 records, got %q", buf.String())
+	}
+	var start, end map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &start); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &end); err != nil {
+		t.Fatal(err)
+	}
+
+	if start["level"] != "INFO" || start[util.OpKey] != "sync_orders" || start["batch"] != float64(3) ||
+		start[grovelog.StatusKey] != grovelog.StatusStarted {
+		t.Errorf("Unexpected start record %v", start)
+	}
+	if _, ok := end[util.ElapsedKey]; !ok || end["level"] != "ERROR" || end["batch"] != float64(3) ||
+		end[grovelog.StatusKey] != grovelog.StatusError || end["error"] != "conflict" {
+		t.Errorf("Unexpected end record %v", end)
+	}
+}
+
+// chanWriter sends every write to a channel, to wait for asynchronous records
+type chanWriter chan string
+
+func (w chanWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
+
+// TestHeartbeat tests the periodic alive records
+func TestHeartbeat(t *testing.T) {
+	records := make(chanWriter, 16)
+	logger := grovelog.Wrap(grovelog.NewLogger(records, grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON)))
+
+	hb := logger.Heartbeat(grovelog.HeartbeatOptions{
+		Interval: 5 * time.Millisecond,
+		Gauges:   func() []slog.Attr { return []slog.Attr{slog.Int("queue", 3)} },
+	})
+	hb.Start()
+	hb.Start()
+	record := <-records
+	hb.Stop()
+	hb.Stop()
+
+	var jsonMap map[string]any
+	if err := json.Unmarshal([]byte(record), &jsonMap); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	if _, ok := jsonMap[grovelog.UptimeKey]; !ok || jsonMap["msg"] != "alive" || jsonMap["queue"] != float64(3) {
+		t.Errorf("Unexpected heartbeat record %v", jsonMap)
+	}
+
+	pending := len(records)
+	time.Sleep(20 * time.Millisecond)
+	if len(records) != pending {
+		t.Error("Heartbeat still logging after Stop")
+	}
+}
+
+// TestRuntimeMetrics tests the periodic runtime stats records
+func TestRuntimeMetrics(t *testing.T) {
+	records := make(chanWriter, 16)
+	logger := grovelog.Wrap(grovelog.NewLogger(records, grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON)))
+
+	hb := logger.RuntimeMetrics(5 * time.Millisecond)
+	hb.Start()
+	record := <-records
+	hb.Stop()
+
+	var jsonMap map[string]any
+	if err := json.Unmarshal([]byte(record), &jsonMap); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	stats, _ := jsonMap[grovelog.RuntimeKey].(map[string]any)
+	if goroutines, _ := stats["goroutines"].(float64); jsonMap["msg"] != "runtime metrics" || goroutines < 1 {
+		t.Errorf("Unexpected runtime metrics record %v", jsonMap)
+	}
+	for _, key := range []string{"heap_alloc", "heap_objects", "num_gc", "gc_pause_total", "gc_pause_last"} {
+		if _, ok := stats[key]; !ok {
+			t.Errorf("Missing runtime stat %q in %v", key, stats)
+		}
+	}
+}
+
+// TestMetrics tests the logging activity counters
+func TestMetrics(t *testing.T) {
+	metrics := grovelog.NewMetrics()
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON)
+	opts.Metrics = metrics
+	opts.ErrorLevels = true
+	logger := grovelog.NewLogger(io.Discard, opts)
+
+	logger.Debug("hidden")
+	logger.Info("one")
+	logger.Info("two")
+	logger.Error("lowered", util.Err(&domainError{level: slog.LevelDebug}))
+	logger.Log(context.Background(), slog.LevelError+4, "fatal")
+
+	s := metrics.Snapshot()
+	if s.Records[slog.LevelDebug] != 0 || s.Records[slog.LevelInfo] != 2 || s.Records[slog.LevelError] != 2 {
+		t.Errorf("Unexpected record counts %v", s.Records)
+	}
+	if s.Dropped != 1 || s.Errors != 0 || s.LatencyCount != 4 {
+		t.Errorf("Unexpected snapshot %+v", s)
+	}
+}
+
+// TestExpvar tests the expvar throughput counters
+func TestExpvar(t *testing.T) {
+	var buf bytes.Buffer
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON)
+	opts.Expvar = "grovelog_test"
+	logger := grovelog.NewLogger(&buf, opts)
+
+	logger.Debug("hidden")
+	logger.Info("one")
+	logger.With("k", "v").Warn("two")
+	grovelog.NewLogger(io.Discard, opts).Info("shared")
+
+	m, ok := expvar.Get("grovelog_test").(*expvar.Map)
+	if !ok {
+		t.Fatal("Expvar map not published")
+	}
+	if m.Get("info").String() != "2" || m.Get("warn").String() != "1" || m.Get("debug") != nil {
+		t.Errorf("Unexpected record counts %s", m.String())
+	}
+	if bytes, _ := m.Get(grovelog.ExpvarBytesKey).(*expvar.Int); bytes == nil || bytes.Value() < int64(buf.Len()) {
+		t.Errorf("Unexpected bytes written %v, wrote %d", bytes, buf.Len())
+	}
+}
+
+// failingHandler counts Handle calls and fails while down is set
+type failingHandler struct {
+	slog.Handler
+	down  bool
+	calls int
+}
+
+func (h *failingHandler) Handle(ctx context.Context, r slog.Record) error { //nolint:gocritic
+	h.calls++
+	if h.down {
+		return fmt.Errorf("collector unreachable")
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// TestBreakerHandler tests the circuit breaker around a failing sink
+func TestBreakerHandler(t *testing.T) {
+	var remote, local bytes.Buffer
+	primary := &failingHandler{Handler: slog.NewJSONHandler(&remote, nil), down: true}
+	fallback := slog.NewJSONHandler(&local, nil)
+	logger := slog.New(grovelog.NewBreakerHandler(primary, fallback, grovelog.BreakerOptions{
+		Failures:      2,
+		ProbeInterval: 20 * time.Millisecond,
+	}))
+
+	for range 5 {
+		logger.Info("outage")
+	}
+	if primary.calls != 2 {
+		t.Errorf("Expected the circuit to open after 2 failures, got %d primary calls", primary.calls)
+	}
+	if n := strings.Count(local.String(), "outage"); n != 5 {
+		t.Errorf("Expected 5 records in the fallback, got %d", n)
+	}
+
+	primary.down = false
+	time.Sleep(30 * time.Millisecond)
+	logger.Info("recovered")
+	logger.Info("closed")
+	if !strings.Contains(remote.String(), "recovered") || !strings.Contains(remote.String(), "closed") {
+		t.Errorf("Expected records in the primary after a successful probe, got %q", remote.String())
+	}
+
+	primary.down = true
+	h := grovelog.NewBreakerHandler(primary, nil, grovelog.BreakerOptions{Failures: 1})
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "dropped", 0)
+	if err := h.Handle(context.Background(), r); err == nil || errors.Is(err, grovelog.ErrCircuitOpen) {
+		t.Errorf("Expected the primary error, got %v", err)
+	}
+	if err := h.Handle(context.Background(), r); !errors.Is(err, grovelog.ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen without fallback, got %v", err)
+	}
+}
+
+// flakyWriter fails while down is set
+type flakyWriter struct {
+	mu   sync.Mutex
+	down bool
+	buf  bytes.Buffer
+}
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.down {
+		return 0, fmt.Errorf("collector unreachable")
+	}
+	return w.buf.Write(p)
+}
+
+func (w *flakyWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+func (w *flakyWriter) setDown(down bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.down = down
+}
+
+// TestAsyncWriterSpill tests spilling records to disk during a sink outage
+func TestAsyncWriterSpill(t *testing.T) {
+	sink := &flakyWriter{down: true}
+	w, err := grovelog.NewAsyncWriter(sink, grovelog.AsyncOptions{
+		QueueSize: 2,
+		SpillPath: filepath.Join(t.TempDir(), "spill.journal"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := grovelog.NewLogger(w, grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON))
+
+	for i := range 20 {
+		logger.Info("outage", "i", i)
+	}
+	time.Sleep(10 * time.Millisecond)
+	sink.setDown(false)
+	logger.Info("recovered")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := sink.buf.String()
+	for i := range 20 {
+		if !strings.Contains(out, fmt.Sprintf(`"i":%d}`, i)) {
+			t.Errorf("Record %d lost during the outage", i)
+		}
+	}
+	if !strings.Contains(out, "recovered") || w.Dropped() != 0 {
+		t.Errorf("Unexpected output %q with %d dropped records", out, w.Dropped())
+	}
+	if _, err := w.Write([]byte("late\n")); !errors.Is(err, grovelog.ErrWriterClosed) {
+		t.Errorf("Expected ErrWriterClosed after Close, got %v", err)
+	}
+}
+
+// TestAsyncWriterReplay tests keeping spilled records in the journal until
+// they are written, across writers reopening the journal
+func TestAsyncWriterReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spill.journal")
+	sink := &flakyWriter{down: true}
+	w, err := grovelog.NewAsyncWriter(sink, grovelog.AsyncOptions{SpillPath: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range []string{"one\n", "two\n", "three\n"} {
+		if _, err := w.Write([]byte(record)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The sink fails during the replay of the next run
+	failing := &failAfterWriter{n: 1}
+	if w, err = grovelog.NewAsyncWriter(failing, grovelog.AsyncOptions{SpillPath: path}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if failing.buf.String() != "one\n" {
+		t.Errorf("Expected the first record to be replayed, got %q", failing.buf.String())
+	}
+
+	sink = &flakyWriter{}
+	if w, err = grovelog.NewAsyncWriter(sink, grovelog.AsyncOptions{SpillPath: path}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if sink.buf.String() != "two\nthree\n" {
+		t.Errorf("Expected the remaining records to be replayed, got %q", sink.buf.String())
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() != 0 {
+		t.Errorf("Expected an empty journal after the replay, got %v, %v", info, err)
+	}
+}
+
+// TestAsyncWriterRetry tests replaying spilled records without further
+// writes, periodically and on Close
+func TestAsyncWriterRetry(t *testing.T) {
+	for _, retry := range []time.Duration{time.Millisecond, time.Hour} {
+		path := filepath.Join(t.TempDir(), "spill.journal")
+		sink := &flakyWriter{down: true}
+		w, err := grovelog.NewAsyncWriter(sink, grovelog.AsyncOptions{SpillPath: path, RetryInterval: retry})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte("spilled\n")); err != nil {
+			t.Fatal(err)
+		}
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			if info, err := os.Stat(path); err == nil && info.Size() > 0 {
+				break
+			}
+		}
+		sink.setDown(false)
+
+		if retry == time.Millisecond {
+			for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+				if sink.String() != "" {
+					break
+				}
+			}
+			if sink.String() != "spilled\n" {
+				t.Errorf("Expected the spilled record replayed by the retry, got %q", sink.String())
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if sink.String() != "spilled\n" {
+			t.Errorf("Retry %v: expected the spilled record replayed once, got %q", retry, sink.String())
+		}
+	}
+}
+
+// failAfterWriter fails every write after the first n
+type failAfterWriter struct {
+	n   int
+	buf bytes.Buffer
+}
+
+func (w *failAfterWriter) Write(p []byte) (int, error) {
+	if w.n == 0 {
+		return 0, errors.New("sink failed")
+	}
+	w.n--
+	return w.buf.Write(p)
+}
+
+// blockingWriter blocks writes until release is closed
+type blockingWriter struct {
+	release chan struct{}
+	buf     bytes.Buffer
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	return w.buf.Write(p)
+}
+
+// TestDrain tests the graceful shutdown of handlers and async writers
+func TestDrain(t *testing.T) {
+	sink := &blockingWriter{release: make(chan struct{})}
+	w, err := grovelog.NewAsyncWriter(sink, grovelog.AsyncOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := grovelog.Wrap(grovelog.NewLogger(w, grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON)))
+	logger.Info("queued")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := logger.Once().Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the drain to time out on a blocked sink, got %v", err)
+	}
+	if logger.Enabled(context.Background(), slog.LevelError) {
+		t.Error("Drained logger still enabled")
+	}
+	logger.Error("after drain")
+
+	close(sink.release)
+	if err := w.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if out := sink.buf.String(); !strings.Contains(out, "queued") || strings.Contains(out, "after drain") {
+		t.Errorf("Unexpected output after drain %q", out)
+	}
+}
+
+// TestDrainStuckRecord tests giving up on a record stuck in the sink
+func TestDrainStuckRecord(t *testing.T) {
+	sink := &blockingWriter{release: make(chan struct{})}
+	logger := grovelog.Wrap(grovelog.NewLogger(sink, grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON)))
+	go logger.Info("stuck")
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := logger.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the drain to time out behind the stuck record, got %v", err)
+	}
+	close(sink.release)
+	if err := logger.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestFileWriterShared tests several writers appending to the same file
+func TestFileWriterShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	var wg sync.WaitGroup
+	for w := range 4 {
+		fw, err := grovelog.NewFileWriter(path, grovelog.FileOptions{Lock: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer fw.Close()
+		logger := grovelog.NewLogger(fw, grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON))
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 100 {
+				logger.Info("record", "writer", w, "i", i, "payload", strings.Repeat("x", 512))
+			}
+		}()
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 400 {
+		t.Fatalf("Expected 400 records, got %d", len(lines))
+	}
+	for _, line := range lines {
+		if !json.Valid([]byte(line)) {
+			t.Fatalf("Interleaved record %q", line)
+		}
+	}
+}
+
+// TestFileWriterModes tests the file permissions and directory creation
+func TestFileWriterModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix permissions")
+	}
+	dir := filepath.Join(t.TempDir(), "var", "log")
+	path := filepath.Join(dir, "app.log")
+
+	fw, err := grovelog.NewFileWriter(path, grovelog.FileOptions{
+		FileMode: 0o600,
+		DirMode:  0o700,
+		Owner:    &grovelog.FileOwner{UID: os.Getuid(), GID: os.Getgid()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fw.Close()
+
+	for p, mode := range map[string]os.FileMode{path: 0o600, dir: 0o700 | os.ModeDir} {
+		info, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode() != mode {
+			t.Errorf("Expected mode %v for %s, got %v", mode, p, info.Mode())
+		}
+	}
+}
+
+// syncWriter counts Sync calls
+type syncWriter struct {
+	bytes.Buffer
+	syncs int
+}
+
+func (w *syncWriter) Sync() error {
+	w.syncs++
+	return nil
+}
+
+// TestSyncPolicy tests syncing after severe records and by file policy
+func TestSyncPolicy(t *testing.T) {
+	var out syncWriter
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON)
+	opts.SyncLevel = slog.LevelError
+	logger := grovelog.NewLogger(&out, opts)
+
+	logger.Info("buffered")
+	logger.Warn("buffered")
+	logger.Error("durable")
+	if out.syncs != 1 {
+		t.Errorf("Expected 1 sync after the Error record, got %d", out.syncs)
+	}
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	fw, err := grovelog.NewFileWriter(path, grovelog.FileOptions{
+		Sync: grovelog.SyncPolicy{Every: 2, Interval: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger = grovelog.NewLogger(fw, opts)
+	for range 3 {
+		logger.Error("durable")
+	}
+	time.Sleep(5 * time.Millisecond)
+	if err := fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "durable"); n != 3 {
+		t.Errorf("Expected 3 records, got %d", n)
+	}
+}
+
+// TestEncryptWriter tests encrypting records and decrypting them back
+func TestEncryptWriter(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	var enc bytes.Buffer
+	w, err := grovelog.NewEncryptWriter(&enc, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := grovelog.NewLogger(w, grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON))
+	logger.Info("secret", "card", "4111")
+	logger.Info("another")
+
+	if strings.Contains(enc.String(), "secret") {
+		t.Fatal("Record written in plain text")
+	}
+
+	r, err := grovelog.NewDecryptReader(bytes.NewReader(enc.Bytes()), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(plain)), "\n"); len(lines) != 2 || !strings.Contains(lines[0], `"card":"4111"`) {
+		t.Errorf("Unexpected decrypted records %q", plain)
+	}
+
+	tampered := bytes.Clone(enc.Bytes())
+	tampered[20] ^= 1
+	r, _ = grovelog.NewDecryptReader(bytes.NewReader(tampered), key)
+	if _, err := io.ReadAll(r); !errors.Is(err, grovelog.ErrCorruptRecord) {
+		t.Errorf("Expected ErrCorruptRecord for a tampered record, got %v", err)
+	}
+}
+
+// TestChainWriter tests the hash-chained audit records
+func TestChainWriter(t *testing.T) {
+	for _, format := range []grovelog.Format{grovelog.JSON, grovelog.Plain} {
+		var out bytes.Buffer
+		w := grovelog.NewChainWriter(&out, grovelog.ChainOptions{CheckpointEvery: 2})
+		logger := grovelog.NewLogger(w, grovelog.NewOptions(slog.LevelInfo, "", format))
+		for i := range 5 {
+			logger.Info("transfer", "id", i)
+		}
+
+		state, err := grovelog.VerifyChain(bytes.NewReader(out.Bytes()))
+		if err != nil || state.Records != 5 || !bytes.Equal(state.Hash, w.State().Hash) {
+			t.Fatalf("Format %v: unexpected verification %+v, %v", format, state, err)
+		}
+		if format == grovelog.JSON {
+			for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+				if !json.Valid([]byte(line)) {
+					t.Errorf("Invalid JSON line %q", line)
+				}
+			}
+		}
+
+		resumed := grovelog.NewChainWriter(&out, grovelog.ChainOptions{Resume: state})
+		grovelog.NewLogger(resumed, grovelog.NewOptions(slog.LevelInfo, "", format)).Info("resumed")
+		if _, err := grovelog.VerifyChain(bytes.NewReader(out.Bytes())); err != nil {
+			t.Errorf("Format %v: resumed chain broken: %v", format, err)
+		}
+
+		lines := strings.SplitAfter(out.String(), "\n")
+		modified := strings.Join(slices.Concat(lines[:1], []string{strings.Replace(lines[1], "transfer", "transfex", 1)}, lines[2:]), "")
+		deleted := strings.Join(slices.Concat(lines[:3], lines[4:]), "")
+		noCheckpoint := strings.Join(slices.Concat(lines[:2], lines[3:]), "")
+		extraCheckpoint := strings.Join(slices.Concat(lines[:3], lines[2:]), "")
+		for name, tampered := range map[string]string{
+			"modified":           modified,
+			"deleted":            deleted,
+			"deleted checkpoint": noCheckpoint,
+			"extra checkpoint":   extraCheckpoint,
+		} {
+			var chainErr *grovelog.ChainError
+			if _, err := grovelog.VerifyChain(strings.NewReader(tampered)); !errors.As(err, &chainErr) {
+				t.Errorf("Format %v: %s line not detected: %v", format, name, err)
+			}
+		}
+	}
+}
+
+// TestChainEmptyObject tests chaining JSON records without members
+func TestChainEmptyObject(t *testing.T) {
+	var out bytes.Buffer
+	w := grovelog.NewChainWriter(&out, grovelog.ChainOptions{})
+	for _, record := range []string{"{}\n", "{ }\n", `{"a":1}` + "\n"} {
+		if _, err := w.Write([]byte(record)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if !json.Valid([]byte(line)) {
+			t.Errorf("Invalid JSON line %q", line)
+		}
+	}
+	if state, err := grovelog.VerifyChain(&out); err != nil || state.Records != 3 {
+		t.Errorf("Unexpected verification %+v, %v", state, err)
+	}
+}
+
+// TestSignWriter tests signing records and verifying them with rotated keys
+func TestSignWriter(t *testing.T) {
+	keys := map[string]grovelog.SignKey{
+		"k1": {Key: []byte("first secret")},
+		"k2": {Key: []byte("second secret")},
+	}
+	var out bytes.Buffer
+	w := grovelog.NewSignWriter(&out, "k1", keys["k1"].Key, keys["k1"].Size)
+	logger := grovelog.NewLogger(w, grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON))
+
+	logger.Info("signed", "n", 1)
+	w.Rotate("k2", keys["k2"].Key)
+	logger.Info("rotated", "n", 2)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	for _, line := range lines {
+		if !json.Valid([]byte(line)) {
+			t.Errorf("Invalid JSON line %q", line)
+		}
+		body, err := grovelog.VerifySignature(line, keys)
+		if err != nil || strings.Contains(body, `"`+grovelog.SignatureKey+`"`) {
+			t.Errorf("Unexpected verification of %q: %q, %v", line, body, err)
+		}
+	}
+	if !strings.Contains(lines[1], `"sig":"k2:`) {
+		t.Errorf("Rotated key not used: %q", lines[1])
+	}
+
+	if _, err := grovelog.VerifySignature(strings.Replace(lines[0], `"n":1`, `"n":9`, 1), keys); !errors.Is(err, grovelog.ErrBadSignature) {
+		t.Errorf("Expected ErrBadSignature for a modified record, got %v", err)
+	}
+	if _, err := grovelog.VerifySignature(lines[0], map[string]grovelog.SignKey{"k2": keys["k2"]}); !errors.Is(err, grovelog.ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey, got %v", err)
+	}
+	truncated := regexp.MustCompile(`(k1:[0-9a-f]{16})[0-9a-f]+`).ReplaceAllString(lines[0], "$1")
+	if _, err := grovelog.VerifySignature(truncated, keys); !errors.Is(err, grovelog.ErrBadSignature) {
+		t.Errorf("Expected ErrBadSignature for a truncated signature, got %v", err)
+	}
+}
+
+// TestMemoryHandlerQuery tests capturing records and slicing them with Where
+func TestMemoryHandlerQuery(t *testing.T) {
+	h := grovelog.NewMemoryHandler(slog.LevelDebug)
+	logger := slog.New(h).With("service", "api").WithGroup("req")
+	logger.Debug("parsed", "method", "GET", "size", 12)
+	logger.Warn("slow", "method", "GET", "elapsed", 2*time.Second)
+	logger.Error("failed", "method", "POST", "status", 502)
+	slog.New(h).Info("ready")
+
+	records := h.Records()
+	if len(records) != 4 {
+		t.Fatalf("Expected 4 records, got %d", len(records))
+	}
+	expectedAttrs := []slog.Attr{
+		slog.String("service", "api"),
+		slog.Group("req", slog.String("method", "GET"), slog.Int("size", 12)),
+	}
+	if len(records[0].Attrs) != 2 || !records[0].Attrs[0].Equal(expectedAttrs[0]) || !records[0].Attrs[1].Equal(expectedAttrs[1]) {
+		t.Errorf("Expected attrs %v, got %v", expectedAttrs, records[0].Attrs)
+	}
+
+	tests := []struct {
+		name     string
+		records  grovelog.Records
+		expected []string
+	}{
+		{"level name", records.Where("level", ">=", "WARN"), []string{"slow", "failed"}},
+		{"level value", records.Where("level", "<", slog.LevelInfo), []string{"parsed"}},
+		{"chained", records.Where("level", ">=", "WARN").Where("req.method", "=", "GET"), []string{"slow"}},
+		{"number", records.Where("req.status", ">=", 500), []string{"failed"}},
+		{"number text", records.Where("req.size", "<", "20"), []string{"parsed"}},
+		{"duration", records.Where("req.elapsed", ">", "1s"), []string{"slow"}},
+		{"missing key", records.Where("req.method", "!=", "GET"), []string{"failed"}},
+		{"regexp", records.Where("msg", "~", "^(slow|ready)$"), []string{"slow", "ready"}},
+		{"top level", records.Where("service", "=", "api"), []string{"parsed", "slow", "failed"}},
+	}
+	for _, tt := range tests {
+		if msgs := tt.records.Messages(); !slices.Equal(msgs, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, msgs)
+		}
+	}
+
+	h.Reset()
+	if len(h.Records()) != 0 {
+		t.Error("Expected no records after Reset")
+	}
+}
+
+// TestRingHandler tests keeping only the most recent records in order
+func TestRingHandler(t *testing.T) {
+	h := grovelog.NewRingHandler(slog.LevelInfo, 3)
+	logger := slog.New(h).With("service", "api")
+	for _, msg := range []string{"one", "two", "three", "four", "five"} {
+		logger.Info(msg)
+	}
+	if msgs := h.Records().Messages(); !slices.Equal(msgs, []string{"three", "four", "five"}) {
+		t.Errorf("Expected the last 3 records, got %v", msgs)
+	}
+
+	h.Reset()
+	logger.Info("six")
+	if msgs := h.Records().Messages(); !slices.Equal(msgs, []string{"six"}) {
+		t.Errorf("Expected only the record after Reset, got %v", msgs)
+	}
+}
+
+// TestSchemaHandler tests annotating and failing records missing required keys
+func TestSchemaHandler(t *testing.T) {
+	required := map[slog.Level][]string{
+		slog.LevelWarn:  {"op"},
+		slog.LevelError: {"error", "req.id"},
+	}
+
+	h := grovelog.NewMemoryHandler(slog.LevelDebug)
+	logger := slog.New(grovelog.NewSchemaHandler(h, grovelog.SchemaOptions{Required: required}))
+	logger.Info("no requirements")
+	logger.With("op", "sync").Warn("complete")
+	logger.Error("incomplete", "op", "sync", slog.Group("req", "path", "/"))
+	logger.With("op", "sync").WithGroup("req").Error("grouped", "id", 7, "error", "boom")
+
+	records := h.Records()
+	if msgs := records.Where(grovelog.SchemaViolationKey, "=", "[error req.id]").Messages(); !slices.Equal(msgs, []string{"incomplete"}) {
+		t.Errorf("Expected a violation of error and req.id for incomplete, got %v", msgs)
+	}
+	if msgs := records.Where("req."+grovelog.SchemaViolationKey, "=", "[error]").Messages(); !slices.Equal(msgs, []string{"grouped"}) {
+		t.Errorf("Expected a violation of error for grouped, got %v", msgs)
+	}
+	if n := len(records.Where(grovelog.SchemaViolationKey, "~", ".")); n != 1 {
+		t.Errorf("Expected 1 top-level violation, got %d", n)
+	}
+
+	var errs []error
+	logger = slog.New(grovelog.NewSchemaHandler(h, grovelog.SchemaOptions{
+		Required: required,
+		Fail:     func(err error) { errs = append(errs, err) },
+	}))
+	logger.Warn("missing op")
+	var schemaErr *grovelog.SchemaError
+	if len(errs) != 1 || !errors.As(errs[0], &schemaErr) || !slices.Equal(schemaErr.Missing, []string{"op"}) {
+		t.Errorf("Expected a SchemaError for op, got %v", errs)
+	}
+
+	h.Reset()
+	var resolved int
+	valuer := countingValuer{calls: &resolved}
+	logger.With("op", valuer).Error("resolved", "error", valuer, "req", valuer)
+	if records := h.Records(); resolved != 3 || len(records) != 1 || len(records[0].Attrs) != 3 {
+		t.Errorf("Expected every LogValuer resolved once, got %d calls and %v", resolved, records)
+	}
+}
+
+// countingValuer is a LogValuer counting its calls, its value is a group
+// of the number of calls so far
+type countingValuer struct{ calls *int }
+
+func (v countingValuer) LogValue() slog.Value {
+	*v.calls++
+	return slog.GroupValue(slog.Int("id", *v.calls))
+}
+
+// TestProcessorHandler tests chaining record processors in front of a handler
+func TestProcessorHandler(t *testing.T) {
+	type tenantKey struct{}
+	errInvalid := errors.New("invalid record")
+
+	redact := grovelog.ProcessorFunc(func(_ context.Context, r *slog.Record) error {
+		redacted := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+		r.Attrs(func(a slog.Attr) bool {
+			if a.Key == "password" {
+				a.Value = slog.StringValue("***")
+			}
+			redacted.AddAttrs(a)
+			return true
+		})
+		*r = redacted
+		return nil
+	})
+	enrich := grovelog.ProcessorFunc(func(ctx context.Context, r *slog.Record) error {
+		if tenant, ok := ctx.Value(tenantKey{}).(string); ok {
+			r.AddAttrs(slog.String("tenant", tenant))
+		}
+		return nil
+	})
+	sample := grovelog.ProcessorFunc(func(_ context.Context, r *slog.Record) error {
+		if r.Message == "noise" {
+			return grovelog.ErrDropRecord
+		}
+		return nil
+	})
+	validate := grovelog.ProcessorFunc(func(_ context.Context, r *slog.Record) error {
+		if r.Message == "" {
+			return errInvalid
+		}
+		return nil
+	})
+
+	h := grovelog.NewMemoryHandler(slog.LevelInfo)
+	handler := grovelog.NewProcessorHandler(h, redact, enrich, sample, validate)
+	logger := slog.New(handler)
+
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+	logger.InfoContext(ctx, "login", "user", "eve", "password", "hunter2")
+	logger.Info("noise")
+
+	records := h.Records()
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 record, got %v", records.Messages())
+	}
+	if len(records.Where("password", "=", "***").Where("tenant", "=", "acme")) != 1 {
+		t.Errorf("Expected a redacted and enriched record, got %v", records[0].Attrs)
+	}
+
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "", 0)
+	if err := handler.Handle(context.Background(), r); !errors.Is(err, errInvalid) {
+		t.Errorf("Expected the validation error, got %v", err)
+	}
+}
+
+// TestCompose tests the Pipe, Chain and Fanout combinators
+func TestCompose(t *testing.T) {
+	var order []string
+	tag := func(name string) grovelog.Middleware {
+		return grovelog.Process(grovelog.ProcessorFunc(func(_ context.Context, r *slog.Record) error {
+			order = append(order, name)
+			r.AddAttrs(slog.String("via", name))
+			return nil
+		}))
+	}
+
+	debug := grovelog.NewMemoryHandler(slog.LevelDebug)
+	warn := grovelog.NewMemoryHandler(slog.LevelWarn)
+	logger := slog.New(grovelog.Chain(grovelog.Fanout(debug, warn), tag("first"), tag("second")))
+	if !logger.Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("Expected Fanout to be enabled for Debug")
+	}
+
+	logger.With("app", "api").Debug("verbose")
+	logger.WithGroup("req").Warn("slow", "id", 1)
+
+	if !slices.Equal(order, []string{"first", "second", "first", "second"}) {
+		t.Errorf("Expected middlewares to run in order, got %v", order)
+	}
+	if msgs := debug.Records().Messages(); !slices.Equal(msgs, []string{"verbose", "slow"}) {
+		t.Errorf("Expected both records in the Debug handler, got %v", msgs)
+	}
+	records := warn.Records()
+	if msgs := records.Messages(); !slices.Equal(msgs, []string{"slow"}) {
+		t.Errorf("Expected only the Warn record in the Warn handler, got %v", msgs)
+	}
+	if len(records.Where("req.id", "=", 1).Where("req.via", "=", "second")) != 1 {
+		t.Errorf("Expected grouped attributes, got %v", records)
+	}
+
+	failing := grovelog.Fanout(&failingHandler{Handler: debug, down: true}, debug)
+	if err := failing.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "fail", 0)); err == nil {
+		t.Error("Expected the error of the failing handler")
+	}
+	if n := len(debug.Records()); n != 3 {
+		t.Errorf("Expected the record despite the failing handler, got %d records", n)
+	}
+}
+
+// TestWithDebugAttrs tests adding attributes only while Debug is enabled
+func TestWithDebugAttrs(t *testing.T) {
+	level := new(slog.LevelVar)
+	h := grovelog.NewMemoryHandler(level)
+	resolved := 0
+	logger := grovelog.Wrap(slog.New(h)).WithDebugAttrs("query", "SELECT 1",
+		slog.Any("plan", grovelog.Lazy(func() slog.Value {
+			resolved++
+			return slog.StringValue("seq scan")
+		}))).WithGroup("req")
+
+	logger.Info("lean", "id", 1)
+	if resolved != 0 {
+		t.Errorf("Expected debug attributes unresolved at Info, resolved %d times", resolved)
+	}
+
+	level.Set(slog.LevelDebug)
+	logger.Info("rich", "id", 2)
+
+	// The debug side is derived once, not per record
+	derived := 0
+	counted := grovelog.Wrap(slog.New(derivingHandler{h, &derived})).WithDebugAttrs("query", "SELECT 1").WithGroup("req")
+	before := derived
+	counted.Info("rich", "id", 3)
+	if derived != before {
+		t.Errorf("Expected no handlers derived per record, got %d", derived-before)
+	}
+
+	records := h.Records()
+	if len(records) != 3 || len(records[:1].Where("query", "=", "SELECT 1")) != 0 {
+		t.Errorf("Expected a lean Info record, got %v", records[0].Attrs)
+	}
+	rich := records.Where("query", "=", "SELECT 1").Where("plan", "=", "seq scan").Where("req.id", "=", 2)
+	if len(records.Where("query", "=", "SELECT 1").Where("req.id", "=", 3)) != 1 {
+		t.Errorf("Expected the counted record at Debug, got %v", records[len(records)-1].Attrs)
+	}
+	if len(rich) != 1 {
+		t.Errorf("Expected a rich record at Debug, got %v", records[len(records)-1].Attrs)
+	}
+}
+
+// TestWithVerbose tests enabling Debug records for a single context
+func TestWithVerbose(t *testing.T) {
+	for _, format := range []grovelog.Format{grovelog.JSON, grovelog.Plain, grovelog.Color} {
+		var buf bytes.Buffer
+		opts := grovelog.NewOptions(slog.LevelInfo, "", format)
+		logger := grovelog.NewLogger(&buf, opts).With("app", "api")
+
+		ctx := util.WithVerbose(context.Background())
+		logger.DebugContext(context.Background(), "quiet request")
+		logger.DebugContext(ctx, "verbose request")
+		logger.Log(ctx, slog.LevelDebug-4, "trace request")
+
+		logOutput := buf.String()
+		if !strings.Contains(logOutput, "verbose request") {
+			t.Errorf("format %d: expected the Debug record of the verbose context. Got: %s", format, logOutput)
+		}
+		if strings.Contains(logOutput, "quiet request") || strings.Contains(logOutput, "trace request") {
+			t.Errorf("format %d: unexpected records. Got: %s", format, logOutput)
+		}
+	}
+}
+
+// TestNewDevWithFile tests the Color console plus JSON file preset
+func TestNewDevWithFile(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	path := filepath.Join(t.TempDir(), "logs", "dev.log")
+	logger, err := grovelog.NewDevWithFile(path, slog.LevelInfo, nil)
+	os.Stdout = stdout
+	if err != nil {
+		t.Fatalf("NewDevWithFile failed: %v", err)
+	}
+
+	logger.Debug("dev record", "user", "alice")
+	logger.Info("both")
+	if err := logger.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain failed: %v", err)
+	}
+	w.Close()
+	console, _ := io.ReadAll(r)
+
+	if !strings.Contains(string(console), "both") || strings.Contains(string(console), `"msg"`) {
+		t.Errorf("Expected the Color record on stdout. Got: %s", console)
+	}
+	if strings.Contains(string(console), "dev record") {
+		t.Errorf("Expected the Debug record below the console level to be dropped. Got: %s", console)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var record map[string]any
+	first, _, _ := bytes.Cut(data, []byte("\n"))
+	if err := json.Unmarshal(first, &record); err != nil {
+		t.Fatalf("Expected a JSON record in the file: %v. Got: %s", err, data)
+	}
+	if record["msg"] != "dev record" || record["level"] != "DEBUG" || record["user"] != "alice" {
+		t.Errorf("Unexpected file record %v", record)
+	}
+}
+
+// TestLevelFileHandler tests routing records to files by level
+func TestLevelFileHandler(t *testing.T) {
+	dir := t.TempDir()
+	files := []grovelog.LevelFile{
+		{Path: filepath.Join(dir, "debug.log"), Level: slog.LevelDebug},
+		{Path: filepath.Join(dir, "app.log"), Level: slog.LevelInfo},
+		{Path: filepath.Join(dir, "error.log"), Level: slog.LevelError},
+	}
+	var opened []string
+	h, err := grovelog.NewLevelFileHandler(grovelog.LevelFileOptions{
+		Options: grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON),
+		Files:   files,
+		NewWriter: func(path string) (grovelog.RotatingWriter, error) {
+			opened = append(opened, filepath.Base(path))
+			return grovelog.NewFileWriter(path, grovelog.FileOptions{})
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewLevelFileHandler failed: %v", err)
+	}
+	if !slices.Equal(opened, []string{"debug.log", "app.log", "error.log"}) {
+		t.Errorf("Expected every file opened by NewWriter, got %v", opened)
+	}
+
+	logger := grovelog.Wrap(slog.New(h))
+	logger.Debug("cache miss")
+	logger.Info("request served")
+	logger.Error("request failed")
+	if err := logger.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain failed: %v", err)
+	}
+
+	expected := map[string][]string{
+		"debug.log": {"cache miss", "request served", "request failed"},
+		"app.log":   {"request served", "request failed"},
+		"error.log": {"request failed"},
+	}
+	for name, msgs := range expected {
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		dec := decode.NewDecoder(f, decode.JSON)
+		for {
+			rec, _, err := dec.Next()
+			if err != nil {
+				break
+			}
+			got = append(got, rec.Message)
+		}
+		f.Close()
+		if !slices.Equal(got, msgs) {
+			t.Errorf("%s: expected %v, got %v", name, msgs, got)
+		}
+	}
+}
+
+// TestStdioLogger tests splitting records between stdout and stderr by level
+func TestStdioLogger(t *testing.T) {
+	outR, outW, _ := os.Pipe()
+	errR, errW, _ := os.Pipe()
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = outW, errW
+	logger := grovelog.NewStdioLogger(grovelog.NewOptions(slog.LevelDebug, "", grovelog.JSON))
+	os.Stdout, os.Stderr = stdout, stderr
+
+	logger.With("app", "api").Debug("debug record")
+	logger.Info("info record")
+	logger.Warn("warn record")
+	logger.Error("error record")
+	outW.Close()
+	errW.Close()
+	out, _ := io.ReadAll(outR)
+	errOut, _ := io.ReadAll(errR)
+
+	for _, msg := range []string{"debug record", "info record"} {
+		if !strings.Contains(string(out), msg) || strings.Contains(string(errOut), msg) {
+			t.Errorf("Expected %q on stdout only", msg)
+		}
+	}
+	for _, msg := range []string{"warn record", "error record"} {
+		if !strings.Contains(string(errOut), msg) || strings.Contains(string(out), msg) {
+			t.Errorf("Expected %q on stderr only", msg)
+		}
+	}
+	if !strings.Contains(string(out), `"app":"api"`) {
+		t.Errorf("Expected attributes on the split handler. Got: %s", out)
+	}
+}
+
+// rotator mimics lumberjack.Logger
+type rotator struct {
+	bytes.Buffer
+	rotations int
+	closed    bool
+}
+
+func (r *rotator) Rotate() error {
+	r.rotations++
+	return nil
+}
+
+func (r *rotator) Close() error {
+	r.closed = true
+	return nil
+}
+
+// TestRotatingWriter tests FileWriter rotation and injecting custom rotators
+func TestRotatingWriter(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	w, err := grovelog.NewFileWriter(path, grovelog.FileOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rw grovelog.RotatingWriter = w
+	logger := grovelog.Wrap(slog.New(grovelog.NewFileHandler(rw, grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON))))
+
+	logger.Info("before rotation")
+	if err := w.Rotate(); err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	logger.Info("after rotation")
+	if err := logger.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain failed: %v", err)
+	}
+
+	backups, _ := filepath.Glob(filepath.Join(dir, "app-*.log"))
+	if len(backups) != 1 {
+		t.Fatalf("Expected one backup file, got %v", backups)
+	}
+	backup, _ := os.ReadFile(backups[0])
+	current, _ := os.ReadFile(path)
+	if !strings.Contains(string(backup), "before rotation") || strings.Contains(string(backup), "after rotation") {
+		t.Errorf("Unexpected backup content: %s", backup)
+	}
+	if !strings.Contains(string(current), "after rotation") || strings.Contains(string(current), "before rotation") {
+		t.Errorf("Unexpected current content: %s", current)
+	}
+
+	sized := filepath.Join(dir, "sized.log")
+	w, err = grovelog.NewFileWriter(sized, grovelog.FileOptions{
+		MaxSize:       20,
+		OnRotateError: func(err error) { t.Errorf("Rotation failed: %v", err) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range []string{"first record\n", "second record\n", "third record\n"} {
+		if _, err := w.Write([]byte(record)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = w.Close()
+	backups, _ = filepath.Glob(filepath.Join(dir, "sized-*.log"))
+	current, _ = os.ReadFile(sized)
+	if len(backups) != 2 || string(current) != "third record\n" {
+		t.Errorf("Expected a rotation before every record exceeding MaxSize, got %v and %q", backups, current)
+	}
+
+	aged := filepath.Join(dir, "aged.log")
+	w, err = grovelog.NewFileWriter(aged, grovelog.FileOptions{MaxAge: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = w.Write([]byte("old record\n"))
+	time.Sleep(5 * time.Millisecond)
+	_, _ = w.Write([]byte("new record\n"))
+	_ = w.Close()
+	backups, _ = filepath.Glob(filepath.Join(dir, "aged-*.log"))
+	current, _ = os.ReadFile(aged)
+	if len(backups) != 1 || string(current) != "new record\n" {
+		t.Errorf("Expected a rotation after MaxAge, got %v and %q", backups, current)
+	}
+
+	utc := filepath.Join(dir, "utc.log")
+	w, err = grovelog.NewFileWriter(utc, grovelog.FileOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	utcOpts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON)
+	utcOpts.UTC = true
+	logger = grovelog.Wrap(slog.New(grovelog.NewFileHandler(w, utcOpts)))
+	before := time.Now().UTC().Format("utc-2006-01-02T15")
+	if err := w.Rotate(); err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	after := time.Now().UTC().Format("utc-2006-01-02T15")
+	_ = logger.Drain(context.Background())
+	backups, _ = filepath.Glob(filepath.Join(dir, "utc-*.log"))
+	if len(backups) != 1 || !strings.HasPrefix(filepath.Base(backups[0]), before) && !strings.HasPrefix(filepath.Base(backups[0]), after) {
+		t.Errorf("Expected a backup dated in UTC %s, got %v", before, backups)
+	}
+
+	custom := &rotator{}
+	logger = grovelog.Wrap(slog.New(grovelog.NewFileHandler(custom, grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON))))
+	logger.Info("custom rotator")
+	_ = logger.Drain(context.Background())
+	if !strings.Contains(custom.String(), "custom rotator") || !custom.closed {
+		t.Errorf("Expected the record written and the rotator closed, got %q, closed %v", custom.String(), custom.closed)
+	}
+}
+
+// TestAddCaller tests the caller attribute in every format
+func TestAddCaller(t *testing.T) {
+	_, file, _, _ := runtime.Caller(0)
+	for _, format := range []grovelog.Format{grovelog.JSON, grovelog.Plain, grovelog.Color} {
+		var buf bytes.Buffer
+		opts := grovelog.NewOptions(slog.LevelInfo, "", format)
+		opts.AddCaller = true
+		logger := grovelog.NewLogger(&buf, opts)
+
+		logger.Info("with caller")
+		_, _, line, _ := runtime.Caller(0)
+		if want := fmt.Sprintf("%s:%d", file, line-1); !strings.Contains(buf.String(), want) ||
+			!strings.Contains(buf.String(), util.CallerKey) {
+			t.Errorf("format %d: expected caller %s. Got: %s", format, want, buf.String())
+		}
+	}
+}
+
+// TestParseFormatAndLevel tests parsing formats and levels, and Format flags
+func TestParseFormatAndLevel(t *testing.T) {
+	for name, expected := range map[string]grovelog.Format{
+		"json": grovelog.JSON, "Plain": grovelog.Plain, "text": grovelog.Plain, " COLOR ": grovelog.Color, "auto": grovelog.Auto,
+	} {
+		if f, err := grovelog.ParseFormat(name); err != nil || f != expected {
+			t.Errorf("ParseFormat(%q) = %v, %v, expected %v", name, f, err, expected)
+		}
+	}
+	if _, err := grovelog.ParseFormat("xml"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+
+	for name, expected := range map[string]slog.Level{
+		"debug": slog.LevelDebug, "WARN": slog.LevelWarn, "warning": slog.LevelWarn, "error+2": slog.LevelError + 2, "-4": slog.LevelDebug,
+	} {
+		if level, err := grovelog.ParseLevel(name); err != nil || level != expected {
+			t.Errorf("ParseLevel(%q) = %v, %v, expected %v", name, level, err, expected)
+		}
+	}
+	if _, err := grovelog.ParseLevel("loud"); err == nil {
+		t.Error("Expected an error for an unknown level")
+	}
+
+	fs := flag.NewFlagSet("app", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	format := grovelog.Color
+	var level slog.Level
+	fs.Var(&format, "log-format", "log format")
+	fs.TextVar(&level, "log-level", slog.LevelInfo, "log level")
+	if err := fs.Parse([]string{"-log-format", "json", "-log-level", "warn"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if format != grovelog.JSON || level != slog.LevelWarn {
+		t.Errorf("Expected json and WARN, got %v and %v", format, level)
+	}
+	if err := fs.Parse([]string{"-log-format", "yaml"}); err == nil {
+		t.Error("Expected an error for an unknown format flag")
+	}
+	if text, _ := grovelog.Plain.MarshalText(); string(text) != "plain" {
+		t.Errorf("Expected plain, got %s", text)
+	}
+}
+
+// TestColorSingleWrite tests that the Color format writes every record
+// with a single call, without trailing spaces
+func TestColorSingleWrite(t *testing.T) {
+	for _, layout := range []grovelog.Layout{grovelog.LayoutIndented, grovelog.LayoutKeyValue, grovelog.LayoutExpanded} {
+		writes := make(chanWriter, 64)
+		opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.Color)
+		opts.Layout = layout
+		logger := grovelog.NewLogger(writes, opts).With("app", "api")
+
+		var wg sync.WaitGroup
+		for i := range 16 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				logger.Info("concurrent", "worker", i)
+			}()
+		}
+		wg.Wait()
+		grovelog.NewLogger(writes, opts).Info("bare")
+		close(writes)
+
+		n := 0
+		for w := range writes {
+			n++
+			if !strings.HasSuffix(w, "\n") || strings.Count(w, "[") == 0 {
+				t.Errorf("Layout %d: expected a complete record per write, got %q", layout, w)
+			}
+			if strings.HasSuffix(w, " \n") {
+				t.Errorf("Layout %d: unexpected trailing space in %q", layout, w)
+			}
+		}
+		if n != 17 {
+			t.Errorf("Layout %d: expected 17 writes, got %d", layout, n)
+		}
+	}
+}
+
+func TestBufferPoolOptions(t *testing.T) {
+	var buf bytes.Buffer
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.Color)
+	opts.BufferSize = 16
+	opts.MaxBufferSize = 64
+	opts.Layout = grovelog.LayoutKeyValue
+	logger := grovelog.NewLogger(&buf, opts).With("app", "api")
+
+	long := strings.Repeat("x", 256)
+	for i := range 4 {
+		logger.Info("big", "payload", long, "i", i)
+		logger.WithGroup("req").Info("small", "i", i)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 8 {
+		t.Fatalf("expected 8 lines, got %d: %q", len(lines), buf.String())
+	}
+	for i, line := range lines {
+		if i%2 == 0 && !strings.Contains(line, long) {
+			t.Errorf("expected the payload in line %d, got %q", i, line)
+		}
+		if i%2 == 1 && (strings.Contains(line, "x") || !strings.Contains(line, "small")) {
+			t.Errorf("expected a clean small record in line %d, got %q", i, line)
+		}
+	}
+}
+
+// TestColorJSONEncoding tests that the Color format encodes values like encoding/json
+func TestColorJSONEncoding(t *testing.T) {
+	ts := time.Date(2024, 5, 6, 7, 8, 9, 123456000, time.UTC)
+	args := []any{
+		"str", "a<b> & \"q\"\\\n\t\b\f\x01\u2028\u2029 é",
+		"int", 42,
+		"neg", -7,
+		"uint", uint64(1 << 63),
+		"bool", true,
+		"small", 1e-7,
+		"big", 1e21,
+		"float", 3.25,
+		"zero", 0.0,
+		"time", ts,
+		"slice", []int{1, 2},
+		"map", map[string]int{"a": 1},
+		"nil", nil,
+	}
+
+	var buf bytes.Buffer
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.Color)
+	opts.ColorMode = grovelog.ColorNever
+	opts.Layout = grovelog.LayoutJSONLine
+	opts.DisableEscaping = true
+	grovelog.NewLogger(&buf, opts).Info("encode", args...)
+
+	var want strings.Builder
+	want.WriteString(" {")
+	for i := 0; i < len(args); i += 2 {
+		if i > 0 {
+			want.WriteByte(',')
+		}
+		key, _ := json.Marshal(args[i])
+		value, err := json.Marshal(args[i+1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		want.Write(key)
+		want.WriteByte(':')
+		want.Write(value)
+	}
+	want.WriteString("}\n")
+
+	if !strings.HasSuffix(buf.String(), want.String()) {
+		t.Errorf("Expected attributes %s, got %q", want.String(), buf.String())
+	}
+}
+
+// TestMsgPackFormat tests encoding records with the MsgPack format and decoding them back
+func TestMsgPackFormat(t *testing.T) {
+	type point struct {
+		X, Y int
+	}
+	ts := time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.UTC)
+
+	var buf bytes.Buffer
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.MsgPack)
+	logger := grovelog.NewLogger(&buf, opts).With("app", "api").WithGroup("req")
+	logger.Debug("dropped")
+	logger.Warn("slow request",
+		"id", "a b", "attempt", 2, "neg", -300, "ratio", 0.5, "cached", false,
+		"at", ts, "elapsed", 1500*time.Millisecond, "point", point{X: 1, Y: 2},
+		slog.Group("db", "rows", uint64(1<<63)), slog.Group("empty"))
+	grovelog.NewLogger(&buf, opts).Info("bare")
+
+	dec := decode.NewMsgPackDecoder(&buf)
+	rec, _, err := dec.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Level != slog.LevelWarn || rec.Message != "slow request" || rec.Time.IsZero() {
+		t.Errorf("Unexpected built-in fields %+v", rec)
+	}
+	expected := []slog.Attr{
+		slog.String("app", "api"),
+		slog.Group("req",
+			slog.String("id", "a b"), slog.Int64("attempt", 2), slog.Int64("neg", -300),
+			slog.Float64("ratio", 0.5), slog.Bool("cached", false), slog.Time("at", ts),
+			slog.Int64("elapsed", int64(1500*time.Millisecond)),
+			slog.Group("point", slog.Int64("X", 1), slog.Int64("Y", 2)),
+			slog.Group("db", slog.Uint64("rows", 1<<63)),
+		),
+	}
+	if len(rec.Attrs) != len(expected) {
+		t.Fatalf("Expected attrs %v, got %v", expected, rec.Attrs)
+	}
+	for i, a := range expected {
+		if !rec.Attrs[i].Equal(a) {
+			t.Errorf("Expected %v, got %v", a, rec.Attrs[i])
+		}
+	}
+
+	if rec, _, err = dec.Next(); err != nil || rec.Message != "bare" || len(rec.Attrs) != 0 {
+		t.Errorf("Expected the bare record, got %+v, %v", rec, err)
+	}
+	if _, _, err := dec.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+
+	if f, err := grovelog.ParseFormat("msgpack"); err != nil || f != grovelog.MsgPack {
+		t.Errorf("Expected MsgPack, got %v, %v", f, err)
+	}
+}
+
+// TestProtobufFormat tests encoding records with the Protobuf format and decoding them back
+func TestProtobufFormat(t *testing.T) {
+	ts := time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.UTC)
+
+	var buf bytes.Buffer
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.Protobuf)
+	logger := grovelog.NewLogger(&buf, opts).With("app", "api").WithGroup("req")
+	logger.Warn("slow request",
+		"id", "a b", "attempt", 2, "neg", -300, "ratio", 0.5, "cached", false,
+		"at", ts, "elapsed", -1500*time.Millisecond, "tags", []string{"x", "y"},
+		slog.Group("db", "rows", uint64(1<<63)))
+	grovelog.NewLogger(&buf, opts).Info("")
+
+	dec := decode.NewProtobufDecoder(&buf)
+	rec, _, err := dec.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Level != slog.LevelWarn || rec.Message != "slow request" || rec.Time.IsZero() {
+		t.Errorf("Unexpected built-in fields %+v", rec)
+	}
+	expected := []slog.Attr{
+		slog.String("app", "api"),
+		slog.Group("req",
+			slog.String("id", "a b"), slog.Int64("attempt", 2), slog.Int64("neg", -300),
+			slog.Float64("ratio", 0.5), slog.Bool("cached", false), slog.Time("at", ts),
+			slog.Duration("elapsed", -1500*time.Millisecond), slog.Any("tags", []any{"x", "y"}),
+			slog.Group("db", slog.Uint64("rows", 1<<63)),
+		),
+	}
+	if len(rec.Attrs) != len(expected) {
+		t.Fatalf("Expected attrs %v, got %v", expected, rec.Attrs)
+	}
+	if !rec.Attrs[0].Equal(expected[0]) || fmt.Sprint(rec.Attrs[1]) != fmt.Sprint(expected[1]) {
+		t.Errorf("Expected %v, got %v", expected, rec.Attrs)
+	}
+
+	if rec, _, err = dec.Next(); err != nil || rec.Level != slog.LevelInfo || rec.Message != "" || len(rec.Attrs) != 0 {
+		t.Errorf("Expected the empty record, got %+v, %v", rec, err)
+	}
+	if _, _, err := dec.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+}
+
+// TestParquetHandler tests buffering records into hourly partitioned Parquet files
+func TestParquetHandler(t *testing.T) {
+	dir := t.TempDir()
+	h, err := grovelog.NewParquetHandler(grovelog.ParquetOptions{
+		Dir: dir,
+		Columns: []grovelog.ParquetColumn{
+			{Name: "user", Key: "req.user"},
+			{Name: "status", Key: "req.status", Type: grovelog.ParquetInt64},
+			{Name: "ok", Key: "req.ok", Type: grovelog.ParquetBool},
+		},
+		BatchOptions: grovelog.BatchOptions{MaxRecords: 3},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := h.WithAttrs([]slog.Attr{slog.String("app", "api")}).WithGroup("req")
+	base := time.Date(2024, 5, 6, 7, 59, 0, 0, time.UTC)
+	for i, ts := range []time.Time{base, base.Add(time.Minute), base.Add(2 * time.Minute)} {
+		r := slog.NewRecord(ts, slog.LevelInfo, "served", 0)
+		r.AddAttrs(slog.String("user", "eve"), slog.Int("status", 200+i))
+		if i < 2 {
+			r.AddAttrs(slog.Bool("ok", i == 0))
+		}
+		if err := handler.Handle(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := h.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	hour8 := base.Add(time.Minute).Truncate(time.Hour)
+	expected := map[string]map[string][]any{
+		"dt=2024-05-06/hour=07": {
+			"time":   {base.UnixMicro()},
+			"level":  {"INFO"},
+			"msg":    {"served"},
+			"user":   {"eve"},
+			"status": {int64(200)},
+			"ok":     {true},
+		},
+		"dt=2024-05-06/hour=08": {
+			"time":   {hour8.UnixMicro(), hour8.Add(time.Minute).UnixMicro()},
+			"level":  {"INFO", "INFO"},
+			"msg":    {"served", "served"},
+			"user":   {"eve", "eve"},
+			"status": {int64(201), int64(202)},
+			"ok":     {false, nil},
+		},
+	}
+	for partition, want := range expected {
+		files, err := filepath.Glob(filepath.Join(dir, partition, "*.parquet"))
+		if err != nil || len(files) != 1 {
+			t.Fatalf("Expected one file in %s, got %v, %v", partition, files, err)
+		}
+		data, err := os.ReadFile(files[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		columns, err := readParquet(data)
+		if err != nil {
+			t.Fatalf("Invalid Parquet file %s: %v", files[0], err)
+		}
+		for name, values := range want {
+			if !slices.Equal(columns[name], values) {
+				t.Errorf("%s: expected column %s = %v, got %v", partition, name, values, columns[name])
+			}
+		}
+		for _, attrs := range columns["attrs"] {
+			if s, _ := attrs.(string); !strings.HasPrefix(s, `{"app":"api","req":{"user":"eve"`) {
+				t.Errorf("%s: expected the attrs JSON, got %v", partition, attrs)
+			}
+		}
+	}
+}
+
+// readParquet decodes a Parquet file written by NewParquetHandler into
+// its columns by name, nulls as nil
+func readParquet(data []byte) (map[string][]any, error) {
+	if len(data) < 12 || !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+		return nil, errors.New("missing magic")
+	}
+	footer := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	if footer+12 > len(data) {
+		return nil, errors.New("invalid footer length")
+	}
+	meta := (&thriftReader{data: data[len(data)-8-footer : len(data)-8]}).structure()
+
+	schema, _ := meta[2].([]any)
+	rowGroups, _ := meta[4].([]any)
+	if len(schema) < 2 || len(rowGroups) != 1 {
+		return nil, fmt.Errorf("unexpected metadata %v", meta)
+	}
+	chunks, _ := rowGroups[0].(map[int16]any)[1].([]any)
+	if len(chunks) != len(schema)-1 {
+		return nil, fmt.Errorf("expected %d column chunks, got %d", len(schema)-1, len(chunks))
+	}
+
+	columns := make(map[string][]any, len(chunks))
+	for i, chunk := range chunks {
+		element := schema[i+1].(map[int16]any)
+		chunkMeta := chunk.(map[int16]any)[3].(map[int16]any)
+		r := &thriftReader{data: data, pos: int(chunkMeta[9].(int64))}
+		header := r.structure()
+		size := int(header[2].(int64))
+		rows := int(header[5].(map[int16]any)[1].(int64))
+		if r.pos+size > len(data) {
+			return nil, errors.New("page out of bounds")
+		}
+		values, err := readParquetPage(data[r.pos:r.pos+size], element[1].(int64), element[3].(int64) == 1, rows)
+		if err != nil {
+			return nil, err
+		}
+		columns[element[4].(string)] = values
+	}
+	return columns, nil
+}
+
+// readParquetPage decodes a plain encoded data page of rows values
+func readParquetPage(page []byte, physical int64, optional bool, rows int) ([]any, error) {
+	defined := make([]bool, rows)
+	if optional {
+		n := int(binary.LittleEndian.Uint32(page))
+		levels := page[4 : 4+n]
+		page = page[4+n:]
+		for i := 0; i < rows; {
+			run, k := binary.Uvarint(levels)
+			if k <= 0 || len(levels) <= k {
+				return nil, errors.New("invalid definition levels")
+			}
+			for j := 0; j < int(run>>1) && i < rows; j++ {
+				defined[i] = levels[k] == 1
+				i++
+			}
+			levels = levels[k+1:]
+		}
+	} else {
+		for i := range defined {
+			defined[i] = true
+		}
+	}
+
+	values := make([]any, rows)
+	bit := 0
+	for i := range values {
+		if !defined[i] {
+			continue
+		}
+		switch physical {
+		case 0: // BOOLEAN
+			values[i] = page[bit/8]&(1<<(bit%8)) != 0
+			bit++
+		case 2: // INT64
+			values[i] = int64(binary.LittleEndian.Uint64(page))
+			page = page[8:]
+		case 5: // DOUBLE
+			values[i] = math.Float64frombits(binary.LittleEndian.Uint64(page))
+			page = page[8:]
+		case 6: // BYTE_ARRAY
+			n := int(binary.LittleEndian.Uint32(page))
+			values[i] = string(page[4 : 4+n])
+			page = page[4+n:]
+		default:
+			return nil, fmt.Errorf("unexpected physical type %d", physical)
+		}
+	}
+	return values, nil
+}
+
+// thriftReader decodes the Thrift compact protocol, structs as maps of
+// the field ids, integers as int64 and lists as []any
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *thriftReader) varint() int64 {
+	v, n := binary.Varint(r.data[r.pos:])
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) structure() map[int16]any {
+	fields := make(map[int16]any)
+	var id int16
+	for {
+		b := r.data[r.pos]
+		r.pos++
+		if b == 0 {
+			return fields
+		}
+		if delta := int16(b >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(r.varint())
+		}
+		fields[id] = r.value(b & 0x0f)
+	}
+}
+
+func (r *thriftReader) value(typ byte) any {
+	switch typ {
+	case 1, 2: // BOOLEAN_TRUE, BOOLEAN_FALSE
+		return typ == 1
+	case 4, 5, 6: // I16, I32, I64
+		return r.varint()
+	case 8: // BINARY
+		n := int(r.uvarint())
+		r.pos += n
+		return string(r.data[r.pos-n : r.pos])
+	case 9: // LIST
+		b := r.data[r.pos]
+		r.pos++
+		n := int(b >> 4)
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		list := make([]any, n)
+		for i := range list {
+			list[i] = r.value(b & 0x0f)
+		}
+		return list
+	case 12: // STRUCT
+		return r.structure()
+	}
+	panic(fmt.Sprintf("unexpected Thrift type %d", typ))
+}
+
+// TestBatchHandler tests flushing in the background and retrying failed batches
+func TestBatchHandler(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		calls   int
+		flushed []string
+	)
+	release := make(chan struct{})
+	h := grovelog.NewBatchHandler(func(ctx context.Context, records grovelog.Records) error {
+		mu.Lock()
+		calls++
+		call := calls
+		mu.Unlock()
+		switch call {
+		case 1:
+			<-release
+			return errors.New("unavailable")
+		case 2:
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, r := range records {
+			flushed = append(flushed, r.Message)
+		}
+		return nil
+	}, grovelog.BatchOptions{MaxRecords: 2, FlushInterval: time.Hour})
+
+	handle := func(msg string) {
+		if err := h.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, msg, 0)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	handle("one")
+	handle("two")
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		mu.Lock()
+		n := calls
+		mu.Unlock()
+		if n == 1 {
+			break
+		}
+	}
+
+	// The first flush blocks, Handle keeps buffering
+	done := make(chan struct{})
+	go func() {
+		handle("three")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Handle blocked behind a flush")
+	}
+	close(release)
+
+	// The failed batch is kept, the second attempt gives up with ctx
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := h.Flush(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the flush to time out, got %v", err)
+	}
+	if err := h.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if fmt.Sprint(flushed) != "[one two three]" {
+		t.Errorf("Expected the failed batch to be retried, got %v", flushed)
+	}
+}
+
+// recordingDB is a database/sql connector recording executed statements
+type recordingDB struct {
+	mu      sync.Mutex
+	execs   []string
+	commits int
+}
+
+func (db *recordingDB) committed() int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.commits
+}
+
+func (db *recordingDB) Connect(context.Context) (driver.Conn, error) { return recordingConn{db}, nil }
+func (db *recordingDB) Driver() driver.Driver                        { return nil }
+
+type recordingConn struct{ db *recordingDB }
+
+func (c recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return recordingStmt{c.db, query}, nil
+}
+func (c recordingConn) Close() error              { return nil }
+func (c recordingConn) Begin() (driver.Tx, error) { return recordingTx(c), nil }
+
+type recordingTx struct{ db *recordingDB }
+
+func (tx recordingTx) Commit() error {
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+	tx.db.commits++
+	return nil
+}
+func (tx recordingTx) Rollback() error { return nil }
+
+type recordingStmt struct {
+	db    *recordingDB
+	query string
+}
+
+func (s recordingStmt) Close() error  { return nil }
+func (s recordingStmt) NumInput() int { return -1 }
+func (s recordingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	s.db.execs = append(s.db.execs, fmt.Sprint(s.query, args))
+	return driver.RowsAffected(1), nil
+}
+func (s recordingStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+// TestSQLiteHandler tests creating the schema and inserting batches in transactions
+func TestSQLiteHandler(t *testing.T) {
+	rec := &recordingDB{}
+	db := sql.OpenDB(rec)
+	defer db.Close()
+
+	h, err := grovelog.NewSQLiteHandler(db, grovelog.SQLiteOptions{
+		BatchOptions: grovelog.BatchOptions{MaxRecords: 2, FlushInterval: time.Hour},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.execs) != 4 || rec.execs[0] != "PRAGMA journal_mode=WAL[]" || !strings.HasPrefix(rec.execs[1], `CREATE TABLE IF NOT EXISTS "logs"`) {
+		t.Fatalf("Unexpected schema statements %q", rec.execs)
+	}
+
+	ts := time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.UTC)
+	logger := slog.New(h).With("app", "api")
+	for _, msg := range []string{"one", "two", "three"} {
+		r := slog.NewRecord(ts, slog.LevelWarn, msg, 0)
+		r.AddAttrs(slog.Group("req", slog.Int("status", 200)))
+		if err := logger.Handler().Handle(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for deadline := time.Now().Add(time.Second); rec.committed() == 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if rec.committed() == 0 {
+		t.Error("Expected a full batch to be committed in the background")
+	}
+	if err := h.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if rec.commits != 2 || len(rec.execs) != 7 {
+		t.Fatalf("Expected 3 inserts in 2 transactions, got %d commits, %q", rec.commits, rec.execs)
+	}
+	expected := `INSERT INTO "logs" (time, level, msg, attrs) VALUES (?, ?, ?, ?)` +
+		`[2024-05-06T07:08:09.123Z 4 one {"app":"api","req":{"status":200}}]`
+	if rec.execs[4] != expected {
+		t.Errorf("Expected %q, got %q", expected, rec.execs[4])
+	}
+}
+
+// recordingCopier records the rows copied by a Postgres handler
+type recordingCopier struct {
+	table    string
+	columns  []string
+	rows     [][]any
+	failures int // Number of copies failing before the first success
+}
+
+func (c *recordingCopier) CopyFrom(_ context.Context, table string, columns []string, rows [][]any) (int64, error) {
+	if c.failures > 0 {
+		c.failures--
+		return 0, errors.New("connection reset")
+	}
+	c.table, c.columns = table, columns
+	c.rows = append(c.rows, rows...)
+	return int64(len(rows)), nil
+}
+
+// TestPostgresHandler tests copying batches of records with a PostgresCopier
+func TestPostgresHandler(t *testing.T) {
+	copier := &recordingCopier{failures: 1}
+	h := grovelog.NewPostgresHandler(copier, grovelog.PostgresOptions{Table: "audit_logs"})
+
+	ts := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	r := slog.NewRecord(ts, slog.LevelError, "denied", 0)
+	r.AddAttrs(slog.String("user", "eve"))
+	if err := h.WithGroup("auth").Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	r = slog.NewRecord(ts, slog.LevelInfo, "scored", 0)
+	r.AddAttrs(slog.Float64("score", math.NaN()))
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if copier.table != "audit_logs" || fmt.Sprint(copier.columns) != "[time level msg attrs]" || len(copier.rows) != 2 {
+		t.Fatalf("Unexpected copy into %s %v of %v", copier.table, copier.columns, copier.rows)
+	}
+	row := copier.rows[0]
+	rowTime, _ := row[0].(time.Time)
+	attrs, _ := row[3].(json.RawMessage)
+	if !rowTime.Equal(ts) || row[1] != int32(8) || row[2] != "denied" || string(attrs) != `{"auth":{"user":"eve"}}` {
+		t.Errorf("Unexpected row %v", row)
+	}
+	if attrs, _ := copier.rows[1][3].(json.RawMessage); string(attrs) != `{"score":"NaN"}` {
+		t.Errorf("Expected NaN stored as text, got %s", attrs)
+	}
+}
+
+// recordingPublisher records the published MQTT messages
+type recordingPublisher struct {
+	topics   []string
+	payloads []string
+	qos      byte
+	retained bool
+}
+
+func (p *recordingPublisher) Publish(topic string, qos byte, retained bool, payload []byte) error {
+	p.topics = append(p.topics, topic)
+	p.payloads = append(p.payloads, string(payload))
+	p.qos, p.retained = qos, retained
+	return nil
+}
+
+// TestMQTTWriter tests publishing every record as an MQTT message
+func TestMQTTWriter(t *testing.T) {
+	if _, err := grovelog.NewMQTTWriter(&recordingPublisher{}, grovelog.MQTTOptions{Topic: "logs", QoS: 3}); err == nil {
+		t.Error("Expected an error for QoS 3")
+	}
+
+	pub := &recordingPublisher{}
+	w, err := grovelog.NewMQTTWriter(pub, grovelog.MQTTOptions{Topic: "devices/7/logs", QoS: 1, Retained: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := grovelog.NewLogger(w, grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON))
+	logger.Info("boot", "fw", "1.2")
+	logger.Warn("low battery")
+
+	if len(pub.payloads) != 2 || pub.topics[1] != "devices/7/logs" || pub.qos != 1 || !pub.retained {
+		t.Fatalf("Unexpected messages %v on %v", pub.payloads, pub.topics)
+	}
+	var jsonMap map[string]any
+	if err := json.Unmarshal([]byte(pub.payloads[0]), &jsonMap); err != nil || jsonMap["fw"] != "1.2" {
+		t.Errorf("Expected a JSON record, got %q", pub.payloads[0])
+	}
+	if strings.HasSuffix(pub.payloads[0], "\n") {
+		t.Errorf("Expected no trailing newline, got %q", pub.payloads[0])
+	}
+}
+
+// TestCloudLoggingHandler tests writing records with the Cloud Logging API
+// on a Cloud Run revision detected with the metadata server
+func TestCloudLoggingHandler(t *testing.T) {
+	var (
+		auth string
+		body struct {
+			LogName  string                 `json:"logName"`
+			Resource grovelog.CloudResource `json:"resource"`
+			Entries  []struct {
+				Timestamp   string         `json:"timestamp"`
+				Severity    string         `json:"severity"`
+				JSONPayload map[string]any `json:"jsonPayload"`
+			} `json:"entries"`
+		}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/computeMetadata/") && r.Header.Get("Metadata-Flavor") != "Google" {
+			http.Error(w, "missing Metadata-Flavor", http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/computeMetadata/v1/project/project-id":
+			_, _ = io.WriteString(w, "demo")
+		case "/computeMetadata/v1/instance/region":
+			_, _ = io.WriteString(w, "projects/123/regions/europe-west1")
+		case "/computeMetadata/v1/instance/service-accounts/default/token":
+			_, _ = io.WriteString(w, `{"access_token":"secret","expires_in":3600,"token_type":"Bearer"}`)
+		case "/v2/entries:write":
+			auth = r.Header.Get("Authorization")
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(server.URL, "http://"))
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	t.Setenv("K_SERVICE", "api")
+	t.Setenv("K_REVISION", "api-00042")
+	t.Setenv("K_CONFIGURATION", "api")
+
+	h, err := grovelog.NewCloudLoggingHandler(context.Background(), grovelog.CloudLoggingOptions{
+		LogID:    "requests",
+		Endpoint: server.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	for _, level := range []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError, slog.LevelError + 4} {
+		r := slog.NewRecord(ts, level, "served", 0)
+		r.AddAttrs(slog.Int("status", 200))
+		if err := h.WithGroup("http").Handle(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := h.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if auth != "Bearer secret" || body.LogName != "projects/demo/logs/requests" {
+		t.Errorf("Unexpected request %q to %s", auth, body.LogName)
+	}
+	labels := body.Resource.Labels
+	if body.Resource.Type != "cloud_run_revision" || labels["project_id"] != "demo" || labels["service_name"] != "api" ||
+		labels["revision_name"] != "api-00042" || labels["location"] != "europe-west1" {
+		t.Errorf("Unexpected resource %+v", body.Resource)
+	}
+	var severities []string
+	for _, e := range body.Entries {
+		severities = append(severities, e.Severity)
+	}
+	if fmt.Sprint(severities) != "[DEBUG INFO WARNING ERROR CRITICAL]" {
+		t.Fatalf("Unexpected severities %v", severities)
+	}
+	if e := body.Entries[0]; e.Timestamp != "2024-05-06T07:08:09Z" || e.JSONPayload["message"] != "served" ||
+		fmt.Sprint(e.JSONPayload["http"]) != "map[status:200]" {
+		t.Errorf("Unexpected entry %+v", e)
+	}
+}
@@ -0,0 +1,165 @@
+package grovelog
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Record is a record captured by a MemoryHandler. Attributes added with
+// WithAttrs and WithGroup are merged into Attrs, LogValuers are resolved
+type Record struct {
+	Time    time.Time
+	Level   slog.Level
+	Message string
+	Attrs   []slog.Attr
+	PC      uintptr
+}
+
+// memoryStore holds the records captured by a MemoryHandler and its derivatives
+type memoryStore struct {
+	mu       sync.Mutex
+	records  Records
+	capacity int // Maximum number of records kept, unbounded if 0
+	oldest   int // Index of the oldest record once capacity is reached
+}
+
+// add appends rec, overwriting the oldest record once capacity is reached
+func (s *memoryStore) add(rec Record) { //nolint:gocritic
+	if s.capacity > 0 && len(s.records) == s.capacity {
+		s.records[s.oldest] = rec
+		s.oldest = (s.oldest + 1) % s.capacity
+		return
+	}
+	s.records = append(s.records, rec)
+}
+
+// MemoryHandler is a slog.Handler keeping records in memory. Created by
+// NewMemoryHandler it keeps every record, for tests. Created by
+// NewRingHandler it keeps only the most recent ones, e.g. for an admin
+// dump endpoint. Handlers derived with WithAttrs and WithGroup share its
+// records
+type MemoryHandler struct {
+	level  slog.Leveler
+	store  *memoryStore
+	groups []string      // Open groups
+	attrs  [][]slog.Attr // Attributes per group level, len(groups)+1
+}
+
+// NewMemoryHandler creates a MemoryHandler capturing records at level
+// and above, slog.LevelInfo if level is nil
+func NewMemoryHandler(level slog.Leveler) *MemoryHandler {
+	if level == nil {
+		level = slog.LevelInfo
+	}
+	return &MemoryHandler{level: level, store: &memoryStore{}, attrs: make([][]slog.Attr, 1)}
+}
+
+// NewRingHandler creates a MemoryHandler like NewMemoryHandler keeping
+// only the last capacity records, every record if capacity is not positive
+func NewRingHandler(level slog.Leveler, capacity int) *MemoryHandler {
+	h := NewMemoryHandler(level)
+	h.store.capacity = max(capacity, 0)
+	return h
+}
+
+// Records returns a copy of the captured records in the order they were handled
+func (h *MemoryHandler) Records() Records {
+	h.store.mu.Lock()
+	defer h.store.mu.Unlock()
+	s := h.store
+	return slices.Concat(s.records[s.oldest:], s.records[:s.oldest])
+}
+
+// Reset discards the captured records
+func (h *MemoryHandler) Reset() {
+	h.store.mu.Lock()
+	defer h.store.mu.Unlock()
+	h.store.records, h.store.oldest = nil, 0
+}
+
+// Enabled reports whether level is at or above the handler's level
+func (h *MemoryHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+// Handle captures the record
+func (h *MemoryHandler) Handle(_ context.Context, r slog.Record) error { //nolint:gocritic
+	rec := captureRecord(h.groups, h.attrs, r)
+
+	h.store.mu.Lock()
+	defer h.store.mu.Unlock()
+	h.store.add(rec)
+	return nil
+}
+
+// captureRecord returns r as a Record, its attributes nested in the open
+// groups after the attributes bound at each group level
+func captureRecord(groups []string, bound [][]slog.Attr, r slog.Record) Record { //nolint:gocritic
+	attrs := slices.Clone(bound[len(groups)])
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = appendResolved(attrs, a)
+		return true
+	})
+	for i := len(groups) - 1; i >= 0; i-- {
+		outer := slices.Clone(bound[i])
+		if len(attrs) > 0 {
+			outer = append(outer, slog.Attr{Key: groups[i], Value: slog.GroupValue(attrs...)})
+		}
+		attrs = outer
+	}
+	return Record{Time: r.Time, Level: r.Level, Message: r.Message, Attrs: attrs, PC: r.PC}
+}
+
+// WithAttrs returns a MemoryHandler adding attrs to the records it captures
+func (h *MemoryHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.attrs = slices.Clone(h.attrs)
+	last := slices.Clip(h2.attrs[len(h.groups)])
+	for _, a := range attrs {
+		last = appendResolved(last, a)
+	}
+	h2.attrs[len(h.groups)] = last
+	return &h2
+}
+
+// WithGroup returns a MemoryHandler nesting the attributes that follow in group name
+func (h *MemoryHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.groups = append(slices.Clip(h.groups), name)
+	h2.attrs = append(slices.Clip(h.attrs), nil)
+	return &h2
+}
+
+// appendResolved appends a to attrs with LogValuers resolved, following
+// the slog rules: empty attributes are dropped, groups without a key are
+// inlined and empty groups are dropped
+func appendResolved(attrs []slog.Attr, a slog.Attr) []slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() != slog.KindGroup {
+		if a.Equal(slog.Attr{}) {
+			return attrs
+		}
+		return append(attrs, a)
+	}
+
+	var members []slog.Attr
+	for _, m := range a.Value.Group() {
+		members = appendResolved(members, m)
+	}
+	if a.Key == "" {
+		return append(attrs, members...)
+	}
+	if len(members) == 0 {
+		return attrs
+	}
+	return append(attrs, slog.Attr{Key: a.Key, Value: slog.GroupValue(members...)})
+}
@@ -0,0 +1,152 @@
+package grovelog
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// LatencyBuckets are the upper bounds of the Handle latency histogram of Metrics
+var LatencyBuckets = []time.Duration{
+	time.Microsecond,
+	5 * time.Microsecond,
+	10 * time.Microsecond,
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+}
+
+// metricLevels are the levels records are counted by, see metricLevel
+var metricLevels = []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError}
+
+// Metrics counts the logging activity of the handlers created with it
+// in Options.Metrics. It is safe for concurrent use
+type Metrics struct {
+	records [4]atomic.Uint64 // By metricLevels
+	errors  atomic.Uint64
+	dropped atomic.Uint64
+
+	latency      [12]atomic.Uint64 // By LatencyBuckets, the last one is +Inf
+	latencySumNs atomic.Int64
+}
+
+// MetricsSnapshot is a point in time copy of Metrics
+type MetricsSnapshot struct {
+	// Records counts the records handled by level: Debug, Info, Warn and
+	// Error, where every level includes the custom levels above it
+	Records map[slog.Level]uint64
+	// Errors counts the records whose handler returned an error
+	Errors uint64
+	// Dropped counts the records discarded by the record level middlewares,
+	// e.g. errors lowered below the handler level
+	Dropped uint64
+
+	// LatencyCounts are the cumulative counts of Handle durations per
+	// LatencyBuckets entry
+	LatencyCounts []uint64
+	// LatencyCount and LatencySum are the number and sum of Handle durations
+	LatencyCount uint64
+	LatencySum   time.Duration
+}
+
+// NewMetrics returns zeroed Metrics
+func NewMetrics() *Metrics {
+	return &Metrics{}
+}
+
+// Snapshot returns the current counts
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	s := MetricsSnapshot{
+		Records:       make(map[slog.Level]uint64, len(metricLevels)),
+		Errors:        m.errors.Load(),
+		Dropped:       m.dropped.Load(),
+		LatencyCounts: make([]uint64, len(LatencyBuckets)),
+		LatencySum:    time.Duration(m.latencySumNs.Load()),
+	}
+
+	for i, level := range metricLevels {
+		s.Records[level] = m.records[i].Load()
+	}
+
+	for i := range LatencyBuckets {
+		s.LatencyCount += m.latency[i].Load()
+		s.LatencyCounts[i] = s.LatencyCount
+	}
+	s.LatencyCount += m.latency[len(LatencyBuckets)].Load()
+	return s
+}
+
+// observe records a handled record
+func (m *Metrics) observe(level slog.Level, elapsed time.Duration, err error) {
+	m.records[metricLevel(level)].Add(1)
+	if err != nil {
+		m.errors.Add(1)
+	}
+
+	bucket := len(LatencyBuckets)
+	for i, bound := range LatencyBuckets {
+		if elapsed <= bound {
+			bucket = i
+			break
+		}
+	}
+	m.latency[bucket].Add(1)
+	m.latencySumNs.Add(int64(elapsed))
+}
+
+// drop records a record discarded by a middleware, m may be nil
+func (m *Metrics) drop() {
+	if m != nil {
+		m.dropped.Add(1)
+	}
+}
+
+// metricLevel returns the metricLevels index of level
+func metricLevel(level slog.Level) int {
+	switch {
+	case level < slog.LevelInfo:
+		return 0
+	case level < slog.LevelWarn:
+		return 1
+	case level < slog.LevelError:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// metricsHandler counts records in Metrics. It wraps all middlewares,
+// which count the records they discard themselves
+type metricsHandler struct {
+	next    slog.Handler
+	metrics *Metrics
+}
+
+// Enabled reports whether the wrapped handler handles level
+func (h *metricsHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle counts the record and passes it on
+func (h *metricsHandler) Handle(ctx context.Context, r slog.Record) error { //nolint:gocritic
+	start := time.Now()
+	err := h.next.Handle(ctx, r)
+	h.metrics.observe(r.Level, time.Since(start), err)
+	return err
+}
+
+// WithAttrs returns a metricsHandler wrapping the handler with attrs
+func (h *metricsHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &metricsHandler{next: h.next.WithAttrs(attrs), metrics: h.metrics}
+}
+
+// WithGroup returns a metricsHandler wrapping the grouped handler
+func (h *metricsHandler) WithGroup(name string) slog.Handler {
+	return &metricsHandler{next: h.next.WithGroup(name), metrics: h.metrics}
+}
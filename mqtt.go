@@ -0,0 +1,63 @@
+package grovelog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// MQTTPublisher publishes a message on an existing MQTT connection. A
+// paho client satisfies it through a small adapter:
+//
+//	type publisher struct{ client mqtt.Client }
+//
+//	func (p publisher) Publish(topic string, qos byte, retained bool, payload []byte) error {
+//		token := p.client.Publish(topic, qos, retained, payload)
+//		token.Wait()
+//		return token.Error()
+//	}
+type MQTTPublisher interface {
+	Publish(topic string, qos byte, retained bool, payload []byte) error
+}
+
+// MQTTOptions configures NewMQTTWriter
+type MQTTOptions struct {
+	// Topic is the topic the records are published to
+	Topic string
+	// QoS is the MQTT quality of service level: 0, 1 or 2
+	QoS byte
+	// Retained makes the broker keep the last record for new subscribers
+	Retained bool
+}
+
+// MQTTWriter publishes every written record as an MQTT message, for
+// devices already maintaining an MQTT connection. Like every grovelog
+// writer it expects one record per Write, as the handlers write them,
+// and publishes it without its trailing newline
+type MQTTWriter struct {
+	pub  MQTTPublisher
+	opts MQTTOptions
+}
+
+// NewMQTTWriter returns an MQTTWriter publishing with pub, to be passed to
+// NewHandler, possibly through an AsyncWriter so a slow broker doesn't
+// block logging
+func NewMQTTWriter(pub MQTTPublisher, opts MQTTOptions) (*MQTTWriter, error) {
+	if opts.Topic == "" {
+		return nil, errors.New("grovelog: MQTTOptions.Topic is empty")
+	}
+	if opts.QoS > 2 {
+		return nil, fmt.Errorf("grovelog: invalid MQTT QoS %d", opts.QoS)
+	}
+	return &MQTTWriter{pub: pub, opts: opts}, nil
+}
+
+// Write publishes the record p. The payload is a copy, so publishers may
+// keep it after Write returns
+func (w *MQTTWriter) Write(p []byte) (int, error) {
+	payload := bytes.Clone(bytes.TrimSuffix(p, []byte("\n")))
+	if err := w.pub.Publish(w.opts.Topic, w.opts.QoS, w.opts.Retained, payload); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,230 @@
+package grovelog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"log/slog"
+	"maps"
+	"math"
+	"slices"
+	"time"
+)
+
+// encodeMsgPack appends a record of the MsgPack format: a map with the
+// built-in attributes followed by the attributes
+func encodeMsgPack(buf []byte, builtins, attrs []slog.Attr) ([]byte, error) {
+	buf, err := appendMsgPackAttrs(appendMsgPackMapHeader(buf, len(builtins)+len(attrs)), builtins)
+	if err != nil {
+		return nil, err
+	}
+	return appendMsgPackAttrs(buf, attrs)
+}
+
+// appendMsgPackAttrs appends the keys and values of resolved attrs
+func appendMsgPackAttrs(buf []byte, attrs []slog.Attr) ([]byte, error) {
+	var err error
+	for _, a := range attrs {
+		buf = appendMsgPackString(buf, a.Key)
+		if buf, err = appendMsgPackValue(buf, a.Value); err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+// appendMsgPackValue appends v as a MessagePack value. Times use the
+// timestamp extension type, durations are nanoseconds and groups are maps
+func appendMsgPackValue(buf []byte, v slog.Value) ([]byte, error) {
+	switch v.Kind() {
+	case slog.KindString:
+		return appendMsgPackString(buf, v.String()), nil
+	case slog.KindInt64:
+		return appendMsgPackInt(buf, v.Int64()), nil
+	case slog.KindUint64:
+		return appendMsgPackUint(buf, v.Uint64()), nil
+	case slog.KindFloat64:
+		return binary.BigEndian.AppendUint64(append(buf, 0xcb), math.Float64bits(v.Float64())), nil
+	case slog.KindBool:
+		return appendMsgPackBool(buf, v.Bool()), nil
+	case slog.KindDuration:
+		return appendMsgPackInt(buf, int64(v.Duration())), nil
+	case slog.KindTime:
+		return appendMsgPackTime(buf, v.Time()), nil
+	case slog.KindGroup:
+		attrs := v.Group()
+		return appendMsgPackAttrs(appendMsgPackMapHeader(buf, len(attrs)), attrs)
+	default:
+		return appendMsgPackAny(buf, v.Any())
+	}
+}
+
+// appendMsgPackAny appends x, falling back to its JSON representation
+// for types without a MessagePack counterpart, like structs
+func appendMsgPackAny(buf []byte, x any) ([]byte, error) {
+	var err error
+	switch x := x.(type) {
+	case nil:
+		return append(buf, 0xc0), nil
+	case string:
+		return appendMsgPackString(buf, x), nil
+	case bool:
+		return appendMsgPackBool(buf, x), nil
+	case float64:
+		return appendMsgPackValue(buf, slog.Float64Value(x))
+	case []byte:
+		return appendMsgPackBinary(buf, x), nil
+	case error:
+		return appendMsgPackString(buf, x.Error()), nil // Like the JSON format does
+	case slog.Level:
+		return appendMsgPackString(buf, x.String()), nil
+	case json.Number:
+		if n, err := x.Int64(); err == nil {
+			return appendMsgPackInt(buf, n), nil
+		}
+		f, err := x.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return appendMsgPackValue(buf, slog.Float64Value(f))
+	case []any:
+		buf = appendMsgPackArrayHeader(buf, len(x))
+		for _, e := range x {
+			if buf, err = appendMsgPackAny(buf, e); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case map[string]any:
+		buf = appendMsgPackMapHeader(buf, len(x))
+		for _, k := range slices.Sorted(maps.Keys(x)) {
+			buf = appendMsgPackString(buf, k)
+			if buf, err = appendMsgPackAny(buf, x[k]); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	}
+
+	if v := slog.AnyValue(x); v.Kind() != slog.KindAny {
+		return appendMsgPackValue(buf, v)
+	}
+	b, err := json.Marshal(x)
+	if err != nil {
+		return nil, err
+	}
+	var generic any
+	if err := unmarshalJSONNumber(b, &generic); err != nil {
+		return nil, err
+	}
+	return appendMsgPackAny(buf, generic)
+}
+
+// unmarshalJSONNumber unmarshals data keeping numbers as json.Number
+func unmarshalJSONNumber(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+func appendMsgPackBool(buf []byte, b bool) []byte {
+	if b {
+		return append(buf, 0xc3)
+	}
+	return append(buf, 0xc2)
+}
+
+func appendMsgPackInt(buf []byte, n int64) []byte {
+	switch {
+	case n >= 0:
+		return appendMsgPackUint(buf, uint64(n))
+	case n >= -32:
+		return append(buf, byte(n)) // Negative fixint
+	case n >= math.MinInt8:
+		return append(buf, 0xd0, byte(n))
+	case n >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(buf, 0xd1), uint16(n)) //nolint:gosec
+	case n >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(buf, 0xd2), uint32(n)) //nolint:gosec
+	default:
+		return binary.BigEndian.AppendUint64(append(buf, 0xd3), uint64(n)) //nolint:gosec
+	}
+}
+
+func appendMsgPackUint(buf []byte, n uint64) []byte {
+	switch {
+	case n <= 0x7f:
+		return append(buf, byte(n)) // Positive fixint
+	case n <= math.MaxUint8:
+		return append(buf, 0xcc, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, 0xcd), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(buf, 0xce), uint32(n))
+	default:
+		return binary.BigEndian.AppendUint64(append(buf, 0xcf), n)
+	}
+}
+
+func appendMsgPackString(buf []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		buf = append(buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		buf = append(buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		buf = binary.BigEndian.AppendUint16(append(buf, 0xda), uint16(n))
+	default:
+		buf = binary.BigEndian.AppendUint32(append(buf, 0xdb), uint32(n)) //nolint:gosec
+	}
+	return append(buf, s...)
+}
+
+func appendMsgPackBinary(buf, b []byte) []byte {
+	switch n := len(b); {
+	case n <= math.MaxUint8:
+		buf = append(buf, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		buf = binary.BigEndian.AppendUint16(append(buf, 0xc5), uint16(n))
+	default:
+		buf = binary.BigEndian.AppendUint32(append(buf, 0xc6), uint32(n)) //nolint:gosec
+	}
+	return append(buf, b...)
+}
+
+func appendMsgPackArrayHeader(buf []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(buf, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, 0xdc), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(buf, 0xdd), uint32(n)) //nolint:gosec
+	}
+}
+
+func appendMsgPackMapHeader(buf []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(buf, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, 0xde), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(buf, 0xdf), uint32(n)) //nolint:gosec
+	}
+}
+
+// appendMsgPackTime appends t with the timestamp extension type -1 in
+// its 32, 64 or 96 bit form, the smallest one holding t
+func appendMsgPackTime(buf []byte, t time.Time) []byte {
+	sec, nsec := t.Unix(), uint64(t.Nanosecond())
+	switch {
+	case sec>>34 == 0 && nsec == 0:
+		return binary.BigEndian.AppendUint32(append(buf, 0xd6, 0xff), uint32(sec)) //nolint:gosec
+	case sec>>34 == 0:
+		return binary.BigEndian.AppendUint64(append(buf, 0xd7, 0xff), nsec<<34|uint64(sec)) //nolint:gosec
+	default:
+		buf = binary.BigEndian.AppendUint32(append(buf, 0xc7, 12, 0xff), uint32(nsec)) //nolint:gosec
+		return binary.BigEndian.AppendUint64(buf, uint64(sec))                         //nolint:gosec
+	}
+}
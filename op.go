@@ -0,0 +1,78 @@
+package grovelog
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"time"
+
+	"github.com/AlonMell/grovelog/util"
+)
+
+// StatusKey is the key of the operation status added by Op records
+const StatusKey = "status"
+
+// Operation statuses
+const (
+	StatusStarted = "started"
+	StatusOK      = "ok"
+	StatusError   = "error"
+)
+
+// Op is a running operation started by Logger.Begin
+type Op struct {
+	logger *Logger
+	ctx    context.Context
+	name   string
+	attrs  []slog.Attr
+	start  time.Time
+}
+
+// Begin logs the start of the operation name and returns an Op whose End
+// logs its outcome and duration. The operation name is added to the log
+// context returned by Op.Context under util.OpKey for nested records:
+//
+//	op := logger.Begin(ctx, "sync_orders", slog.Int("batch", n))
+//	err := syncOrders(op.Context())
+//	op.End(err)
+func (l *Logger) Begin(ctx context.Context, name string, attrs ...slog.Attr) *Op {
+	op := &Op{
+		logger: l,
+		ctx:    util.UpdateLogCtx(ctx, util.OpKey, name),
+		name:   name,
+		attrs:  append([]slog.Attr{slog.String(util.OpKey, name)}, attrs...),
+		start:  time.Now(),
+	}
+	op.log(slog.LevelInfo, slog.String(StatusKey, StatusStarted))
+	return op
+}
+
+// Context returns the context carrying the operation name
+func (op *Op) Context() context.Context {
+	return op.ctx
+}
+
+// End logs the end of the operation with its duration, at Info level
+// with StatusOK, or at Error level with StatusError and err if not nil
+func (op *Op) End(err error) {
+	elapsed := slog.Duration(util.ElapsedKey, time.Since(op.start))
+	if err != nil {
+		op.log(slog.LevelError, slog.String(StatusKey, StatusError), elapsed, util.Err(err))
+		return
+	}
+	op.log(slog.LevelInfo, slog.String(StatusKey, StatusOK), elapsed)
+}
+
+// log logs an operation record with the source of the caller of Begin or End
+func (op *Op) log(level slog.Level, attrs ...slog.Attr) {
+	if !op.logger.Enabled(op.ctx, level) {
+		return
+	}
+
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:]) // Skip Callers, log and Begin or End
+	r := slog.NewRecord(time.Now(), level, op.name, pcs[0])
+	r.AddAttrs(op.attrs...)
+	r.AddAttrs(attrs...)
+	_ = op.logger.Handler().Handle(op.ctx, r)
+}
@@ -0,0 +1,224 @@
+package grovelog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// ParquetType is the type of a Parquet column mapped from an attribute
+type ParquetType int
+
+const (
+	// ParquetString stores values as UTF-8 strings, any attribute converts
+	ParquetString ParquetType = iota
+	// ParquetInt64 stores integers, durations as nanoseconds
+	ParquetInt64
+	// ParquetDouble stores floating point numbers, durations as nanoseconds
+	ParquetDouble
+	// ParquetBool stores booleans
+	ParquetBool
+	// ParquetTimestamp stores times with microsecond precision
+	ParquetTimestamp
+)
+
+// ParquetColumn maps the attribute Key, groups separated by ".", to the
+// column Name of type Type. Records without the attribute, or with a
+// value not converting to Type, store null
+type ParquetColumn struct {
+	Name string
+	Key  string
+	Type ParquetType
+}
+
+// ParquetOptions configures NewParquetHandler
+type ParquetOptions struct {
+	// Dir is the root of the files, partitioned by hour in the Hive
+	// layout Dir/dt=2006-01-02/hour=15/ of the UTC record time
+	Dir string
+	// Columns are the columns after the time, level and msg ones
+	Columns []ParquetColumn
+	// OmitAttrs drops the "attrs" column holding all attributes as JSON
+	OmitAttrs bool
+	// BatchOptions configures the buffering, every flush writes a file
+	// per partition. MaxRecords defaults to 10000 and FlushInterval to
+	// one minute
+	BatchOptions
+}
+
+// NewParquetHandler returns a BatchHandler writing records to Parquet
+// files, so logs can be queried by DuckDB or Athena without an ETL step.
+// Every flush writes new immutable files, renamed into place once
+// complete so readers never see partial files
+func NewParquetHandler(opts ParquetOptions) (*BatchHandler, error) { //nolint:gocritic
+	if opts.Dir == "" {
+		return nil, errors.New("grovelog: ParquetOptions.Dir is empty")
+	}
+	for _, c := range opts.Columns {
+		if c.Name == "" || c.Name == slog.TimeKey || c.Name == slog.LevelKey || c.Name == slog.MessageKey {
+			return nil, fmt.Errorf("grovelog: invalid Parquet column name %q", c.Name)
+		}
+	}
+	if opts.MaxRecords <= 0 {
+		opts.MaxRecords = 10000
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Minute
+	}
+
+	w := &parquetWriter{opts: opts}
+	return NewBatchHandler(w.write, opts.BatchOptions), nil
+}
+
+// parquetWriter writes batches of records to Parquet files
+type parquetWriter struct {
+	opts ParquetOptions
+	seq  int
+}
+
+// write writes the records to a file per partition hour
+func (w *parquetWriter) write(_ context.Context, records Records) error {
+	partitions := make(map[time.Time]Records)
+	var hours []time.Time
+	for _, r := range records {
+		hour := r.Time.UTC().Truncate(time.Hour)
+		if _, ok := partitions[hour]; !ok {
+			hours = append(hours, hour)
+		}
+		partitions[hour] = append(partitions[hour], r)
+	}
+
+	var errs []error
+	for _, hour := range hours {
+		errs = append(errs, w.writeFile(hour, partitions[hour]))
+	}
+	return errors.Join(errs...)
+}
+
+// writeFile writes the records of the partition hour to a new file
+func (w *parquetWriter) writeFile(hour time.Time, records Records) error {
+	dir := filepath.Join(w.opts.Dir, "dt="+hour.Format(time.DateOnly), "hour="+hour.Format("15"))
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return err
+	}
+	w.seq++
+	name := filepath.Join(dir, fmt.Sprintf("part-%d-%d-%d.parquet", records[0].Time.UnixNano(), os.Getpid(), w.seq))
+
+	f, err := os.OpenFile(name+".tmp", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+	if err != nil {
+		return err
+	}
+	err = writeParquet(f, w.columns(records), len(records))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(name+".tmp", name)
+	}
+	if err != nil {
+		_ = os.Remove(name + ".tmp")
+	}
+	return err
+}
+
+// columns converts records into the column data of a file
+func (w *parquetWriter) columns(records Records) []parquetColumnData {
+	columns := []parquetColumnData{
+		{name: slog.TimeKey, typ: ParquetTimestamp, required: true},
+		{name: slog.LevelKey, typ: ParquetString, required: true},
+		{name: slog.MessageKey, typ: ParquetString, required: true},
+	}
+	for _, c := range w.opts.Columns {
+		columns = append(columns, parquetColumnData{name: c.Name, typ: c.Type})
+	}
+	if !w.opts.OmitAttrs {
+		columns = append(columns, parquetColumnData{name: "attrs", typ: ParquetString})
+	}
+	for i := range columns {
+		columns[i].values = make([]any, len(records))
+	}
+
+	for row := range records {
+		r := &records[row]
+		columns[0].values[row] = r.Time.UnixMicro()
+		columns[1].values[row] = r.Level.String()
+		columns[2].values[row] = r.Message
+		for i, c := range w.opts.Columns {
+			if v, ok := r.lookup(c.Key); ok {
+				columns[3+i].values[row] = parquetValue(v.Resolve(), c.Type)
+			}
+		}
+		if !w.opts.OmitAttrs {
+			columns[len(columns)-1].values[row] = string(recordAttrsJSON(r))
+		}
+	}
+	return columns
+}
+
+// parquetValue converts v to the value of a column of type typ,
+// nil if it doesn't convert
+func parquetValue(v slog.Value, typ ParquetType) any { //nolint:cyclop
+	switch typ {
+	case ParquetInt64:
+		switch v.Kind() {
+		case slog.KindInt64:
+			return v.Int64()
+		case slog.KindUint64:
+			if v.Uint64() <= 1<<63-1 {
+				return int64(v.Uint64()) //nolint:gosec
+			}
+		case slog.KindDuration:
+			return int64(v.Duration())
+		case slog.KindString:
+			if n, err := strconv.ParseInt(v.String(), 10, 64); err == nil {
+				return n
+			}
+		}
+	case ParquetDouble:
+		switch v.Kind() {
+		case slog.KindFloat64:
+			return v.Float64()
+		case slog.KindInt64, slog.KindUint64:
+			return toFloatValue(v)
+		case slog.KindDuration:
+			return float64(v.Duration())
+		case slog.KindString:
+			if f, err := strconv.ParseFloat(v.String(), 64); err == nil {
+				return f
+			}
+		}
+	case ParquetBool:
+		switch v.Kind() {
+		case slog.KindBool:
+			return v.Bool()
+		case slog.KindString:
+			if b, err := strconv.ParseBool(v.String()); err == nil {
+				return b
+			}
+		}
+	case ParquetTimestamp:
+		switch v.Kind() {
+		case slog.KindTime:
+			return v.Time().UnixMicro()
+		case slog.KindString:
+			if t, err := time.Parse(time.RFC3339Nano, v.String()); err == nil {
+				return t.UnixMicro()
+			}
+		}
+	default:
+		if v.Kind() == slog.KindGroup {
+			b, err := appendJSONGroup(nil, v.Group(), "", false)
+			if err == nil {
+				return string(b)
+			}
+			return nil
+		}
+		return valueText(v)
+	}
+	return nil
+}
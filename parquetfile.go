@@ -0,0 +1,294 @@
+package grovelog
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// Parquet physical types, converted types and encodings of parquet.thrift
+const (
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetUTF8            = 0
+	parquetTimestampMicros = 10
+
+	parquetPlain = 0
+	parquetRLE   = 3
+)
+
+// parquetColumnData holds the values of a column, nil for nulls. Values
+// are strings, int64, float64 or bool according to the column type
+type parquetColumnData struct {
+	name     string
+	typ      ParquetType
+	required bool
+	values   []any
+}
+
+// physical returns the physical and converted type of the column, the
+// latter -1 if none
+func (c *parquetColumnData) physical() (physical, converted int32) {
+	switch c.typ {
+	case ParquetInt64:
+		return parquetInt64, -1
+	case ParquetDouble:
+		return parquetDouble, -1
+	case ParquetBool:
+		return parquetBoolean, -1
+	case ParquetTimestamp:
+		return parquetInt64, parquetTimestampMicros
+	default:
+		return parquetByteArray, parquetUTF8
+	}
+}
+
+// writeParquet writes a Parquet file holding the columns, all of the same
+// length, as one row group of uncompressed, plain encoded data pages
+func writeParquet(w io.Writer, columns []parquetColumnData, rows int) error {
+	file := []byte("PAR1")
+	chunks := make([]parquetChunk, len(columns))
+	for i := range columns {
+		page := columns[i].page()
+		header := parquetPageHeader(len(page), rows)
+		chunks[i] = parquetChunk{offset: int64(len(file)), size: int64(len(header) + len(page))}
+		file = append(file, header...)
+		file = append(file, page...)
+	}
+
+	footer := parquetFileMetaData(columns, chunks, rows)
+	file = append(file, footer...)
+	file = binary.LittleEndian.AppendUint32(file, uint32(len(footer))) //nolint:gosec
+	file = append(file, "PAR1"...)
+	_, err := w.Write(file)
+	return err
+}
+
+// parquetChunk locates a column chunk in the file
+type parquetChunk struct {
+	offset int64
+	size   int64
+}
+
+// page returns the data page of the column: the definition levels of
+// optional columns followed by the plain encoded non-null values
+func (c *parquetColumnData) page() []byte {
+	var page []byte
+	if !c.required {
+		levels := appendParquetLevels(nil, c.values)
+		page = binary.LittleEndian.AppendUint32(page, uint32(len(levels))) //nolint:gosec
+		page = append(page, levels...)
+	}
+
+	var bits, nbits int
+	for _, v := range c.values {
+		switch x := v.(type) {
+		case string:
+			page = binary.LittleEndian.AppendUint32(page, uint32(len(x))) //nolint:gosec
+			page = append(page, x...)
+		case int64:
+			page = binary.LittleEndian.AppendUint64(page, uint64(x)) //nolint:gosec
+		case float64:
+			page = binary.LittleEndian.AppendUint64(page, math.Float64bits(x))
+		case bool:
+			if x {
+				bits |= 1 << nbits
+			}
+			if nbits++; nbits == 8 {
+				page = append(page, byte(bits))
+				bits, nbits = 0, 0
+			}
+		}
+	}
+	if nbits > 0 {
+		page = append(page, byte(bits))
+	}
+	return page
+}
+
+// appendParquetLevels appends the definition levels of values, 0 for
+// nulls and 1 otherwise, as RLE runs of the RLE/bit-packing hybrid encoding
+func appendParquetLevels(buf []byte, values []any) []byte {
+	for i := 0; i < len(values); {
+		defined := values[i] != nil
+		n := 1
+		for i+n < len(values) && (values[i+n] != nil) == defined {
+			n++
+		}
+		buf = binary.AppendUvarint(buf, uint64(n)<<1)
+		if defined {
+			buf = append(buf, 1)
+		} else {
+			buf = append(buf, 0)
+		}
+		i += n
+	}
+	return buf
+}
+
+// parquetPageHeader returns the PageHeader of a data page
+func parquetPageHeader(size, values int) []byte {
+	var t thriftWriter
+	t.i32(1, 0)           // DATA_PAGE
+	t.i32(2, int32(size)) //nolint:gosec
+	t.i32(3, int32(size)) //nolint:gosec
+	t.structBegin(5)
+	t.i32(1, int32(values)) //nolint:gosec
+	t.i32(2, parquetPlain)
+	t.i32(3, parquetRLE)
+	t.i32(4, parquetRLE)
+	t.structEnd()
+	t.stop()
+	return t.buf
+}
+
+// parquetFileMetaData returns the FileMetaData of the footer
+func parquetFileMetaData(columns []parquetColumnData, chunks []parquetChunk, rows int) []byte {
+	var t thriftWriter
+	t.i32(1, 1) // Version
+
+	t.listBegin(2, thriftStruct, len(columns)+1)
+	t.elemBegin()
+	t.binary(4, "schema")
+	t.i32(5, int32(len(columns))) //nolint:gosec
+	t.structEnd()
+	for i := range columns {
+		physical, converted := columns[i].physical()
+		t.elemBegin()
+		t.i32(1, physical)
+		if columns[i].required {
+			t.i32(3, 0)
+		} else {
+			t.i32(3, 1)
+		}
+		t.binary(4, columns[i].name)
+		if converted >= 0 {
+			t.i32(6, converted)
+		}
+		t.structEnd()
+	}
+
+	t.i64(3, int64(rows))
+
+	var total int64
+	for _, chunk := range chunks {
+		total += chunk.size
+	}
+	t.listBegin(4, thriftStruct, 1)
+	t.elemBegin()
+	t.listBegin(1, thriftStruct, len(columns))
+	for i := range columns {
+		physical, _ := columns[i].physical()
+		t.elemBegin()
+		t.i64(2, chunks[i].offset)
+		t.structBegin(3)
+		t.i32(1, physical)
+		t.listBegin(2, thriftI32, 2)
+		t.listI32(parquetPlain)
+		t.listI32(parquetRLE)
+		t.listBegin(3, thriftBinary, 1)
+		t.listBinary(columns[i].name)
+		t.i32(4, 0) // UNCOMPRESSED
+		t.i64(5, int64(rows))
+		t.i64(6, chunks[i].size)
+		t.i64(7, chunks[i].size)
+		t.i64(9, chunks[i].offset)
+		t.structEnd()
+		t.structEnd()
+	}
+	t.i64(2, total)
+	t.i64(3, int64(rows))
+	t.structEnd()
+
+	t.binary(6, "grovelog")
+	t.stop()
+	return t.buf
+}
+
+// Thrift compact protocol types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter appends fields in the Thrift compact protocol, which
+// encodes the Parquet metadata. Field ids are delta encoded per struct
+type thriftWriter struct {
+	buf    []byte
+	last   int16
+	parent []int16 // Last field ids of the enclosing structs
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	if delta := id - t.last; delta > 0 && delta <= 15 {
+		t.buf = append(t.buf, byte(delta)<<4|typ)
+	} else {
+		t.buf = append(t.buf, typ)
+		t.buf = binary.AppendVarint(t.buf, int64(id))
+	}
+	t.last = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.buf = binary.AppendVarint(t.buf, int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.buf = binary.AppendVarint(t.buf, v)
+}
+
+func (t *thriftWriter) binary(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.listBinary(s)
+}
+
+func (t *thriftWriter) listBegin(id int16, elemType byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf = append(t.buf, byte(n)<<4|elemType)
+		return
+	}
+	t.buf = append(t.buf, 0xf0|elemType)
+	t.buf = binary.AppendUvarint(t.buf, uint64(n)) //nolint:gosec
+}
+
+func (t *thriftWriter) listI32(v int32) {
+	t.buf = binary.AppendVarint(t.buf, int64(v))
+}
+
+func (t *thriftWriter) listBinary(s string) {
+	t.buf = binary.AppendUvarint(t.buf, uint64(len(s)))
+	t.buf = append(t.buf, s...)
+}
+
+// structBegin opens the struct field id
+func (t *thriftWriter) structBegin(id int16) {
+	t.field(id, thriftStruct)
+	t.elemBegin()
+}
+
+// elemBegin opens a struct element of a list
+func (t *thriftWriter) elemBegin() {
+	t.parent = append(t.parent, t.last)
+	t.last = 0
+}
+
+// structEnd closes the innermost struct
+func (t *thriftWriter) structEnd() {
+	t.stop()
+	t.last = t.parent[len(t.parent)-1]
+	t.parent = t.parent[:len(t.parent)-1]
+}
+
+// stop ends the top-level struct
+func (t *thriftWriter) stop() {
+	t.buf = append(t.buf, 0)
+}
@@ -0,0 +1,61 @@
+package grovelog
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// PostgresCopier copies rows into a table with the COPY protocol. A pgx
+// connection or pool satisfies it through a small adapter:
+//
+//	type copier struct{ pool *pgxpool.Pool }
+//
+//	func (c copier) CopyFrom(ctx context.Context, table string, columns []string, rows [][]any) (int64, error) {
+//		return c.pool.CopyFrom(ctx, pgx.Identifier{table}, columns, pgx.CopyFromRows(rows))
+//	}
+type PostgresCopier interface {
+	CopyFrom(ctx context.Context, table string, columns []string, rows [][]any) (int64, error)
+}
+
+// PostgresOptions configures NewPostgresHandler
+type PostgresOptions struct {
+	// Table is the name of the table, "logs" if empty
+	Table string
+	// BatchOptions configures the buffering, every flush copies the batch
+	BatchOptions
+}
+
+// postgresColumns are the columns the records are copied into
+var postgresColumns = []string{"time", "level", "msg", "attrs"}
+
+// NewPostgresHandler returns a BatchHandler copying records into a table
+// of a PostgreSQL database, for teams centralizing audit logs in their
+// primary database. A failed COPY is retried as configured by
+// BatchOptions, and attribute values JSON can't encode, e.g. NaN, are
+// stored as text, so a bad record never loses its batch. The table is created by the caller, e.g. with:
+//
+//	CREATE TABLE logs (
+//		id    bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+//		time  timestamptz NOT NULL,
+//		level integer NOT NULL, -- slog.Level, e.g. 4 for WARN
+//		msg   text NOT NULL,
+//		attrs jsonb NOT NULL
+//	);
+//	CREATE INDEX ON logs (time);
+//	CREATE INDEX ON logs USING gin (attrs);
+func NewPostgresHandler(copier PostgresCopier, opts PostgresOptions) *BatchHandler { //nolint:gocritic
+	if opts.Table == "" {
+		opts.Table = "logs"
+	}
+
+	flush := func(ctx context.Context, records Records) error {
+		rows := make([][]any, len(records))
+		for i := range records {
+			r := &records[i]
+			rows[i] = []any{r.Time, int32(r.Level), r.Message, json.RawMessage(recordAttrsJSON(r))}
+		}
+		_, err := copier.CopyFrom(ctx, opts.Table, postgresColumns, rows)
+		return err
+	}
+	return NewBatchHandler(flush, opts.BatchOptions)
+}
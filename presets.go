@@ -0,0 +1,90 @@
+package grovelog
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Environment variables read by FromEnvPreset, APP_ENV takes precedence
+const (
+	AppEnv = "APP_ENV"
+	GoEnv  = "GO_ENV"
+)
+
+// DevelopmentOptions returns options for local development:
+// colored Debug output with short source locations
+func DevelopmentOptions() Options {
+	opts := NewOptions(slog.LevelDebug, "", Color)
+	opts.SlogOpts.AddSource = true
+	opts.ShortSource = true
+	return opts
+}
+
+// ProductionOptions returns options for deployed services:
+// Info level JSON with UTC timestamps
+func ProductionOptions() Options {
+	opts := NewOptions(slog.LevelInfo, "", JSON)
+	opts.UTC = true
+	return opts
+}
+
+// NewDevWithFile returns a development Logger writing DevelopmentOptions
+// Color output to stdout at consoleLevel and JSON records to the file at
+// path at fileLevel, so the file stays machine readable. Nil levels mean
+// Debug. Each side has its own handler, build them with Fanout to pick
+// other options. Drain the Logger to close the file
+func NewDevWithFile(path string, consoleLevel, fileLevel slog.Leveler) (*Logger, error) {
+	file, err := NewFileWriter(path, FileOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if consoleLevel == nil {
+		consoleLevel = slog.LevelDebug
+	}
+	if fileLevel == nil {
+		fileLevel = slog.LevelDebug
+	}
+
+	consoleOpts := DevelopmentOptions()
+	consoleOpts.SlogOpts.Level = consoleLevel
+	fileOpts := NewOptions(slog.LevelDebug, "", JSON)
+	fileOpts.SlogOpts.Level = fileLevel
+	fileOpts.SlogOpts.AddSource = true
+	h := Fanout(
+		NewHandler(os.Stdout, consoleOpts),
+		NewHandler(file, fileOpts),
+	)
+	return Wrap(slog.New(h)), nil
+}
+
+// PresetHooks customize the options picked by FromEnvPreset, nil hooks are skipped
+type PresetHooks struct {
+	Development func(opts *Options)
+	Production  func(opts *Options)
+}
+
+// FromEnvPreset returns DevelopmentOptions when APP_ENV, or GO_ENV if
+// APP_ENV is unset, is "dev", "development", "local" or "test", and
+// ProductionOptions otherwise, after applying the matching hook
+func FromEnvPreset(hooks PresetHooks) Options {
+	env := os.Getenv(AppEnv)
+	if env == "" {
+		env = os.Getenv(GoEnv)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(env)) {
+	case "dev", "development", "local", "test":
+		opts := DevelopmentOptions()
+		if hooks.Development != nil {
+			hooks.Development(&opts)
+		}
+		return opts
+	default:
+		opts := ProductionOptions()
+		if hooks.Production != nil {
+			hooks.Production(&opts)
+		}
+		return opts
+	}
+}
@@ -0,0 +1,83 @@
+package grovelog
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// ErrDropRecord is returned by a Processor to drop the record without
+// passing it to the remaining processors and the handler
+var ErrDropRecord = errors.New("grovelog: record dropped")
+
+// Processor is a record transformation stage, e.g. for redaction,
+// enrichment, sampling or validation. Process may modify r in place, return
+// ErrDropRecord to drop it or any other error to fail the record. Processors
+// see the record attributes only, not those added with WithAttrs
+type Processor interface {
+	Process(ctx context.Context, r *slog.Record) error
+}
+
+// ProcessorFunc adapts a function to the Processor interface
+type ProcessorFunc func(ctx context.Context, r *slog.Record) error
+
+// Process calls f
+func (f ProcessorFunc) Process(ctx context.Context, r *slog.Record) error {
+	return f(ctx, r)
+}
+
+// NewProcessorHandler returns a handler running records through processors
+// in order before passing them to next
+func NewProcessorHandler(next slog.Handler, processors ...Processor) slog.Handler {
+	if len(processors) == 0 {
+		return next
+	}
+	return &processorHandler{next: next, processors: processors}
+}
+
+// processorHandler runs records through a chain of processors
+type processorHandler struct {
+	next       slog.Handler
+	processors []Processor
+}
+
+// Enabled reports whether the wrapped handler handles level
+func (h *processorHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle processes a copy of the record, then passes it on unless dropped
+func (h *processorHandler) Handle(ctx context.Context, r slog.Record) error { //nolint:gocritic
+	r = r.Clone()
+	for _, p := range h.processors {
+		if err := p.Process(ctx, &r); errors.Is(err, ErrDropRecord) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	return h.next.Handle(ctx, r)
+}
+
+// WithAttrs returns a processorHandler wrapping the handler with attrs
+func (h *processorHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &processorHandler{next: h.next.WithAttrs(attrs), processors: h.processors}
+}
+
+// WithGroup returns a processorHandler wrapping the grouped handler
+func (h *processorHandler) WithGroup(name string) slog.Handler {
+	return &processorHandler{next: h.next.WithGroup(name), processors: h.processors}
+}
+
+// Drain drains the wrapped handler
+func (h *processorHandler) Drain(ctx context.Context) error {
+	return drain(ctx, h.next)
+}
+
+// Process returns a Middleware running records through processors,
+// see NewProcessorHandler
+func Process(processors ...Processor) Middleware {
+	return func(next slog.Handler) slog.Handler {
+		return NewProcessorHandler(next, processors...)
+	}
+}
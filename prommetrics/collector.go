@@ -0,0 +1,80 @@
+// Package prommetrics exposes grovelog.Metrics as Prometheus metrics, to
+// alert on the error log rate and watch the logging overhead
+package prommetrics
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/AlonMell/grovelog"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	recordsDesc = prometheus.NewDesc(
+		"grovelog_records_total",
+		"Records handled, by level.",
+		[]string{"handler", "level"}, nil,
+	)
+	errorsDesc = prometheus.NewDesc(
+		"grovelog_handler_errors_total",
+		"Records whose handler returned an error.",
+		[]string{"handler"}, nil,
+	)
+	droppedDesc = prometheus.NewDesc(
+		"grovelog_dropped_records_total",
+		"Records discarded by record level middlewares.",
+		[]string{"handler"}, nil,
+	)
+	latencyDesc = prometheus.NewDesc(
+		"grovelog_handle_duration_seconds",
+		"Duration of handling a record.",
+		[]string{"handler"}, nil,
+	)
+)
+
+// Collector is a prometheus.Collector reading grovelog.Metrics
+type Collector struct {
+	handler string
+	metrics *grovelog.Metrics
+}
+
+// NewCollector returns a Collector of m labeled with handler, so the
+// metrics of several handlers can be registered side by side:
+//
+//	m := grovelog.NewMetrics()
+//	opts.Metrics = m
+//	prometheus.MustRegister(prommetrics.NewCollector("app", m))
+func NewCollector(handler string, m *grovelog.Metrics) *Collector {
+	return &Collector{handler: handler, metrics: m}
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- recordsDesc
+	ch <- errorsDesc
+	ch <- droppedDesc
+	ch <- latencyDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	s := c.metrics.Snapshot()
+
+	for level, n := range s.Records {
+		ch <- prometheus.MustNewConstMetric(recordsDesc, prometheus.CounterValue, float64(n), c.handler, levelLabel(level))
+	}
+	ch <- prometheus.MustNewConstMetric(errorsDesc, prometheus.CounterValue, float64(s.Errors), c.handler)
+	ch <- prometheus.MustNewConstMetric(droppedDesc, prometheus.CounterValue, float64(s.Dropped), c.handler)
+
+	buckets := make(map[float64]uint64, len(grovelog.LatencyBuckets))
+	for i, bound := range grovelog.LatencyBuckets {
+		buckets[bound.Seconds()] = s.LatencyCounts[i]
+	}
+	ch <- prometheus.MustNewConstHistogram(latencyDesc, s.LatencyCount, s.LatencySum.Seconds(), buckets, c.handler)
+}
+
+// levelLabel returns the lower case level name, e.g. "error"
+func levelLabel(level slog.Level) string {
+	return strings.ToLower(level.String())
+}
@@ -0,0 +1,61 @@
+package prommetrics_test
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/AlonMell/grovelog"
+	"github.com/AlonMell/grovelog/prommetrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TestCollector tests exporting the logging activity counters
+func TestCollector(t *testing.T) {
+	metrics := grovelog.NewMetrics()
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON)
+	opts.Metrics = metrics
+	logger := grovelog.NewLogger(io.Discard, opts)
+	logger.Info("one")
+	logger.Info("two")
+	logger.Error("failed")
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(prommetrics.NewCollector("app", metrics))
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := make(map[string]bool)
+	for _, f := range families {
+		found[f.GetName()] = true
+		for _, m := range f.GetMetric() {
+			labels := make(map[string]string)
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["handler"] != "app" {
+				t.Errorf("%s: expected the handler label, got %v", f.GetName(), labels)
+			}
+			switch {
+			case f.GetName() == "grovelog_records_total" && labels["level"] == "info":
+				if v := m.GetCounter().GetValue(); v != 2 {
+					t.Errorf("Expected 2 info records, got %v", v)
+				}
+			case f.GetName() == "grovelog_handle_duration_seconds":
+				if n := m.GetHistogram().GetSampleCount(); n != 3 {
+					t.Errorf("Expected 3 observed durations, got %d", n)
+				}
+			}
+		}
+	}
+	for _, name := range []string{
+		"grovelog_records_total", "grovelog_handler_errors_total",
+		"grovelog_dropped_records_total", "grovelog_handle_duration_seconds",
+	} {
+		if !found[name] {
+			t.Errorf("Missing metric %s", name)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package grovelog
+
+import (
+	"encoding/json"
+	"log/slog"
+	"math"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field numbers of proto/record.proto
+const (
+	protoRecordTime    protowire.Number = 1
+	protoRecordLevel   protowire.Number = 2
+	protoRecordMessage protowire.Number = 3
+	protoRecordSource  protowire.Number = 4
+	protoRecordAttrs   protowire.Number = 5
+
+	protoSourceFunction protowire.Number = 1
+	protoSourceFile     protowire.Number = 2
+	protoSourceLine     protowire.Number = 3
+
+	protoAttrKey      protowire.Number = 1
+	protoAttrString   protowire.Number = 2
+	protoAttrInt64    protowire.Number = 3
+	protoAttrUint64   protowire.Number = 4
+	protoAttrFloat64  protowire.Number = 5
+	protoAttrBool     protowire.Number = 6
+	protoAttrTime     protowire.Number = 7
+	protoAttrDuration protowire.Number = 8
+	protoAttrGroup    protowire.Number = 9
+	protoAttrBytes    protowire.Number = 10
+	protoAttrJSON     protowire.Number = 11
+
+	protoGroupAttrs protowire.Number = 1
+)
+
+// encodeProtobuf appends a size prefixed Record message of the Protobuf
+// format. Built-in attributes renamed or retyped by ReplaceAttr become attrs
+func encodeProtobuf(buf []byte, builtins, attrs []slog.Attr) ([]byte, error) {
+	var msg []byte
+	var extra []slog.Attr
+	for _, a := range builtins {
+		var ok bool
+		if msg, ok = appendProtoBuiltin(msg, a); !ok {
+			extra = append(extra, a)
+		}
+	}
+
+	var err error
+	for _, list := range [][]slog.Attr{extra, attrs} {
+		for _, a := range list {
+			if msg, err = appendProtoAttr(msg, protoRecordAttrs, a); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	buf = protowire.AppendVarint(buf, uint64(len(msg)))
+	return append(buf, msg...), nil
+}
+
+// appendProtoBuiltin appends a to the Record message if it is a built-in
+// attribute with its original key and type
+func appendProtoBuiltin(msg []byte, a slog.Attr) ([]byte, bool) { //nolint:gocritic
+	switch a.Key {
+	case slog.TimeKey:
+		if a.Value.Kind() == slog.KindTime {
+			return appendProtoTimestamp(msg, protoRecordTime, a.Value.Time()), true
+		}
+	case slog.LevelKey:
+		if level, ok := a.Value.Any().(slog.Level); ok {
+			if level != 0 {
+				msg = protowire.AppendTag(msg, protoRecordLevel, protowire.VarintType)
+				msg = protowire.AppendVarint(msg, protowire.EncodeZigZag(int64(level)))
+			}
+			return msg, true
+		}
+	case slog.MessageKey:
+		if a.Value.Kind() == slog.KindString {
+			if s := a.Value.String(); s != "" {
+				msg = protowire.AppendTag(msg, protoRecordMessage, protowire.BytesType)
+				msg = protowire.AppendString(msg, s)
+			}
+			return msg, true
+		}
+	case slog.SourceKey:
+		if src, ok := a.Value.Any().(*slog.Source); ok && src != nil {
+			return appendProtoSource(msg, src), true
+		}
+	}
+	return msg, false
+}
+
+// appendProtoSource appends the Source message of the record
+func appendProtoSource(buf []byte, src *slog.Source) []byte {
+	var msg []byte
+	if src.Function != "" {
+		msg = protowire.AppendTag(msg, protoSourceFunction, protowire.BytesType)
+		msg = protowire.AppendString(msg, src.Function)
+	}
+	if src.File != "" {
+		msg = protowire.AppendTag(msg, protoSourceFile, protowire.BytesType)
+		msg = protowire.AppendString(msg, src.File)
+	}
+	if src.Line != 0 {
+		msg = protowire.AppendTag(msg, protoSourceLine, protowire.VarintType)
+		msg = protowire.AppendVarint(msg, uint64(src.Line)) //nolint:gosec
+	}
+	buf = protowire.AppendTag(buf, protoRecordSource, protowire.BytesType)
+	return protowire.AppendBytes(buf, msg)
+}
+
+// appendProtoAttr appends a as the Attr message field num
+func appendProtoAttr(buf []byte, num protowire.Number, a slog.Attr) ([]byte, error) { //nolint:cyclop
+	msg := protowire.AppendTag(nil, protoAttrKey, protowire.BytesType)
+	msg = protowire.AppendString(msg, a.Key)
+
+	v := a.Value
+	switch v.Kind() {
+	case slog.KindString:
+		msg = protowire.AppendTag(msg, protoAttrString, protowire.BytesType)
+		msg = protowire.AppendString(msg, v.String())
+	case slog.KindInt64:
+		msg = protowire.AppendTag(msg, protoAttrInt64, protowire.VarintType)
+		msg = protowire.AppendVarint(msg, protowire.EncodeZigZag(v.Int64()))
+	case slog.KindUint64:
+		msg = protowire.AppendTag(msg, protoAttrUint64, protowire.VarintType)
+		msg = protowire.AppendVarint(msg, v.Uint64())
+	case slog.KindFloat64:
+		msg = protowire.AppendTag(msg, protoAttrFloat64, protowire.Fixed64Type)
+		msg = protowire.AppendFixed64(msg, math.Float64bits(v.Float64()))
+	case slog.KindBool:
+		msg = protowire.AppendTag(msg, protoAttrBool, protowire.VarintType)
+		msg = protowire.AppendVarint(msg, protowire.EncodeBool(v.Bool()))
+	case slog.KindTime:
+		msg = appendProtoTimestamp(msg, protoAttrTime, v.Time())
+	case slog.KindDuration:
+		d := v.Duration()
+		msg = appendProtoSecondsNanos(msg, protoAttrDuration, int64(d/time.Second), int64(d%time.Second))
+	case slog.KindGroup:
+		var group []byte
+		var err error
+		for _, member := range v.Group() {
+			if group, err = appendProtoAttr(group, protoGroupAttrs, member); err != nil {
+				return nil, err
+			}
+		}
+		msg = protowire.AppendTag(msg, protoAttrGroup, protowire.BytesType)
+		msg = protowire.AppendBytes(msg, group)
+	default:
+		switch x := v.Any().(type) {
+		case []byte:
+			msg = protowire.AppendTag(msg, protoAttrBytes, protowire.BytesType)
+			msg = protowire.AppendBytes(msg, x)
+		case error:
+			msg = protowire.AppendTag(msg, protoAttrString, protowire.BytesType)
+			msg = protowire.AppendString(msg, x.Error()) // Like the JSON format does
+		case slog.Level:
+			msg = protowire.AppendTag(msg, protoAttrString, protowire.BytesType)
+			msg = protowire.AppendString(msg, x.String())
+		default:
+			b, err := json.Marshal(x)
+			if err != nil {
+				return nil, err
+			}
+			msg = protowire.AppendTag(msg, protoAttrJSON, protowire.BytesType)
+			msg = protowire.AppendBytes(msg, b)
+		}
+	}
+
+	buf = protowire.AppendTag(buf, num, protowire.BytesType)
+	return protowire.AppendBytes(buf, msg), nil
+}
+
+// appendProtoTimestamp appends t as the google.protobuf.Timestamp field num
+func appendProtoTimestamp(buf []byte, num protowire.Number, t time.Time) []byte {
+	return appendProtoSecondsNanos(buf, num, t.Unix(), int64(t.Nanosecond()))
+}
+
+// appendProtoSecondsNanos appends the field num holding a message with
+// seconds and nanos, the layout of google.protobuf.Timestamp and Duration
+func appendProtoSecondsNanos(buf []byte, num protowire.Number, seconds, nanos int64) []byte {
+	var msg []byte
+	if seconds != 0 {
+		msg = protowire.AppendTag(msg, 1, protowire.VarintType)
+		msg = protowire.AppendVarint(msg, uint64(seconds)) //nolint:gosec
+	}
+	if nanos != 0 {
+		msg = protowire.AppendTag(msg, 2, protowire.VarintType)
+		msg = protowire.AppendVarint(msg, uint64(nanos)) //nolint:gosec
+	}
+	buf = protowire.AppendTag(buf, num, protowire.BytesType)
+	return protowire.AppendBytes(buf, msg)
+}
@@ -0,0 +1,218 @@
+package grovelog
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Records is a list of captured records that can be sliced with Where:
+//
+//	warns := h.Records().Where("level", ">=", "WARN").Where("req.method", "=", "GET")
+type Records []Record
+
+// Where returns the records whose field key compares to value with op.
+//
+// Keys "level", "msg" and "time" select the built-in fields, any other key
+// is the path of an attribute, groups separated by ".". Records without
+// the attribute never match.
+//
+// The operators are "=", "!=", "<", "<=", ">", ">=" and "~", which matches
+// the value as text against a regular expression given as a string or
+// *regexp.Regexp. Levels compare to slog.Level values or level names,
+// numbers, durations and times to values of their kind or their text.
+// Other values compare as text.
+//
+// Where panics on unknown operators and invalid patterns
+func (rs Records) Where(key, op string, value any) Records {
+	match := matcher(op, value)
+
+	var out Records
+	for i := range rs {
+		if v, ok := rs[i].lookup(key); ok && match(v) {
+			out = append(out, rs[i])
+		}
+	}
+	return out
+}
+
+// Messages returns the messages of the records
+func (rs Records) Messages() []string {
+	msgs := make([]string, len(rs))
+	for i := range rs {
+		msgs[i] = rs[i].Message
+	}
+	return msgs
+}
+
+// lookup returns the value of the field key
+func (r *Record) lookup(key string) (slog.Value, bool) {
+	switch key {
+	case slog.LevelKey:
+		return slog.AnyValue(r.Level), true
+	case slog.MessageKey:
+		return slog.StringValue(r.Message), true
+	case slog.TimeKey:
+		return slog.TimeValue(r.Time), true
+	}
+
+	attrs := r.Attrs
+	path := strings.Split(key, ".")
+	for i, name := range path {
+		j := len(attrs) - 1 // The last attribute wins, like in the JSON format
+		for j >= 0 && attrs[j].Key != name {
+			j--
+		}
+		if j < 0 {
+			return slog.Value{}, false
+		}
+		if i == len(path)-1 {
+			return attrs[j].Value, true
+		}
+		if attrs[j].Value.Kind() != slog.KindGroup {
+			return slog.Value{}, false
+		}
+		attrs = attrs[j].Value.Group()
+	}
+	return slog.Value{}, false
+}
+
+// matcher returns a function reporting whether a value compares to target with op
+func matcher(op string, target any) func(slog.Value) bool {
+	if op == "~" {
+		re, ok := target.(*regexp.Regexp)
+		if !ok {
+			re = regexp.MustCompile(fmt.Sprint(target))
+		}
+		return func(v slog.Value) bool { return re.MatchString(valueText(v)) }
+	}
+
+	var accept func(int) bool
+	switch op {
+	case "=", "==":
+		accept = func(c int) bool { return c == 0 }
+	case "!=":
+		accept = func(c int) bool { return c != 0 }
+	case "<":
+		accept = func(c int) bool { return c < 0 }
+	case "<=":
+		accept = func(c int) bool { return c <= 0 }
+	case ">":
+		accept = func(c int) bool { return c > 0 }
+	case ">=":
+		accept = func(c int) bool { return c >= 0 }
+	default:
+		panic(fmt.Sprintf("grovelog: unknown query operator %q", op))
+	}
+	return func(v slog.Value) bool {
+		c, ok := compareValue(v, target)
+		return ok && accept(c)
+	}
+}
+
+// compareValue compares v to target, converting target to the kind of v.
+// It reports false if target can't be converted
+func compareValue(v slog.Value, target any) (int, bool) {
+	switch v.Kind() {
+	case slog.KindInt64, slog.KindUint64, slog.KindFloat64:
+		t, ok := toFloat(target)
+		return cmp.Compare(toFloatValue(v), t), ok
+	case slog.KindDuration:
+		var t time.Duration
+		switch x := target.(type) {
+		case time.Duration:
+			t = x
+		case string:
+			d, err := time.ParseDuration(x)
+			if err != nil {
+				return 0, false
+			}
+			t = d
+		default:
+			return 0, false
+		}
+		return cmp.Compare(v.Duration(), t), true
+	case slog.KindTime:
+		var t time.Time
+		switch x := target.(type) {
+		case time.Time:
+			t = x
+		case string:
+			parsed, err := time.Parse(time.RFC3339Nano, x)
+			if err != nil {
+				return 0, false
+			}
+			t = parsed
+		default:
+			return 0, false
+		}
+		return v.Time().Compare(t), true
+	case slog.KindAny:
+		if level, ok := v.Any().(slog.Level); ok {
+			t, ok := toLevel(target)
+			return cmp.Compare(level, t), ok
+		}
+	}
+	return strings.Compare(valueText(v), fmt.Sprint(target)), true
+}
+
+// toFloat converts numbers and numeric text to float64
+func toFloat(target any) (float64, bool) {
+	switch x := target.(type) {
+	case string:
+		f, err := strconv.ParseFloat(x, 64)
+		return f, err == nil
+	case float64:
+		return x, true
+	case float32:
+		return float64(x), true
+	}
+	v := slog.AnyValue(target)
+	switch v.Kind() {
+	case slog.KindInt64, slog.KindUint64:
+		return toFloatValue(v), true
+	default:
+		return 0, false
+	}
+}
+
+// toFloatValue returns the numeric value v as float64
+func toFloatValue(v slog.Value) float64 {
+	switch v.Kind() {
+	case slog.KindInt64:
+		return float64(v.Int64())
+	case slog.KindUint64:
+		return float64(v.Uint64())
+	default:
+		return v.Float64()
+	}
+}
+
+// toLevel converts levels, level names such as "WARN" or "INFO+2" and
+// numbers to slog.Level
+func toLevel(target any) (slog.Level, bool) {
+	switch x := target.(type) {
+	case slog.Level:
+		return x, true
+	case slog.Leveler:
+		return x.Level(), true
+	case string:
+		var level slog.Level
+		return level, level.UnmarshalText([]byte(x)) == nil
+	case int:
+		return slog.Level(x), true
+	}
+	return 0, false
+}
+
+// valueText returns v as text, with times in RFC 3339 format
+func valueText(v slog.Value) string {
+	if v.Kind() == slog.KindTime {
+		return v.Time().Format(time.RFC3339Nano)
+	}
+	return v.String()
+}
@@ -0,0 +1,246 @@
+package grovelog
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// replaceAttrFunc has the signature of slog.HandlerOptions.ReplaceAttr
+type replaceAttrFunc func(groups []string, a slog.Attr) slog.Attr
+
+// slogOptions returns a copy of opts.SlogOpts with ReplaceAttr set to the
+// composed attribute pipeline, for use with the standard slog handlers
+func slogOptions(opts Options) *slog.HandlerOptions {
+	so := *opts.SlogOpts
+	so.ReplaceAttr = buildReplaceAttr(opts)
+	return &so
+}
+
+// buildReplaceAttr composes the user supplied ReplaceAttr with the built-in
+// attribute stages enabled in opts. The built-in time, level, message and
+// source attributes and the attributes of the user go through separate
+// stages, callers mark top-level user attributes with built-in keys with
+// markUserAttr. Returns nil if no stage is enabled
+func buildReplaceAttr(opts Options) replaceAttrFunc { //nolint:cyclop
+	var builtin, user []replaceAttrFunc
+	if loc := opts.location(); loc != nil {
+		builtin = append(builtin, convertTime(loc))
+	}
+	if opts.SlogOpts != nil && opts.SlogOpts.ReplaceAttr != nil {
+		builtin = append(builtin, opts.SlogOpts.ReplaceAttr)
+		user = append(user, opts.SlogOpts.ReplaceAttr)
+	}
+	if opts.ValidateAttr != nil {
+		user = append(user, validateAttrs(opts.ValidateAttr, opts.OnDrop))
+	}
+	if _, ok := epochValue(time.Time{}, opts.TimeFormat); ok {
+		builtin = append(builtin, epochTime(opts.TimeFormat))
+	}
+	if opts.ExpandErrors {
+		user = append(user, expandErrors(opts.VerboseErrors))
+	}
+	if opts.TrimSourcePrefix != "" || opts.ShortSource {
+		builtin = append(builtin, trimSource(opts.TrimSourcePrefix, opts.ShortSource))
+	}
+	if opts.AllowedKeys != nil {
+		user = append(user, allowKeys(opts.AllowedKeys, opts.OnDrop))
+	}
+	if opts.TimeKey != "" || opts.LevelKey != "" || opts.MessageKey != "" {
+		builtin = append(builtin, renameBuiltinKeys(opts.TimeKey, opts.LevelKey, opts.MessageKey))
+	}
+
+	if len(builtin) == 0 && len(user) == 0 {
+		return nil
+	}
+
+	return func(groups []string, a slog.Attr) slog.Attr {
+		stages := user
+		if u, ok := a.Value.Any().(userAttr); ok && a.Value.Kind() == slog.KindAny {
+			a.Value = u.value.Resolve()
+		} else if len(groups) == 0 && isBuiltinKey(a.Key) {
+			stages = builtin
+		}
+		for _, stage := range stages {
+			if a = stage(groups, a); a.Key == "" {
+				return slog.Attr{}
+			}
+		}
+		return a
+	}
+}
+
+// errorMessages returns next followed by a stage rendering error values
+// as their message, as in the other formats. The slog TextHandler would
+// otherwise render them with %+v, e.g. dumping the stack traces of
+// github.com/pkg/errors
+func errorMessages(next replaceAttrFunc) replaceAttrFunc {
+	return func(groups []string, a slog.Attr) slog.Attr {
+		if next != nil {
+			a = next(groups, a)
+		}
+		if err, ok := a.Value.Any().(error); ok && a.Value.Kind() == slog.KindAny {
+			a.Value = slog.StringValue(err.Error())
+		}
+		return a
+	}
+}
+
+// userAttr marks a top-level user attribute whose key is a built-in key,
+// e.g. "level" in logger.Info("saved", "level", 3), so the pipeline
+// doesn't take it for the built-in attribute
+type userAttr struct {
+	value slog.Value
+}
+
+// markUserAttr marks the user attribute a, nested in groups, if its key
+// is a built-in key, to be passed to the pipeline of buildReplaceAttr
+func markUserAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && isBuiltinKey(a.Key) && a.Value.Kind() != slog.KindGroup {
+		a.Value = slog.AnyValue(userAttr{value: a.Value})
+	}
+	return a
+}
+
+// userKeyHandler marks the user attributes with built-in keys for the
+// pipeline of buildReplaceAttr before the standard slog handlers, which
+// pass the built-in and the user attributes to the same ReplaceAttr
+type userKeyHandler struct {
+	next   slog.Handler
+	nested bool // A group is open, attributes aren't top-level
+}
+
+// Enabled reports whether the wrapped handler handles level
+func (h *userKeyHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle marks the top-level attributes with built-in keys and passes the record on
+func (h *userKeyHandler) Handle(ctx context.Context, r slog.Record) error { //nolint:gocritic
+	if h.nested || !hasBuiltinKey(r) {
+		return h.next.Handle(ctx, r)
+	}
+	marked := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		marked.AddAttrs(h.mark(a))
+		return true
+	})
+	return h.next.Handle(ctx, marked)
+}
+
+// WithAttrs returns a userKeyHandler wrapping the handler with the marked attrs
+func (h *userKeyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if !h.nested {
+		attrs = slices.Clone(attrs)
+		for i, a := range attrs {
+			attrs[i] = h.mark(a)
+		}
+	}
+	return &userKeyHandler{next: h.next.WithAttrs(attrs), nested: h.nested}
+}
+
+// WithGroup returns a userKeyHandler wrapping the grouped handler
+func (h *userKeyHandler) WithGroup(name string) slog.Handler {
+	return &userKeyHandler{next: h.next.WithGroup(name), nested: h.nested || name != ""}
+}
+
+// mark resolves and marks a top-level attribute
+func (h *userKeyHandler) mark(a slog.Attr) slog.Attr {
+	if !isBuiltinKey(a.Key) {
+		return a
+	}
+	a.Value = a.Value.Resolve()
+	return markUserAttr(nil, a)
+}
+
+// hasBuiltinKey reports whether an attribute of r has a built-in key
+func hasBuiltinKey(r slog.Record) bool { //nolint:gocritic
+	found := false
+	r.Attrs(func(a slog.Attr) bool {
+		found = isBuiltinKey(a.Key)
+		return !found
+	})
+	return found
+}
+
+// allowKeys drops every attribute whose full key is not in keys. It only
+// sees user attributes, the built-in ones are always kept
+func allowKeys(keys []string, onDrop func(key string)) replaceAttrFunc {
+	allowed := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		allowed[k] = struct{}{}
+	}
+
+	return func(groups []string, a slog.Attr) slog.Attr {
+		key := joinKey(groups, a.Key)
+		if _, ok := allowed[key]; ok {
+			return a
+		}
+		if onDrop != nil {
+			onDrop(key)
+		}
+		return slog.Attr{}
+	}
+}
+
+// validateAttrs drops every attribute failing validate. It only sees
+// user attributes, the built-in ones are always kept
+func validateAttrs(validate func(groups []string, a slog.Attr) error, onDrop func(key string)) replaceAttrFunc {
+	return func(groups []string, a slog.Attr) slog.Attr {
+		if validate(groups, a) == nil {
+			return a
+		}
+		if onDrop != nil {
+			onDrop(joinKey(groups, a.Key))
+		}
+		return slog.Attr{}
+	}
+}
+
+// convertTime converts the built-in time attribute to loc
+func convertTime(loc *time.Location) replaceAttrFunc {
+	return func(groups []string, a slog.Attr) slog.Attr {
+		if len(groups) == 0 && a.Key == slog.TimeKey && a.Value.Kind() == slog.KindTime {
+			a.Value = slog.TimeValue(a.Value.Time().In(loc))
+		}
+		return a
+	}
+}
+
+// renameBuiltinKeys renames the built-in time, level and message
+// attributes, empty names keep the slog defaults
+func renameBuiltinKeys(timeKey, levelKey, messageKey string) replaceAttrFunc {
+	names := map[string]string{
+		slog.TimeKey:    timeKey,
+		slog.LevelKey:   levelKey,
+		slog.MessageKey: messageKey,
+	}
+
+	return func(groups []string, a slog.Attr) slog.Attr {
+		if len(groups) > 0 {
+			return a
+		}
+		if name := names[a.Key]; name != "" {
+			a.Key = name
+		}
+		return a
+	}
+}
+
+func isBuiltinKey(key string) bool {
+	switch key {
+	case slog.TimeKey, slog.LevelKey, slog.MessageKey, slog.SourceKey:
+		return true
+	}
+	return false
+}
+
+// joinKey builds the dotted key of an attribute nested in groups
+func joinKey(groups []string, key string) string {
+	if len(groups) == 0 {
+		return key
+	}
+	return strings.Join(groups, ".") + "." + key
+}
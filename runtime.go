@@ -0,0 +1,39 @@
+package grovelog
+
+import (
+	"log/slog"
+	"runtime"
+	"time"
+
+	"github.com/AlonMell/grovelog/util"
+)
+
+// RuntimeKey is the key of the group added by RuntimeStats
+const RuntimeKey = "runtime"
+
+// RuntimeStats returns a RuntimeKey group with runtime.MemStats highlights
+// and the goroutine count. It stops the world briefly to read the stats
+func RuntimeStats() []slog.Attr {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	return []slog.Attr{slog.Group(RuntimeKey,
+		slog.Any("heap_alloc", util.ByteSize(m.HeapAlloc)), //nolint:gosec
+		slog.Any("heap_sys", util.ByteSize(m.HeapSys)),     //nolint:gosec
+		slog.Uint64("heap_objects", m.HeapObjects),
+		slog.Uint64("num_gc", uint64(m.NumGC)),
+		slog.Duration("gc_pause_total", time.Duration(m.PauseTotalNs)),              //nolint:gosec
+		slog.Duration("gc_pause_last", time.Duration(m.PauseNs[(m.NumGC+255)%256])), //nolint:gosec
+		slog.Int("goroutines", runtime.NumGoroutine()),
+	)}
+}
+
+// RuntimeMetrics returns a stopped Heartbeat logging RuntimeStats every
+// interval, for environments without a metrics stack
+func (l *Logger) RuntimeMetrics(interval time.Duration) *Heartbeat {
+	return l.Heartbeat(HeartbeatOptions{
+		Interval: interval,
+		Message:  "runtime metrics",
+		Gauges:   RuntimeStats,
+	})
+}
@@ -0,0 +1,74 @@
+package grovelog
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// Once returns a Logger emitting only the first record of every call
+// site, e.g. for startup warnings. The records are counted per Logger
+// Once is called on, so it can be called inside loops:
+//
+//	for _, key := range keys {
+//		logger.Once().Warn("config key is deprecated")
+//	}
+func (l *Logger) Once() *Logger {
+	return &Logger{Logger: slog.New(&sampleHandler{next: l.Handler(), counts: &l.samples})}
+}
+
+// Every returns a Logger emitting the first and then every n-th record
+// of every call site, e.g. inside hot loops. Like for Once, the records
+// are counted per Logger Every is called on and per n
+func (l *Logger) Every(n uint64) *Logger {
+	if n <= 1 {
+		return l
+	}
+	return &Logger{Logger: slog.New(&sampleHandler{next: l.Handler(), every: n, counts: &l.samples})}
+}
+
+// sampleKey identifies the counter of a call site sampled with every
+type sampleKey struct {
+	every uint64
+	pc    uintptr
+}
+
+// sampleHandler drops records based on a counter per call site,
+// every == 0 keeps the first record only
+type sampleHandler struct {
+	next   slog.Handler
+	every  uint64
+	counts *sync.Map // sampleKey -> *atomic.Uint64, owned by the parent Logger
+}
+
+// Enabled reports whether the wrapped handler handles level
+func (h *sampleHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle passes the record on if it is sampled for its call site
+func (h *sampleHandler) Handle(ctx context.Context, r slog.Record) error { //nolint:gocritic
+	v, _ := h.counts.LoadOrStore(sampleKey{every: h.every, pc: r.PC}, new(atomic.Uint64))
+	count, _ := v.(*atomic.Uint64)
+	n := count.Add(1) - 1
+	if h.every == 0 && n > 0 || h.every > 0 && n%h.every != 0 {
+		return nil
+	}
+	return h.next.Handle(ctx, r)
+}
+
+// WithAttrs returns a sampleHandler wrapping the handler with attrs
+func (h *sampleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &sampleHandler{next: h.next.WithAttrs(attrs), every: h.every, counts: h.counts}
+}
+
+// WithGroup returns a sampleHandler wrapping the grouped handler
+func (h *sampleHandler) WithGroup(name string) slog.Handler {
+	return &sampleHandler{next: h.next.WithGroup(name), every: h.every, counts: h.counts}
+}
+
+// Drain drains the wrapped handler
+func (h *sampleHandler) Drain(ctx context.Context) error {
+	return drain(ctx, h.next)
+}
@@ -0,0 +1,157 @@
+package grovelog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// SchemaViolationKey is the key of the attribute listing the required
+// keys missing from a record. Like other record attributes, it is nested
+// in the groups opened with WithGroup
+const SchemaViolationKey = "schema_violation"
+
+// SchemaOptions configures NewSchemaHandler
+type SchemaOptions struct {
+	// Required maps a level to the keys records at that level and above
+	// must carry, groups separated by ".", e.g.
+	//
+	//	map[slog.Level][]string{slog.LevelError: {"error", "op"}}
+	Required map[slog.Level][]string
+	// Fail is called with a *SchemaError for every violation, e.g. to
+	// panic in development or call t.Error in tests. If nil, violating
+	// records get a SchemaViolationKey attribute listing the missing keys
+	Fail func(err error)
+}
+
+// SchemaError describes a record missing required keys
+type SchemaError struct {
+	Level   slog.Level
+	Message string
+	Missing []string
+}
+
+// Error implements error
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("grovelog: %s record %q is missing required keys %s",
+		e.Level, e.Message, strings.Join(e.Missing, ", "))
+}
+
+// NewSchemaHandler returns a handler checking that records passed to next
+// carry the keys required for their level, whether they were added to the
+// record or with WithAttrs. LogValuers are resolved once, before the check,
+// and next gets the resolved attributes. Context attributes set with
+// util.UpdateLogCtx aren't seen by the check, as only the Color format adds
+// them to the record, so required keys must not rely on them
+func NewSchemaHandler(next slog.Handler, opts SchemaOptions) slog.Handler {
+	return &schemaHandler{next: next, opts: &opts}
+}
+
+// schemaHandler validates records against SchemaOptions.Required
+type schemaHandler struct {
+	next   slog.Handler
+	opts   *SchemaOptions
+	prefix string   // Open groups, each followed by "."
+	keys   []string // Keys added with WithAttrs, with their groups
+}
+
+// Enabled reports whether the wrapped handler handles level
+func (h *schemaHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle reports or annotates missing required keys, then passes the record on
+func (h *schemaHandler) Handle(ctx context.Context, r slog.Record) error { //nolint:gocritic
+	required := h.required(r.Level)
+	if len(required) == 0 {
+		return h.next.Handle(ctx, r)
+	}
+
+	// Resolve the LogValuers once, next gets the resolved record
+	var attrs []slog.Attr
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = appendResolved(attrs, a)
+		return true
+	})
+	r = slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.AddAttrs(attrs...)
+
+	keys := slices.Clone(h.keys)
+	for _, a := range attrs {
+		keys = appendKeys(keys, h.prefix, a)
+	}
+	var missing []string
+	for _, key := range required {
+		if !slices.Contains(keys, key) && !slices.Contains(missing, key) {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return h.next.Handle(ctx, r)
+	}
+	slices.Sort(missing)
+
+	if h.opts.Fail != nil {
+		h.opts.Fail(&SchemaError{Level: r.Level, Message: r.Message, Missing: missing})
+		return h.next.Handle(ctx, r)
+	}
+	r.AddAttrs(slog.Any(SchemaViolationKey, missing))
+	return h.next.Handle(ctx, r)
+}
+
+// WithAttrs returns a schemaHandler wrapping the handler with the resolved attrs
+func (h *schemaHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var resolved []slog.Attr
+	for _, a := range attrs {
+		resolved = appendResolved(resolved, a)
+	}
+
+	h2 := *h
+	h2.next = h.next.WithAttrs(resolved)
+	h2.keys = slices.Clip(h.keys)
+	for _, a := range resolved {
+		h2.keys = appendKeys(h2.keys, h.prefix, a)
+	}
+	return &h2
+}
+
+// WithGroup returns a schemaHandler wrapping the grouped handler
+func (h *schemaHandler) WithGroup(name string) slog.Handler {
+	h2 := *h
+	h2.next = h.next.WithGroup(name)
+	if name != "" {
+		h2.prefix += name + "."
+	}
+	return &h2
+}
+
+// required returns the keys required at level
+func (h *schemaHandler) required(level slog.Level) []string {
+	var keys []string
+	for from, required := range h.opts.Required {
+		if level >= from {
+			keys = append(keys, required...)
+		}
+	}
+	return keys
+}
+
+// appendKeys appends the key of the resolved attribute a and, for groups,
+// the keys of its members
+func appendKeys(keys []string, prefix string, a slog.Attr) []string {
+	if a.Value.Kind() != slog.KindGroup {
+		if a.Key == "" {
+			return keys
+		}
+		return append(keys, prefix+a.Key)
+	}
+
+	keys = append(keys, prefix+a.Key)
+	prefix += a.Key + "."
+	for _, m := range a.Value.Group() {
+		keys = appendKeys(keys, prefix, m)
+	}
+	return keys
+}
@@ -0,0 +1,78 @@
+package grovelog
+
+import (
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// recordSource returns the source location of the record's call site
+func recordSource(pc uintptr) *slog.Source {
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	return &slog.Source{Function: frame.Function, File: frame.File, Line: frame.Line}
+}
+
+// trimSource rewrites the file of the built-in source attribute: prefix is
+// trimmed from the path and short keeps only the package directory and
+// file name, like zap's TrimmedPath
+func trimSource(prefix string, short bool) replaceAttrFunc {
+	return func(groups []string, a slog.Attr) slog.Attr {
+		if len(groups) > 0 || a.Key != slog.SourceKey {
+			return a
+		}
+		src, ok := a.Value.Any().(*slog.Source)
+		if !ok || src == nil {
+			return a
+		}
+
+		trimmed := *src
+		trimmed.File = trimPath(src.File, prefix, short)
+		a.Value = slog.AnyValue(&trimmed)
+		return a
+	}
+}
+
+// trimPath shortens file according to prefix and short
+func trimPath(file, prefix string, short bool) string {
+	if prefix != "" {
+		if rel, ok := strings.CutPrefix(file, prefix); ok {
+			file = strings.TrimLeft(rel, `/\`)
+		}
+	}
+	if short {
+		dir, name := filepath.Split(filepath.ToSlash(file))
+		file = filepath.Base(dir) + "/" + name
+	}
+	return file
+}
+
+// sourceSuffix renders the call site as a compact " (pkg.Function file:line)"
+// suffix for Color output, after applying the attribute pipeline
+func (h *Handler) sourceSuffix(pc uintptr) string {
+	a := slog.Any(slog.SourceKey, recordSource(pc))
+	if h.replace != nil {
+		if a = h.replace(nil, a); a.Key == "" {
+			return ""
+		}
+	}
+
+	text := a.Value.String()
+	if src, ok := a.Value.Any().(*slog.Source); ok {
+		text = src.File + ":" + strconv.Itoa(src.Line)
+		if src.Function != "" {
+			text = shortFunction(src.Function) + " " + text
+		}
+	}
+	return " " + h.paint(h.theme.Time, "("+text+")")
+}
+
+// shortFunction strips the import path directories from a function name,
+// leaving e.g. "pkg.(*Server).handleLogin"
+func shortFunction(function string) string {
+	if i := strings.LastIndex(function, "/"); i >= 0 {
+		return function[i+1:]
+	}
+	return function
+}
@@ -0,0 +1,62 @@
+package grovelog
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+)
+
+// NewStdioLogger returns a Logger writing Debug and Info records to stdout
+// and Warn and Error records to stderr in the same format, for container
+// log collectors that tell the streams apart
+func NewStdioLogger(opts Options) *Logger { //nolint:gocritic
+	h := NewLevelSplitHandler(NewHandler(os.Stdout, opts), NewHandler(os.Stderr, opts), slog.LevelWarn)
+	return Wrap(slog.New(h))
+}
+
+// NewLevelSplitHandler returns a handler passing records below split to
+// low and the others to high
+func NewLevelSplitHandler(low, high slog.Handler, split slog.Leveler) slog.Handler {
+	return &splitHandler{low: low, high: high, split: split}
+}
+
+// splitHandler routes records to one of two handlers by level
+type splitHandler struct {
+	low   slog.Handler
+	high  slog.Handler
+	split slog.Leveler
+}
+
+// route returns the handler of level
+func (h *splitHandler) route(level slog.Level) slog.Handler {
+	if level >= h.split.Level() {
+		return h.high
+	}
+	return h.low
+}
+
+// Enabled reports whether the handler of level handles it
+func (h *splitHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.route(level).Enabled(ctx, level)
+}
+
+// Handle passes the record to the handler of its level
+func (h *splitHandler) Handle(ctx context.Context, r slog.Record) error { //nolint:gocritic
+	return h.route(r.Level).Handle(ctx, r)
+}
+
+// WithAttrs returns a splitHandler of the handlers with attrs
+func (h *splitHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &splitHandler{low: h.low.WithAttrs(attrs), high: h.high.WithAttrs(attrs), split: h.split}
+}
+
+// WithGroup returns a splitHandler of the grouped handlers
+func (h *splitHandler) WithGroup(name string) slog.Handler {
+	return &splitHandler{low: h.low.WithGroup(name), high: h.high.WithGroup(name), split: h.split}
+}
+
+// Drain drains both handlers
+func (h *splitHandler) Drain(ctx context.Context) error {
+	return errors.Join(drain(ctx, h.low), drain(ctx, h.high))
+}
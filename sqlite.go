@@ -0,0 +1,104 @@
+package grovelog
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// sqliteTimeFormat stores times as fixed width UTC text, which sorts
+// chronologically and which the SQLite date functions read
+const sqliteTimeFormat = "2006-01-02T15:04:05.000Z"
+
+// SQLiteOptions configures NewSQLiteHandler
+type SQLiteOptions struct {
+	// Table is the name of the table, "logs" if empty
+	Table string
+	// BatchOptions configures the buffering, every flush inserts the
+	// batch in one transaction
+	BatchOptions
+}
+
+// NewSQLiteHandler returns a BatchHandler inserting records into a table
+// of the SQLite database db, giving small tools a queryable log store
+// without external infrastructure. db is opened by the caller with the
+// driver of their choice and isn't closed on Drain. The database is
+// switched to WAL mode, and the table is created if needed:
+//
+//	CREATE TABLE logs (
+//		id    INTEGER PRIMARY KEY,
+//		time  TEXT NOT NULL,    -- 2006-01-02T15:04:05.000Z
+//		level INTEGER NOT NULL, -- slog.Level, e.g. 4 for WARN
+//		msg   TEXT NOT NULL,
+//		attrs TEXT NOT NULL     -- JSON object, see json_extract
+//	);
+//
+// with indexes on time and on level and time
+func NewSQLiteHandler(db *sql.DB, opts SQLiteOptions) (*BatchHandler, error) { //nolint:gocritic
+	if opts.Table == "" {
+		opts.Table = "logs"
+	}
+	table := quoteIdent(opts.Table)
+
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, "PRAGMA journal_mode=WAL"); err != nil {
+		return nil, err
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE IF NOT EXISTS " + table + ` (
+	id INTEGER PRIMARY KEY,
+	time TEXT NOT NULL,
+	level INTEGER NOT NULL,
+	msg TEXT NOT NULL,
+	attrs TEXT NOT NULL
+)`,
+		"CREATE INDEX IF NOT EXISTS " + quoteIdent(opts.Table+"_time") + " ON " + table + " (time)",
+		"CREATE INDEX IF NOT EXISTS " + quoteIdent(opts.Table+"_level_time") + " ON " + table + " (level, time)",
+	} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return nil, err
+		}
+	}
+
+	insert := "INSERT INTO " + table + " (time, level, msg, attrs) VALUES (?, ?, ?, ?)"
+	flush := func(ctx context.Context, records Records) error {
+		return insertBatch(ctx, db, insert, records, func(r *Record, attrs string) []any {
+			return []any{r.Time.UTC().Format(sqliteTimeFormat), int64(r.Level), r.Message, attrs}
+		})
+	}
+	return NewBatchHandler(flush, opts.BatchOptions), nil
+}
+
+// insertBatch inserts the records in one transaction with the prepared
+// statement insert, args returns its arguments given a record and its
+// attributes as a JSON object
+func insertBatch(ctx context.Context, db *sql.DB, insert string, records Records, args func(r *Record, attrs string) []any) (err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	stmt, err := tx.PrepareContext(ctx, insert)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = stmt.Close() }()
+
+	for i := range records {
+		attrs := recordAttrsJSON(&records[i])
+		if _, err := stmt.ExecContext(ctx, args(&records[i], string(attrs))...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// quoteIdent quotes a SQL identifier
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,41 @@
+package grovelog
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/AlonMell/grovelog/util"
+)
+
+// StackKey is the key of the stack trace attribute added by Options.StackTraceLevel
+const StackKey = util.StackKey
+
+// stackHandler attaches a stack trace to records at or above level
+type stackHandler struct {
+	next  slog.Handler
+	level slog.Leveler
+}
+
+// Enabled reports whether the wrapped handler handles level
+func (h *stackHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle adds the stack attribute to severe records and passes them on
+func (h *stackHandler) Handle(ctx context.Context, r slog.Record) error { //nolint:gocritic
+	if r.Level >= h.level.Level() {
+		r = r.Clone()
+		r.AddAttrs(slog.Any(StackKey, util.StackFrames(0)))
+	}
+	return h.next.Handle(ctx, r)
+}
+
+// WithAttrs returns a stackHandler wrapping the handler with attrs
+func (h *stackHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &stackHandler{next: h.next.WithAttrs(attrs), level: h.level}
+}
+
+// WithGroup returns a stackHandler wrapping the grouped handler
+func (h *stackHandler) WithGroup(name string) slog.Handler {
+	return &stackHandler{next: h.next.WithGroup(name), level: h.level}
+}
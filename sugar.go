@@ -0,0 +1,84 @@
+package grovelog
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Logger extends slog.Logger with printf-style methods, easing the
+// migration from logrus or the standard log package
+type Logger struct {
+	*slog.Logger
+
+	samples sync.Map // sampleKey -> *atomic.Uint64, call site counts of Once and Every
+}
+
+// Wrap returns a Logger logging through l
+func Wrap(l *slog.Logger) *Logger {
+	return &Logger{Logger: l}
+}
+
+// With returns a Logger that includes the given attributes in each output
+func (l *Logger) With(args ...any) *Logger {
+	return &Logger{Logger: l.Logger.With(args...)}
+}
+
+// WithGroup returns a Logger that starts a group
+func (l *Logger) WithGroup(name string) *Logger {
+	return &Logger{Logger: l.Logger.WithGroup(name)}
+}
+
+// Debugf logs at Debug level, see Infof
+func (l *Logger) Debugf(format string, args ...any) {
+	l.logf(slog.LevelDebug, format, args)
+}
+
+// Infof logs at Info level with the message formatted by fmt.Sprintf.
+// Trailing slog.Attr arguments are added as attributes instead of
+// being formatted:
+//
+//	logger.Infof("user %s logged in", name, slog.Int("id", id))
+func (l *Logger) Infof(format string, args ...any) {
+	l.logf(slog.LevelInfo, format, args)
+}
+
+// Warnf logs at Warn level, see Infof
+func (l *Logger) Warnf(format string, args ...any) {
+	l.logf(slog.LevelWarn, format, args)
+}
+
+// Errorf logs at Error level, see Infof
+func (l *Logger) Errorf(format string, args ...any) {
+	l.logf(slog.LevelError, format, args)
+}
+
+// logf formats and logs a record with the source of the caller of its
+// caller. The message isn't formatted when the level is disabled
+func (l *Logger) logf(level slog.Level, format string, args []any) {
+	ctx := context.Background()
+	if !l.Enabled(ctx, level) {
+		return
+	}
+
+	n := len(args)
+	for n > 0 {
+		if _, ok := args[n-1].(slog.Attr); !ok {
+			break
+		}
+		n--
+	}
+
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:]) // Skip Callers, logf and the level method
+	r := slog.NewRecord(time.Now(), level, fmt.Sprintf(format, args[:n]...), pcs[0])
+	for _, arg := range args[n:] {
+		if attr, ok := arg.(slog.Attr); ok {
+			r.AddAttrs(attr)
+		}
+	}
+	_ = l.Handler().Handle(ctx, r)
+}
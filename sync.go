@@ -0,0 +1,45 @@
+package grovelog
+
+import (
+	"context"
+	"log/slog"
+)
+
+// syncer is implemented by writers that can flush written data to
+// stable storage, like FileWriter and *os.File
+type syncer interface {
+	Sync() error
+}
+
+// syncHandler syncs the writer after records at or above level
+type syncHandler struct {
+	next  slog.Handler
+	out   syncer
+	level slog.Leveler
+}
+
+// Enabled reports whether the wrapped handler handles level
+func (h *syncHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle passes the record on and syncs the writer for severe records
+func (h *syncHandler) Handle(ctx context.Context, r slog.Record) error { //nolint:gocritic
+	if err := h.next.Handle(ctx, r); err != nil {
+		return err
+	}
+	if r.Level < h.level.Level() {
+		return nil
+	}
+	return h.out.Sync()
+}
+
+// WithAttrs returns a syncHandler wrapping the handler with attrs
+func (h *syncHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &syncHandler{next: h.next.WithAttrs(attrs), out: h.out, level: h.level}
+}
+
+// WithGroup returns a syncHandler wrapping the grouped handler
+func (h *syncHandler) WithGroup(name string) slog.Handler {
+	return &syncHandler{next: h.next.WithGroup(name), out: h.out, level: h.level}
+}
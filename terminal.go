@@ -0,0 +1,36 @@
+package grovelog
+
+import (
+	"io"
+
+	"github.com/mattn/go-isatty"
+)
+
+// fdWriter is implemented by writers backed by a file descriptor, like *os.File
+type fdWriter interface {
+	io.Writer
+	Fd() uintptr
+}
+
+// isTerminal reports whether out is an interactive terminal.
+// The second result is false when out has no file descriptor to check
+func isTerminal(out io.Writer) (terminal, known bool) {
+	f, ok := out.(fdWriter)
+	if !ok {
+		return false, false
+	}
+	fd := f.Fd()
+	return isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd), true
+}
+
+// resolveFormat replaces Auto with Color for terminals and with
+// opts.AutoFallback otherwise
+func resolveFormat(out io.Writer, opts Options) Format {
+	if opts.Format != Auto {
+		return opts.Format
+	}
+	if terminal, _ := isTerminal(out); terminal {
+		return Color
+	}
+	return opts.AutoFallback
+}
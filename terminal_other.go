@@ -0,0 +1,11 @@
+//go:build !windows
+
+package grovelog
+
+import "io"
+
+// prepareColorWriter returns out unchanged, terminals outside of
+// Windows render ANSI escapes natively
+func prepareColorWriter(out io.Writer) io.Writer {
+	return out
+}
@@ -0,0 +1,34 @@
+//go:build windows
+
+package grovelog
+
+import (
+	"io"
+	"os"
+
+	"github.com/mattn/go-colorable"
+	"golang.org/x/sys/windows"
+)
+
+// prepareColorWriter enables virtual terminal processing on Windows consoles
+// so ANSI escapes are rendered. Older consoles without VT support get a
+// writer translating the escapes into console API calls
+func prepareColorWriter(out io.Writer) io.Writer {
+	f, ok := out.(*os.File)
+	if !ok {
+		return out
+	}
+
+	h := windows.Handle(f.Fd())
+	var mode uint32
+	if err := windows.GetConsoleMode(h, &mode); err != nil {
+		return out // Not a console
+	}
+	if mode&windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING != 0 {
+		return out
+	}
+	if err := windows.SetConsoleMode(h, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING); err == nil {
+		return out
+	}
+	return colorable.NewColorable(f)
+}
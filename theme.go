@@ -0,0 +1,73 @@
+package grovelog
+
+import (
+	"log/slog"
+	"strconv"
+
+	"github.com/fatih/color"
+)
+
+// Style is a sequence of SGR attributes applied to a part of the output.
+// An empty Style leaves the text uncolored
+type Style []color.Attribute
+
+// Theme defines the colors of the Color format
+type Theme struct {
+	// Levels maps each level to the style of its label,
+	// unknown levels are rendered in white
+	Levels  map[slog.Level]Style
+	Time    Style
+	Message Style
+	Key     Style
+	Value   Style
+}
+
+// DefaultTheme returns the theme used when Options.Theme is nil
+func DefaultTheme() *Theme {
+	return &Theme{
+		Levels: map[slog.Level]Style{
+			slog.LevelDebug: {color.FgBlue},
+			slog.LevelInfo:  {color.FgGreen},
+			slog.LevelWarn:  {color.FgYellow},
+			slog.LevelError: {color.FgRed},
+		},
+		Message: Style{color.FgCyan},
+		Key:     Style{color.FgWhite},
+		Value:   Style{color.FgWhite},
+	}
+}
+
+// paint renders s with style if colors are enabled for the handler
+func (h *Handler) paint(style Style, s string) string {
+	if !h.color || len(style) == 0 {
+		return s
+	}
+	b := h.appendStyle(make([]byte, 0, len(s)+4*len(style)+6), style)
+	b = append(b, s...)
+	return string(h.appendReset(b, style))
+}
+
+// appendStyle appends the escape code starting style if colors are enabled.
+// The codes are written directly, so handlers don't share the process-wide
+// state of fatih/color
+func (h *Handler) appendStyle(buf []byte, style Style) []byte {
+	if !h.color || len(style) == 0 {
+		return buf
+	}
+	buf = append(buf, "\x1b["...)
+	for i, attr := range style {
+		if i > 0 {
+			buf = append(buf, ';')
+		}
+		buf = strconv.AppendInt(buf, int64(attr), 10)
+	}
+	return append(buf, 'm')
+}
+
+// appendReset appends the escape code ending style if colors are enabled
+func (h *Handler) appendReset(buf []byte, style Style) []byte {
+	if !h.color || len(style) == 0 {
+		return buf
+	}
+	return append(buf, "\x1b[0m"...)
+}
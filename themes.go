@@ -0,0 +1,179 @@
+package grovelog
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Names of the built-in themes accepted by ThemeByName
+const (
+	ThemeDefault       = "default"
+	ThemeSolarizedDark = "solarized-dark"
+	ThemeDracula       = "dracula"
+	ThemeMonochromeDim = "monochrome-dim"
+)
+
+// ThemeEnv is the environment variable selecting a built-in theme by name
+// when neither Options.Theme nor Options.ThemeName is set
+const ThemeEnv = "GROVELOG_THEME"
+
+// RGB returns a truecolor foreground Style. Terminals without truecolor
+// support get the closest 256 or 16 color approximation
+func RGB(r, g, b uint8) Style {
+	return Style{38, 2, color.Attribute(r), color.Attribute(g), color.Attribute(b)}
+}
+
+// Color256 returns a foreground Style from the 256 color palette
+func Color256(n uint8) Style {
+	return Style{38, 5, color.Attribute(n)}
+}
+
+// ThemeByName returns the built-in theme called name
+func ThemeByName(name string) (*Theme, bool) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case ThemeDefault:
+		return DefaultTheme(), true
+	case ThemeSolarizedDark:
+		return levelTheme(
+			RGB(0x6c, 0x71, 0xc4), RGB(0x85, 0x99, 0x00), RGB(0xb5, 0x89, 0x00), RGB(0xdc, 0x32, 0x2f),
+			RGB(0x58, 0x6e, 0x75), RGB(0x93, 0xa1, 0xa1), RGB(0x26, 0x8b, 0xd2), RGB(0x2a, 0xa1, 0x98),
+		), true
+	case ThemeDracula:
+		return levelTheme(
+			RGB(0x62, 0x72, 0xa4), RGB(0x50, 0xfa, 0x7b), RGB(0xf1, 0xfa, 0x8c), RGB(0xff, 0x55, 0x55),
+			RGB(0x62, 0x72, 0xa4), RGB(0xf8, 0xf8, 0xf2), RGB(0xbd, 0x93, 0xf9), RGB(0x8b, 0xe9, 0xfd),
+		), true
+	case ThemeMonochromeDim:
+		return levelTheme(
+			Style{color.Faint}, nil, Style{color.Bold}, Style{color.Bold, color.Underline},
+			Style{color.Faint}, nil, Style{color.Faint}, nil,
+		), true
+	}
+	return nil, false
+}
+
+func levelTheme(debug, info, warn, errStyle, timeStyle, msg, key, value Style) *Theme {
+	t := DefaultTheme()
+	t.Levels[slog.LevelDebug] = debug
+	t.Levels[slog.LevelInfo] = info
+	t.Levels[slog.LevelWarn] = warn
+	t.Levels[slog.LevelError] = errStyle
+	t.Time, t.Message, t.Key, t.Value = timeStyle, msg, key, value
+	return t
+}
+
+// resolveTheme picks the theme for opts: an explicit Theme wins over
+// ThemeName, which wins over the GROVELOG_THEME environment variable
+func resolveTheme(opts Options) *Theme {
+	if opts.Theme != nil {
+		return opts.Theme
+	}
+	for _, name := range []string{opts.ThemeName, os.Getenv(ThemeEnv)} {
+		if t, ok := ThemeByName(name); ok {
+			return t
+		}
+	}
+	return DefaultTheme()
+}
+
+// colorProfile is the color depth supported by a terminal
+type colorProfile int
+
+const (
+	profileBasic colorProfile = iota
+	profile256
+	profileTrueColor
+)
+
+// detectColorProfile inspects COLORTERM and TERM like most terminal tools do
+func detectColorProfile() colorProfile {
+	switch strings.ToLower(os.Getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return profileTrueColor
+	}
+	if strings.Contains(os.Getenv("TERM"), "256color") {
+		return profile256
+	}
+	return profileBasic
+}
+
+// downsample returns a copy of t with truecolor styles converted to profile
+func (t *Theme) downsample(profile colorProfile) *Theme {
+	if profile == profileTrueColor {
+		return t
+	}
+
+	d := &Theme{
+		Levels:  make(map[slog.Level]Style, len(t.Levels)),
+		Time:    t.Time.downsample(profile),
+		Message: t.Message.downsample(profile),
+		Key:     t.Key.downsample(profile),
+		Value:   t.Value.downsample(profile),
+	}
+	for level, style := range t.Levels {
+		d.Levels[level] = style.downsample(profile)
+	}
+	return d
+}
+
+// downsample converts the truecolor sequences of s to profile
+func (s Style) downsample(profile colorProfile) Style {
+	var out Style
+	for i := 0; i < len(s); i++ {
+		if s[i] != 38 || i+4 >= len(s) || s[i+1] != 2 {
+			out = append(out, s[i])
+			continue
+		}
+
+		r, g, b := uint8(s[i+2]), uint8(s[i+3]), uint8(s[i+4]) //nolint:gosec
+		if profile == profile256 {
+			out = append(out, 38, 5, color.Attribute(rgbTo256(r, g, b)))
+		} else {
+			out = append(out, rgbToBasic(r, g, b))
+		}
+		i += 4
+	}
+	return out
+}
+
+// rgbTo256 maps a color to the 6x6x6 cube or the gray ramp of the 256 palette
+func rgbTo256(r, g, b uint8) uint8 {
+	if r == g && g == b {
+		switch {
+		case r < 8:
+			return 16
+		case r > 248:
+			return 231
+		}
+		return uint8(232 + (int(r)-8)*24/241) //nolint:gosec
+	}
+
+	cube := func(c uint8) int { return (int(c)*5 + 127) / 255 }
+	return uint8(16 + 36*cube(r) + 6*cube(g) + cube(b)) //nolint:gosec
+}
+
+// basicPalette holds the usual RGB values of the 16 ANSI colors
+var basicPalette = [16][3]int{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// rgbToBasic returns the foreground attribute of the closest ANSI color
+func rgbToBasic(r, g, b uint8) color.Attribute {
+	best, bestDist := 0, -1
+	for i, p := range basicPalette {
+		dr, dg, db := int(r)-p[0], int(g)-p[1], int(b)-p[2]
+		if dist := dr*dr + dg*dg + db*db; bestDist < 0 || dist < bestDist {
+			best, bestDist = i, dist
+		}
+	}
+	if best < 8 {
+		return color.FgBlack + color.Attribute(best)
+	}
+	return color.FgHiBlack + color.Attribute(best-8)
+}
@@ -0,0 +1,49 @@
+package grovelog
+
+import (
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// Sentinel values for Options.TimeFormat producing numeric epoch timestamps,
+// rendered as JSON numbers by the JSON format
+const (
+	UnixSeconds = "unix"
+	UnixMillis  = "unixmilli"
+	UnixNanos   = "unixnano"
+)
+
+// epochValue converts t to the epoch format, if format is one of the sentinels
+func epochValue(t time.Time, format string) (slog.Value, bool) {
+	switch format {
+	case UnixSeconds:
+		return slog.Int64Value(t.Unix()), true
+	case UnixMillis:
+		return slog.Int64Value(t.UnixMilli()), true
+	case UnixNanos:
+		return slog.Int64Value(t.UnixNano()), true
+	}
+	return slog.Value{}, false
+}
+
+// epochTime converts the built-in time attribute to the epoch format
+func epochTime(format string) replaceAttrFunc {
+	return func(groups []string, a slog.Attr) slog.Attr {
+		if len(groups) > 0 || a.Key != slog.TimeKey || a.Value.Kind() != slog.KindTime {
+			return a
+		}
+		if v, ok := epochValue(a.Value.Time(), format); ok {
+			a.Value = v
+		}
+		return a
+	}
+}
+
+// formatTimestamp formats t with layout, which may be an epoch sentinel
+func formatTimestamp(t time.Time, layout string) string {
+	if v, ok := epochValue(t, layout); ok {
+		return strconv.FormatInt(v.Int64(), 10)
+	}
+	return t.Format(layout)
+}
@@ -0,0 +1,22 @@
+package util
+
+import "fmt"
+
+// ByteSize is a size in bytes. Logged as an attribute value it stays
+// numeric in JSON output and renders like "3.2 MiB" in Color output
+type ByteSize int64
+
+// String formats the size with binary (IEC) units
+func (b ByteSize) String() string {
+	const unit = 1024
+	if b < unit && b > -unit {
+		return fmt.Sprintf("%d B", int64(b))
+	}
+
+	value, exp := float64(b), 0
+	for value >= unit*unit || value <= -unit*unit {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", value/unit, "KMGTPE"[exp])
+}
@@ -0,0 +1,75 @@
+package util
+
+import (
+	"log/slog"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CallerKey is the key of the attribute created by Caller
+const CallerKey = "caller"
+
+var (
+	skipMu       sync.RWMutex
+	skipPrefixes = []string{
+		"log/slog.",
+		"github.com/AlonMell/grovelog.",
+		"github.com/AlonMell/grovelog/audit.",
+		"github.com/AlonMell/grovelog/util.",
+		"github.com/AlonMell/grovelog/zapbridge.",
+	}
+)
+
+// SkipCallerPackages registers function name prefixes, such as in-house
+// logging wrapper packages ("example.com/app/logx."), whose frames are
+// skipped when detecting the caller
+func SkipCallerPackages(prefixes ...string) {
+	skipMu.Lock()
+	defer skipMu.Unlock()
+	skipPrefixes = append(skipPrefixes, prefixes...)
+}
+
+// IsInternalFrame reports whether function belongs to slog, the logging
+// packages of grovelog or a package registered with SkipCallerPackages.
+// Programs in the module, such as its examples, aren't internal
+func IsInternalFrame(function string) bool {
+	skipMu.RLock()
+	defer skipMu.RUnlock()
+	for _, prefix := range skipPrefixes {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// CallerFrame walks the stack of the calling goroutine and returns the
+// first frame outside of the logging packages, so no skip count has to be
+// guessed and wrappers can be refactored freely
+func CallerFrame() (runtime.Frame, bool) {
+	var pcs [32]uintptr
+	n := runtime.Callers(2, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !IsInternalFrame(frame.Function) {
+			return frame, frame.PC != 0
+		}
+		if !more {
+			return runtime.Frame{}, false
+		}
+	}
+}
+
+// Caller creates a slog.Attr with key "caller" and the file:line of the
+// first frame outside of the logging packages as value
+// Returns an empty Attr if the caller can't be determined
+func Caller() slog.Attr {
+	frame, ok := CallerFrame()
+	if !ok {
+		return slog.Attr{}
+	}
+	return slog.String(CallerKey, frame.File+":"+strconv.Itoa(frame.Line))
+}
@@ -0,0 +1,41 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// Keys of the attributes added by LogOnCancel and Deadline
+const (
+	CauseKey    = "cause"
+	DeadlineKey = "deadline_remaining"
+)
+
+// LogOnCancel logs a Warn record with msg, the context error and its cause,
+// if different, once ctx is canceled or its deadline passes, so timeouts
+// surface in the logs instead of only as returned context errors.
+// The returned function stops the watch, like context.AfterFunc:
+//
+//	defer util.LogOnCancel(ctx, logger, "request aborted")()
+func LogOnCancel(ctx context.Context, logger *slog.Logger, msg string) func() bool {
+	return context.AfterFunc(ctx, func() {
+		attrs := []slog.Attr{Err(ctx.Err())}
+		if cause := context.Cause(ctx); cause != nil && !errors.Is(ctx.Err(), cause) {
+			attrs = append(attrs, slog.String(CauseKey, cause.Error()))
+		}
+		logger.LogAttrs(ctx, slog.LevelWarn, msg, attrs...)
+	})
+}
+
+// Deadline returns a DeadlineKey attribute with the time remaining until
+// the deadline of ctx, negative once it passed, or an empty attribute,
+// ignored by handlers, if ctx has no deadline
+func Deadline(ctx context.Context) slog.Attr {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return slog.Attr{}
+	}
+	return slog.Duration(DeadlineKey, time.Until(deadline))
+}
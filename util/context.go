@@ -3,41 +3,190 @@ package util
 import (
 	"context"
 	"log/slog"
-	"maps"
+	"slices"
 )
 
 type ctxKey int
 
 const (
 	logCtxKey ctxKey = iota
+	verboseCtxKey
 )
 
-type logCtx map[string]any
+// logField is a key-value pair of the log context
+type logField struct {
+	key   string
+	value any
+}
+
+// logCtx holds the log context fields in insertion order. A repeated key
+// keeps its first position and its last value. It is never modified once
+// stored in a context, updates work on copies
+type logCtx []logField
+
+// set sets the value of key in c
+func (c logCtx) set(key string, value any) logCtx {
+	for i := range c {
+		if c[i].key == key {
+			c[i].value = value
+			return c
+		}
+	}
+	return append(c, logField{key: key, value: value})
+}
+
+// get returns the value of key in c
+func (c logCtx) get(key string) (any, bool) {
+	for _, f := range c {
+		if f.key == key {
+			return f.value, true
+		}
+	}
+	return nil, false
+}
 
 // UpdateLogCtx adds a key-value pair to the context for logging
 // This function can be used to add structured data that will be included
 // in all subsequent log entries using this context
 func UpdateLogCtx(ctx context.Context, key string, value any) context.Context {
-	return updateLogCtx(ctx, logCtx{key: value})
+	return updateLogCtx(ctx, logCtx{{key: key, value: value}})
+}
+
+// UpdateLogCtxGroup adds a key-value pair nested in group to the context
+// for logging, so related values such as request details end up in one
+// group instead of the top-level namespace:
+//
+//	ctx = util.UpdateLogCtxGroup(ctx, "http", "method", r.Method)
+func UpdateLogCtxGroup(ctx context.Context, group, key string, value any) context.Context {
+	var members []slog.Attr
+	if existingCtx, ok := getLogCtx(ctx); ok {
+		if v, ok := existingCtx.get(group); ok {
+			if gv, ok := v.(slog.Value); ok && gv.Kind() == slog.KindGroup {
+				members = slices.Clone(gv.Group())
+			}
+		}
+	}
+
+	i := slices.IndexFunc(members, func(a slog.Attr) bool { return a.Key == key })
+	if i >= 0 {
+		members[i] = KV(key, value)
+	} else {
+		members = append(members, KV(key, value))
+	}
+	return updateLogCtx(ctx, logCtx{{key: group, value: slog.GroupValue(members...)}})
+}
+
+// CtxValue returns the logging value of key in the context as T, e.g.
+// one added with UpdateLogCtx, which keeps the Go type of the value.
+// It reports false if the key is missing or holds another type.
+// Values added with WithLogAttrs are returned as their slog.Value or,
+// for KindAny, the underlying value; slog.Int values are int64
+func CtxValue[T any](ctx context.Context, key string) (T, bool) {
+	var zero T
+	existingCtx, ok := getLogCtx(ctx)
+	if !ok {
+		return zero, false
+	}
+	v, ok := existingCtx.get(key)
+	if !ok {
+		return zero, false
+	}
+	if t, ok := v.(T); ok {
+		return t, true
+	}
+	if sv, ok := v.(slog.Value); ok {
+		t, ok := sv.Any().(T)
+		return t, ok
+	}
+	return zero, false
+}
+
+// WithLogAttrs adds attributes to the context for logging, like
+// UpdateLogCtx, keeping their slog values including groups and kinds:
+//
+//	ctx = util.WithLogAttrs(ctx, slog.Int("user_id", id), slog.Group("req", "method", r.Method))
+func WithLogAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	newCtx := addAttrs(make(logCtx, 0, len(attrs)), attrs)
+	if len(newCtx) == 0 {
+		return ctx
+	}
+	return updateLogCtx(ctx, newCtx)
+}
+
+// addAttrs adds attrs to c, inlining the members of groups without a key
+func addAttrs(c logCtx, attrs []slog.Attr) logCtx {
+	for _, a := range attrs {
+		switch {
+		case a.Key == "" && a.Value.Kind() == slog.KindGroup:
+			c = addAttrs(c, a.Value.Group())
+		case a.Key != "":
+			c = c.set(a.Key, a.Value)
+		}
+	}
+	return c
+}
+
+// DeleteLogCtx returns a context without the given logging keys, e.g. to
+// drop sensitive values before passing the context further down the stack
+func DeleteLogCtx(ctx context.Context, keys ...string) context.Context {
+	existingCtx, ok := getLogCtx(ctx)
+	if !ok {
+		return ctx
+	}
+	c := slices.DeleteFunc(slices.Clone(existingCtx), func(f logField) bool {
+		return slices.Contains(keys, f.key)
+	})
+	if len(c) == len(existingCtx) {
+		return ctx
+	}
+	return context.WithValue(ctx, logCtxKey, c)
+}
+
+// ClearLogCtx returns a context without any logging keys
+func ClearLogCtx(ctx context.Context) context.Context {
+	if _, ok := getLogCtx(ctx); !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, logCtxKey, logCtx{})
 }
 
 // ExtractLogAttrs extracts all logging attributes from a context
-// Returns the attributes as a slice of slog.Attr that can be added to a log record
+// Returns the attributes as a slice of slog.Attr that can be added to a log record,
+// in the order their keys were first added
 func ExtractLogAttrs(ctx context.Context) []slog.Attr {
 	if lctx, ok := getLogCtx(ctx); ok {
 		attrs := make([]slog.Attr, 0, len(lctx))
-		for k, v := range lctx {
-			attrs = append(attrs, KV(k, v))
+		for _, f := range lctx {
+			attrs = append(attrs, KV(f.key, f.value))
 		}
 		return attrs
 	}
 	return nil
 }
 
+// WithVerbose marks the context as verbose: handlers created by
+// grovelog.NewHandler log Debug records for it regardless of their level,
+// e.g. to debug the requests carrying a special header in production
+func WithVerbose(ctx context.Context) context.Context {
+	return context.WithValue(ctx, verboseCtxKey, true)
+}
+
+// IsVerbose reports whether the context was marked with WithVerbose
+func IsVerbose(ctx context.Context) bool {
+	verbose, _ := ctx.Value(verboseCtxKey).(bool)
+	return verbose
+}
+
+// updateLogCtx returns a context with newCtx merged into the log context
+// of ctx. The existing fields are copied, never modified, so contexts sharing
+// a parent don't see each other's values and concurrent updates don't race
 func updateLogCtx(ctx context.Context, newCtx logCtx) context.Context {
 	if existingCtx, ok := getLogCtx(ctx); ok {
-		maps.Copy(existingCtx, newCtx)
-		return context.WithValue(ctx, logCtxKey, existingCtx)
+		merged := slices.Clone(existingCtx)
+		for _, f := range newCtx {
+			merged = merged.set(f.key, f.value)
+		}
+		return context.WithValue(ctx, logCtxKey, merged)
 	}
 	return context.WithValue(ctx, logCtxKey, newCtx)
 }
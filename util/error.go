@@ -3,6 +3,8 @@ package util
 import (
 	"context"
 	"errors"
+	"fmt"
+	"slices"
 )
 
 // errorWithLogCtx is an error type that carries a logging context
@@ -19,22 +21,75 @@ func (e *errorWithLogCtx) Unwrap() error {
 	return e.err
 }
 
+// joinedErrorWithLogCtx carries a logging context for a multi-error, such
+// as one created by errors.Join, and unwraps to its branches like it does
+type joinedErrorWithLogCtx struct {
+	errorWithLogCtx
+	joined interface{ Unwrap() []error }
+}
+
+func (e *joinedErrorWithLogCtx) Unwrap() []error {
+	return e.joined.Unwrap()
+}
+
 // WrapCtx wraps an error with the logging context from the provided context
-// This allows context information to propagate along with errors
+// This allows context information to propagate along with errors.
+// Multi-errors, e.g. from errors.Join, stay multi-errors unwrapping to their branches
 func WrapCtx(ctx context.Context, err error) error {
 	if err == nil {
 		return nil
 	}
 	c, _ := getLogCtx(ctx)
+	if joined, ok := err.(interface{ Unwrap() []error }); ok { //nolint:errorlint
+		return &joinedErrorWithLogCtx{errorWithLogCtx: errorWithLogCtx{err: err, ctx: c}, joined: joined}
+	}
 	return &errorWithLogCtx{err: err, ctx: c}
 }
 
+// WrapCtxf wraps err with a message formatted by fmt.Errorf and then with
+// the logging context of ctx. err is the operand of the last verb of
+// format, which should be %w. Returns nil if err is nil:
+//
+//	return util.WrapCtxf(ctx, err, "loading user %d: %w", id)
+func WrapCtxf(ctx context.Context, err error, format string, args ...any) error {
+	if err == nil {
+		return nil
+	}
+	return WrapCtx(ctx, fmt.Errorf(format, append(args, err)...))
+}
+
 // ErrorCtx extracts logging context from an error (if it was wrapped with WrapCtx)
-// and adds it to the provided context
+// and adds it to the provided context. The contexts of all wrappers in the
+// error tree, including every branch of errors.Join, are merged. The
+// outermost wrapper wins on conflicting keys, then the earlier branch
 func ErrorCtx(ctx context.Context, err error) context.Context {
-	var errCtx *errorWithLogCtx
-	if errors.As(err, &errCtx) {
-		return updateLogCtx(ctx, errCtx.ctx)
+	chain := appendErrorCtx(nil, err)
+	for _, c := range slices.Backward(chain) {
+		if len(c) > 0 {
+			ctx = updateLogCtx(ctx, c)
+		}
 	}
 	return ctx
 }
+
+// appendErrorCtx appends the logging contexts in the tree of err in the
+// pre-order used by errors.As
+func appendErrorCtx(chain []logCtx, err error) []logCtx {
+	for err != nil {
+		switch e := err.(type) { //nolint:errorlint
+		case *errorWithLogCtx:
+			chain = append(chain, e.ctx)
+		case *joinedErrorWithLogCtx:
+			chain = append(chain, e.ctx)
+		}
+
+		if joined, ok := err.(interface{ Unwrap() []error }); ok { //nolint:errorlint
+			for _, branch := range joined.Unwrap() {
+				chain = appendErrorCtx(chain, branch)
+			}
+			return chain
+		}
+		err = errors.Unwrap(err)
+	}
+	return chain
+}
@@ -0,0 +1,83 @@
+package util
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Keys of the groups created by Request and Response
+const (
+	RequestKey  = "request"
+	ResponseKey = "response"
+)
+
+// Redacted replaces the values of sensitive query parameters
+const Redacted = "REDACTED"
+
+var (
+	redactMu     sync.RWMutex
+	redactParams = []string{
+		"access_token", "api_key", "apikey", "code", "key", "password",
+		"secret", "signature", "sig", "token",
+	}
+)
+
+// RedactQueryParams registers query parameter names, matched case
+// insensitively, whose values Request replaces with Redacted
+func RedactQueryParams(names ...string) {
+	redactMu.Lock()
+	defer redactMu.Unlock()
+	for _, name := range names {
+		redactParams = append(redactParams, strings.ToLower(name))
+	}
+}
+
+// Request creates a "request" group attribute with the method, path,
+// query, remote address and user agent of r, the query with the values
+// of sensitive parameters redacted, see RedactQueryParams, and the remote
+// address anonymized according to the privacy mode, see IP
+func Request(r *http.Request) slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+	}
+	if r.URL.RawQuery != "" {
+		attrs = append(attrs, slog.String("query", redactQuery(r.URL.Query())))
+	}
+	if r.RemoteAddr != "" {
+		attrs = append(attrs, IP("remote_addr", r.RemoteAddr))
+	}
+	if ua := r.UserAgent(); ua != "" {
+		attrs = append(attrs, slog.String("user_agent", ua))
+	}
+	return slog.Attr{Key: RequestKey, Value: slog.GroupValue(attrs...)}
+}
+
+// Response creates a "response" group attribute with the status code,
+// the body size and the time taken to serve the request
+func Response(status int, size int64, dur time.Duration) slog.Attr {
+	return slog.Group(ResponseKey,
+		slog.Int("status", status),
+		slog.Any("size", ByteSize(size)),
+		slog.Duration("duration", dur),
+	)
+}
+
+// redactQuery encodes query with the values of sensitive parameters redacted
+func redactQuery(query url.Values) string {
+	redactMu.RLock()
+	defer redactMu.RUnlock()
+	for name, values := range query {
+		if slices.Contains(redactParams, strings.ToLower(name)) {
+			for i := range values {
+				values[i] = Redacted
+			}
+		}
+	}
+	return query.Encode()
+}
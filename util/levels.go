@@ -0,0 +1,39 @@
+package util
+
+import (
+	"errors"
+	"log/slog"
+)
+
+// LevelError is implemented by errors that know the level they should be logged at
+type LevelError interface {
+	error
+	LogLevel() slog.Level
+}
+
+// CodedError is implemented by errors carrying a stable code or category,
+// logged as an additional "<key>.code" attribute
+type CodedError interface {
+	error
+	LogCode() string
+}
+
+// ErrLevel returns the level of the first error in the chain of err that
+// implements LevelError, or fallback if there is none
+func ErrLevel(err error, fallback slog.Level) slog.Level {
+	var le LevelError
+	if errors.As(err, &le) {
+		return le.LogLevel()
+	}
+	return fallback
+}
+
+// ErrCode returns the code of the first error in the chain of err that
+// implements CodedError
+func ErrCode(err error) (string, bool) {
+	var ce CodedError
+	if errors.As(err, &ce) {
+		return ce.LogCode(), true
+	}
+	return "", false
+}
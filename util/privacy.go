@@ -0,0 +1,116 @@
+package util
+
+import (
+	"log/slog"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync/atomic"
+)
+
+// PrivacyMode selects how IP, URL and UUID render personal data
+type PrivacyMode int32
+
+const (
+	// PrivacyOff logs values as they are
+	PrivacyOff PrivacyMode = iota
+	// PrivacyAnonymize masks the last octet of IPv4 addresses and the last
+	// 80 bits of IPv6 addresses, and strips the user info, query and
+	// fragment of URLs. UUIDs are kept
+	PrivacyAnonymize
+	// PrivacyRedact replaces IP addresses, URLs and UUIDs with Redacted
+	PrivacyRedact
+)
+
+// Invalid replaces values that IP, URL and UUID can't parse
+const Invalid = "INVALID"
+
+var privacyMode atomic.Int32
+
+// SetPrivacyMode sets the package-level privacy mode of IP, URL and UUID
+func SetPrivacyMode(mode PrivacyMode) {
+	privacyMode.Store(int32(mode))
+}
+
+// CurrentPrivacyMode returns the mode set with SetPrivacyMode
+func CurrentPrivacyMode() PrivacyMode {
+	return PrivacyMode(privacyMode.Load())
+}
+
+// IP creates a slog.Attr for an IP address, given with or without a port
+// like http.Request.RemoteAddr, anonymized according to the privacy mode
+func IP(key, ip string) slog.Attr {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		addrPort, err := netip.ParseAddrPort(ip)
+		if err != nil {
+			return slog.String(key, Invalid)
+		}
+		addr = addrPort.Addr()
+	}
+
+	switch CurrentPrivacyMode() {
+	case PrivacyAnonymize:
+		bits := 24
+		if !addr.Unmap().Is4() {
+			bits = 48
+		}
+		prefix, _ := addr.Unmap().Prefix(bits)
+		return slog.String(key, prefix.Addr().String())
+	case PrivacyRedact:
+		return slog.String(key, Redacted)
+	}
+	return slog.String(key, addr.String())
+}
+
+// URL creates a slog.Attr for a URL, anonymized according to the privacy mode
+func URL(key, rawURL string) slog.Attr {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return slog.String(key, Invalid)
+	}
+
+	switch CurrentPrivacyMode() {
+	case PrivacyAnonymize:
+		u.User = nil
+		u.RawQuery = ""
+		u.ForceQuery = false
+		u.Fragment = ""
+		u.RawFragment = ""
+	case PrivacyRedact:
+		return slog.String(key, Redacted)
+	}
+	return slog.String(key, u.String())
+}
+
+// UUID creates a slog.Attr for a UUID in canonical lower case form,
+// redacted in PrivacyRedact mode
+func UUID(key, uuid string) slog.Attr {
+	if !isUUID(uuid) {
+		return slog.String(key, Invalid)
+	}
+	if CurrentPrivacyMode() == PrivacyRedact {
+		return slog.String(key, Redacted)
+	}
+	return slog.String(key, strings.ToLower(uuid))
+}
+
+// isUUID reports whether s has the 8-4-4-4-12 hex digit form of a UUID
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i, c := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,53 @@
+package util
+
+import (
+	"log/slog"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// StackKey is the key of the attribute created by Stack
+const StackKey = "stack"
+
+// maxStackDepth limits the number of frames in captured stack traces
+const maxStackDepth = 32
+
+// Stack creates a slog.Attr with key "stack" and the stack of the calling
+// goroutine as value, see StackFrames. skip is the number of frames to
+// skip above the caller of Stack, e.g. 1 for a helper reporting its caller
+func Stack(skip int) slog.Attr {
+	return slog.Any(StackKey, StackFrames(skip+1))
+}
+
+// StackFrames returns the stack of the calling goroutine as
+// "function file:line" entries. It skips skip frames above the caller of
+// StackFrames and the frames of the logging packages (see IsInternalFrame),
+// and ends before the runtime entry points or after 32 frames
+func StackFrames(skip int) []string {
+	pcs := make([]uintptr, maxStackDepth+16)
+	n := runtime.Callers(skip+2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	stack := make([]string, 0, n)
+	skipping := true
+	for {
+		frame, more := frames.Next()
+		if skipping && IsInternalFrame(frame.Function) {
+			if !more {
+				break
+			}
+			continue
+		}
+		skipping = false
+
+		if strings.HasPrefix(frame.Function, "runtime.") || len(stack) == maxStackDepth {
+			break
+		}
+		stack = append(stack, frame.Function+" "+frame.File+":"+strconv.Itoa(frame.Line))
+		if !more {
+			break
+		}
+	}
+	return stack
+}
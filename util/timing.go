@@ -0,0 +1,67 @@
+package util
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"time"
+)
+
+// Keys of the attributes added by TimeOp and TimeOpErr
+const (
+	OpKey      = "op"
+	ElapsedKey = "elapsed"
+)
+
+// TimeOp starts timing an operation and returns a function logging an Info
+// record with the operation name and the elapsed time, meant to be deferred:
+//
+//	defer util.TimeOp(ctx, logger, "load_users")()
+func TimeOp(ctx context.Context, logger *slog.Logger, op string) func() {
+	pc := callerPC()
+	start := time.Now()
+	return func() {
+		logOp(ctx, logger, pc, slog.LevelInfo, op, time.Since(start), nil)
+	}
+}
+
+// TimeOpErr is like TimeOp but logs at Error level with the error when
+// *errp is not nil once the deferred function runs, e.g. for named results:
+//
+//	func load(ctx context.Context) (err error) {
+//		defer util.TimeOpErr(ctx, logger, "load_users", &err)()
+func TimeOpErr(ctx context.Context, logger *slog.Logger, op string, errp *error) func() {
+	pc := callerPC()
+	start := time.Now()
+	return func() {
+		var err error
+		if errp != nil {
+			err = *errp
+		}
+		level := slog.LevelInfo
+		if err != nil {
+			level = slog.LevelError
+		}
+		logOp(ctx, logger, pc, level, op, time.Since(start), err)
+	}
+}
+
+// callerPC returns the program counter of the caller of the caller of callerPC
+func callerPC() uintptr {
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:]) // Skip Callers, callerPC and its caller
+	return pcs[0]
+}
+
+// logOp logs an operation record with the source set to pc
+func logOp(ctx context.Context, logger *slog.Logger, pc uintptr, level slog.Level, op string, elapsed time.Duration, err error) {
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	r := slog.NewRecord(time.Now(), level, op, pc)
+	r.AddAttrs(slog.String(OpKey, op), slog.Duration(ElapsedKey, elapsed))
+	if err != nil {
+		r.AddAttrs(Err(err))
+	}
+	_ = logger.Handler().Handle(ctx, r)
+}
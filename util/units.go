@@ -0,0 +1,41 @@
+package util
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Key suffixes added by Duration, Bytes and Count
+const (
+	DurationSuffix = "_ms"
+	BytesSuffix    = "_bytes"
+	CountSuffix    = "_count"
+)
+
+// Duration creates a slog.Attr with key+"_ms" and d in milliseconds as
+// a float, so dashboards don't have to guess the unit of latencies:
+//
+//	util.Duration("latency", 1500*time.Microsecond) // latency_ms=1.5
+func Duration(key string, d time.Duration) slog.Attr {
+	return slog.Float64(withSuffix(key, DurationSuffix), float64(d)/float64(time.Millisecond))
+}
+
+// Bytes creates a slog.Attr with key+"_bytes" and n as a ByteSize,
+// numeric in JSON output and human readable in Color output
+func Bytes(key string, n int64) slog.Attr {
+	return slog.Any(withSuffix(key, BytesSuffix), ByteSize(n))
+}
+
+// Count creates a slog.Attr with key+"_count" and n as value
+func Count(key string, n int) slog.Attr {
+	return slog.Int(withSuffix(key, CountSuffix), n)
+}
+
+// withSuffix appends suffix to key unless it already ends with it
+func withSuffix(key, suffix string) string {
+	if strings.HasSuffix(key, suffix) {
+		return key
+	}
+	return key + suffix
+}
@@ -1,18 +1,140 @@
 package util
 
-import "log/slog"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// Keys of the attributes created by Err, ErrDetailed and Errs
+const (
+	ErrorKey        = "error"
+	ErrorsKey       = "errors"
+	ErrorMessageKey = "message"
+	ErrorTypeKey    = "type"
+	ErrorCodeKey    = "code"
+	ErrorChainKey   = "chain"
+)
 
 // Err creates a slog.Attr for an error
 // Returns an empty Attr if err is nil, otherwise creates an Attr with key "error"
-// and the error message as value
+// and the error as value. All formats render it as the error message, while
+// handlers can still inspect the error, e.g. for LevelError and CodedError
 func Err(err error) slog.Attr {
 	if err == nil {
 		return slog.Attr{}
 	}
 	return slog.Attr{
-		Key:   "error",
-		Value: slog.StringValue(err.Error()),
+		Key:   ErrorKey,
+		Value: slog.AnyValue(err),
+	}
+}
+
+// ErrDetailed creates an "error" group attribute with the message, the
+// dynamic type, the CodedError code and the messages of the unwrap chain,
+// so consumers can filter by error type. Returns an empty Attr if err is nil
+func ErrDetailed(err error) slog.Attr {
+	if err == nil {
+		return slog.Attr{}
+	}
+	return slog.Attr{Key: ErrorKey, Value: slog.GroupValue(ErrAttrs(err)...)}
+}
+
+// Errs creates an "errors" attribute rendering errs as an array of objects
+// with the message and the dynamic type of every error, e.g. for validation
+// and batch failures. Joined errors, e.g. from errors.Join, are flattened
+// into their branches and nil errors are skipped. Returns an empty Attr if
+// no error remains
+func Errs(errs ...error) slog.Attr {
+	var list errList
+	for _, err := range errs {
+		list = list.append(err)
+	}
+	if len(list) == 0 {
+		return slog.Attr{}
+	}
+	return slog.Any(ErrorsKey, list)
+}
+
+// errEntry is an element of the Errs array
+type errEntry struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+}
+
+// errList is the value of the Errs attribute
+type errList []errEntry
+
+// append appends err, or the branches of a joined error
+func (l errList) append(err error) errList {
+	if err == nil {
+		return l
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok { //nolint:errorlint
+		for _, e := range joined.Unwrap() {
+			l = l.append(e)
+		}
+		return l
+	}
+	return append(l, errEntry{Message: err.Error(), Type: fmt.Sprintf("%T", err)})
+}
+
+// MarshalJSON encodes the list as a JSON array
+func (l errList) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]errEntry(l))
+}
+
+// MarshalText renders the list for text formats as "message (type); ..."
+func (l errList) MarshalText() ([]byte, error) {
+	var buf []byte
+	for i, e := range l {
+		if i > 0 {
+			buf = append(buf, "; "...)
+		}
+		buf = fmt.Appendf(buf, "%s (%s)", e.Message, e.Type)
+	}
+	return buf, nil
+}
+
+// ErrAttrs returns the members of the group created by ErrDetailed
+func ErrAttrs(err error) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String(ErrorMessageKey, err.Error()),
+		slog.String(ErrorTypeKey, fmt.Sprintf("%T", err)),
+	}
+	if code, ok := ErrCode(err); ok {
+		attrs = append(attrs, slog.String(ErrorCodeKey, code))
+	}
+	if chain := ErrChain(err); len(chain) > 0 {
+		attrs = append(attrs, slog.Any(ErrorChainKey, chain))
+	}
+	return attrs
+}
+
+// ErrChain returns the messages of the errors wrapped by err, depth first.
+// Branches of joined errors are all included
+func ErrChain(err error) []string {
+	var chain []string
+	var walk func(err error)
+	walk = func(err error) {
+		switch x := err.(type) { //nolint:errorlint
+		case interface{ Unwrap() []error }:
+			for _, e := range x.Unwrap() {
+				if e != nil {
+					chain = append(chain, e.Error())
+					walk(e)
+				}
+			}
+		default:
+			if e := errors.Unwrap(err); e != nil {
+				chain = append(chain, e.Error())
+				walk(e)
+			}
+		}
 	}
+	walk(err)
+	return chain
 }
 
 // KV creates a slog.Attr with the given key and value
@@ -0,0 +1,545 @@
+package util_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AlonMell/grovelog"
+	"github.com/AlonMell/grovelog/util"
+)
+
+// TestTimeOp tests the deferred operation timing helpers
+func TestTimeOp(t *testing.T) {
+	var buf bytes.Buffer
+	logger := grovelog.NewLogger(&buf, grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON))
+	ctx := context.Background()
+
+	func() {
+		defer util.TimeOp(ctx, logger, "load_users")()
+		time.Sleep(time.Millisecond)
+	}()
+	_ = func() (err error) {
+		defer util.TimeOpErr(ctx, logger, "save_users", &err)()
+		return fmt.Errorf("disk full")
+	}()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 records, got %q", buf.String())
+	}
+	var load, save map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &load); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &save); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed, _ := load[util.ElapsedKey].(float64); load["level"] != "INFO" || load[util.OpKey] != "load_users" || elapsed < float64(time.Millisecond) {
+		t.Errorf("Unexpected TimeOp record %v", load)
+	}
+	if save["level"] != "ERROR" || save[util.OpKey] != "save_users" || save["error"] != "disk full" {
+		t.Errorf("Unexpected TimeOpErr record %v", save)
+	}
+}
+
+// chanWriter sends every write to a channel, to wait for asynchronous records
+type chanWriter chan string
+
+func (w chanWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
+
+// TestLogOnCancel tests the cancellation and deadline helpers
+func TestLogOnCancel(t *testing.T) {
+	records := make(chanWriter, 2)
+	logger := grovelog.NewLogger(records, grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON))
+
+	if attr := util.Deadline(context.Background()); !attr.Equal(slog.Attr{}) {
+		t.Errorf("Expected empty attribute without deadline, got %v", attr)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	if attr := util.Deadline(ctx); attr.Key != util.DeadlineKey || attr.Value.Duration() <= 59*time.Minute {
+		t.Errorf("Unexpected deadline attribute %v", attr)
+	}
+
+	stopped, stopCancel := context.WithCancel(context.Background())
+	util.LogOnCancel(stopped, logger, "stopped watch")()
+	stopCancel()
+
+	watched, cancelCause := context.WithCancelCause(context.Background())
+	util.LogOnCancel(watched, logger, "request aborted")
+	cancelCause(fmt.Errorf("client gone"))
+
+	var jsonMap map[string]any
+	if err := json.Unmarshal([]byte(<-records), &jsonMap); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	if jsonMap["level"] != "WARN" || jsonMap["msg"] != "request aborted" ||
+		jsonMap["error"] != context.Canceled.Error() || jsonMap[util.CauseKey] != "client gone" {
+		t.Errorf("Unexpected cancellation record %v", jsonMap)
+	}
+}
+
+// TestLogCtxCopyOnWrite tests that contexts derived from a common parent
+// don't share log context values
+func TestLogCtxCopyOnWrite(t *testing.T) {
+	parent := util.UpdateLogCtx(context.Background(), "trace_id", "t1")
+	a := util.UpdateLogCtx(parent, "user", "alice")
+	b := util.UpdateLogCtx(parent, "user", "bob")
+
+	keys := func(ctx context.Context) map[string]string {
+		m := make(map[string]string)
+		for _, a := range util.ExtractLogAttrs(ctx) {
+			m[a.Key] = a.Value.String()
+		}
+		return m
+	}
+	if got := keys(parent); len(got) != 1 || got["trace_id"] != "t1" {
+		t.Errorf("Expected the parent to keep only trace_id, got %v", got)
+	}
+	if got := keys(a); got["user"] != "alice" || got["trace_id"] != "t1" {
+		t.Errorf("Expected alice in the first child, got %v", got)
+	}
+	if got := keys(b); got["user"] != "bob" {
+		t.Errorf("Expected bob in the second child, got %v", got)
+	}
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = util.UpdateLogCtx(parent, "worker", i)
+		}()
+	}
+	wg.Wait()
+	if _, ok := keys(parent)["worker"]; ok {
+		t.Error("Expected concurrent updates to leave the parent unchanged")
+	}
+}
+
+// TestWithLogAttrs tests adding typed attributes to the log context
+func TestWithLogAttrs(t *testing.T) {
+	ctx := util.UpdateLogCtx(context.Background(), "trace_id", "t1")
+	ctx = util.WithLogAttrs(ctx,
+		slog.Int("user_id", 42),
+		slog.Duration("budget", time.Second),
+		slog.Group("req", slog.String("method", "GET")),
+		slog.Group("", slog.Bool("inlined", true)),
+		slog.Attr{},
+	)
+
+	attrs := util.ExtractLogAttrs(ctx)
+	slices.SortFunc(attrs, func(a, b slog.Attr) int { return strings.Compare(a.Key, b.Key) })
+	expected := []slog.Attr{
+		slog.Duration("budget", time.Second),
+		slog.Bool("inlined", true),
+		slog.Group("req", slog.String("method", "GET")),
+		slog.String("trace_id", "t1"),
+		slog.Int("user_id", 42),
+	}
+	if len(attrs) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, attrs)
+	}
+	for i := range expected {
+		if !attrs[i].Equal(expected[i]) {
+			t.Errorf("Expected %v, got %v", expected[i], attrs[i])
+		}
+	}
+
+	var buf bytes.Buffer
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.Color)
+	opts.Layout = grovelog.LayoutKeyValue
+	grovelog.NewLogger(&buf, opts).InfoContext(ctx, "typed")
+	if !strings.Contains(buf.String(), "req.method=GET") || !strings.Contains(buf.String(), "user_id=42") {
+		t.Errorf("Expected typed context attributes in the output. Got: %s", buf.String())
+	}
+}
+
+// TestDeleteLogCtx tests removing keys from the log context
+func TestDeleteLogCtx(t *testing.T) {
+	parent := util.UpdateLogCtx(context.Background(), "token", "secret")
+	parent = util.UpdateLogCtx(parent, "user", "alice")
+
+	ctx := util.DeleteLogCtx(parent, "token", "missing")
+	if attrs := util.ExtractLogAttrs(ctx); len(attrs) != 1 || attrs[0].Key != "user" {
+		t.Errorf("Expected only user after DeleteLogCtx, got %v", attrs)
+	}
+	if attrs := util.ExtractLogAttrs(parent); len(attrs) != 2 {
+		t.Errorf("Expected the parent to keep both keys, got %v", attrs)
+	}
+
+	ctx = util.ClearLogCtx(parent)
+	if attrs := util.ExtractLogAttrs(ctx); len(attrs) != 0 {
+		t.Errorf("Expected no attributes after ClearLogCtx, got %v", attrs)
+	}
+	ctx = util.UpdateLogCtx(ctx, "request_id", "r1")
+	if attrs := util.ExtractLogAttrs(ctx); len(attrs) != 1 || attrs[0].Key != "request_id" {
+		t.Errorf("Expected only request_id after updating a cleared context, got %v", attrs)
+	}
+}
+
+// TestLogCtxOrder tests that context attributes keep their insertion order
+func TestLogCtxOrder(t *testing.T) {
+	ctx := context.Background()
+	keys := []string{"trace_id", "user", "op", "region", "attempt", "shard"}
+	for i, key := range keys {
+		ctx = util.UpdateLogCtx(ctx, key, i)
+	}
+	ctx = util.UpdateLogCtx(ctx, "user", "bob") // Keeps its position
+
+	for range 10 {
+		attrs := util.ExtractLogAttrs(ctx)
+		got := make([]string, len(attrs))
+		for i, a := range attrs {
+			got[i] = a.Key
+		}
+		if !slices.Equal(got, keys) {
+			t.Fatalf("Expected keys in insertion order %v, got %v", keys, got)
+		}
+		if attrs[1].Value.String() != "bob" {
+			t.Errorf("Expected the updated user value, got %v", attrs[1])
+		}
+	}
+
+	var buf bytes.Buffer
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.Color)
+	opts.Layout = grovelog.LayoutKeyValue
+	grovelog.NewLogger(&buf, opts).InfoContext(ctx, "ordered")
+	if !strings.Contains(buf.String(), "trace_id=0 user=bob op=2 region=3 attempt=4 shard=5") {
+		t.Errorf("Expected context attributes in insertion order. Got: %s", buf.String())
+	}
+}
+
+// TestUpdateLogCtxGroup tests adding context attributes under a group
+func TestUpdateLogCtxGroup(t *testing.T) {
+	parent := util.UpdateLogCtx(context.Background(), "trace_id", "t1")
+	parent = util.UpdateLogCtxGroup(parent, "http", "method", "GET")
+	ctx := util.UpdateLogCtxGroup(parent, "http", "path", "/users")
+	ctx = util.UpdateLogCtxGroup(ctx, "http", "method", "POST")
+
+	attrs := util.ExtractLogAttrs(ctx)
+	expected := slog.Group("http", slog.String("method", "POST"), slog.String("path", "/users"))
+	if len(attrs) != 2 || !attrs[1].Equal(expected) {
+		t.Errorf("Expected %v, got %v", expected, attrs)
+	}
+	if attrs := util.ExtractLogAttrs(parent); len(attrs) != 2 || !attrs[1].Equal(slog.Group("http", slog.String("method", "GET"))) {
+		t.Errorf("Expected the parent group unchanged, got %v", attrs)
+	}
+
+	var buf bytes.Buffer
+	opts := grovelog.NewOptions(slog.LevelInfo, "", grovelog.Color)
+	opts.Layout = grovelog.LayoutKeyValue
+	grovelog.NewLogger(&buf, opts).InfoContext(ctx, "grouped")
+	if !strings.Contains(buf.String(), "http.method=POST http.path=/users") {
+		t.Errorf("Expected grouped context attributes. Got: %s", buf.String())
+	}
+}
+
+// TestCtxValue tests the typed context value helpers
+func TestCtxValue(t *testing.T) {
+	ctx := util.UpdateLogCtx(context.Background(), "timeout", 3*time.Second)
+	ctx = util.UpdateLogCtx(ctx, "retries", 2)
+	ctx = util.WithLogAttrs(ctx, slog.Int("shard", 7))
+
+	if timeout, ok := util.CtxValue[time.Duration](ctx, "timeout"); !ok || timeout != 3*time.Second {
+		t.Errorf("Expected timeout 3s, got %v, %v", timeout, ok)
+	}
+	if retries, ok := util.CtxValue[int](ctx, "retries"); !ok || retries != 2 {
+		t.Errorf("Expected retries 2, got %v, %v", retries, ok)
+	}
+	if shard, ok := util.CtxValue[int64](ctx, "shard"); !ok || shard != 7 {
+		t.Errorf("Expected shard 7, got %v, %v", shard, ok)
+	}
+	if _, ok := util.CtxValue[string](ctx, "retries"); ok {
+		t.Error("Expected a mismatched type to report false")
+	}
+	if _, ok := util.CtxValue[int](context.Background(), "retries"); ok {
+		t.Error("Expected a missing key to report false")
+	}
+
+	attrs := util.ExtractLogAttrs(ctx)
+	if attrs[0].Value.Kind() != slog.KindDuration || attrs[1].Value.Kind() != slog.KindInt64 {
+		t.Errorf("Expected typed attribute kinds, got %v", attrs)
+	}
+}
+
+// TestErrorCtxChain tests merging the log contexts of every wrapper in the error chain
+func TestErrorCtxChain(t *testing.T) {
+	repoCtx := util.UpdateLogCtx(context.Background(), "table", "users")
+	repoCtx = util.UpdateLogCtx(repoCtx, "layer", "repo")
+	err := util.WrapCtx(repoCtx, errors.New("no rows"))
+
+	serviceCtx := util.UpdateLogCtx(context.Background(), "user_id", 42)
+	serviceCtx = util.UpdateLogCtx(serviceCtx, "layer", "service")
+	err = util.WrapCtx(serviceCtx, fmt.Errorf("loading user: %w", err))
+
+	ctx := util.ErrorCtx(util.UpdateLogCtx(context.Background(), "request_id", "r1"), err)
+	got := make(map[string]string)
+	for _, a := range util.ExtractLogAttrs(ctx) {
+		got[a.Key] = a.Value.String()
+	}
+	expected := map[string]string{"request_id": "r1", "table": "users", "user_id": "42", "layer": "service"}
+	if !maps.Equal(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+// TestErrorCtxJoin tests log contexts across the branches of errors.Join
+func TestErrorCtxJoin(t *testing.T) {
+	errA := errors.New("disk full")
+	errB := errors.New("quota exceeded")
+	branchA := util.WrapCtx(util.UpdateLogCtx(context.Background(), "disk", "sda"), errA)
+	branchB := util.WrapCtx(util.UpdateLogCtx(context.Background(), "tenant", "acme"), errB)
+
+	outer := util.UpdateLogCtx(context.Background(), "op", "upload")
+	err := util.WrapCtx(outer, errors.Join(branchA, branchB))
+
+	if !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Error("Expected errors.Is to find both branches")
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); !ok || len(joined.Unwrap()) != 2 {
+		t.Errorf("Expected WrapCtx to keep the joined structure, got %T", err)
+	}
+
+	got := make(map[string]string)
+	for _, a := range util.ExtractLogAttrs(util.ErrorCtx(context.Background(), err)) {
+		got[a.Key] = a.Value.String()
+	}
+	expected := map[string]string{"op": "upload", "disk": "sda", "tenant": "acme"}
+	if !maps.Equal(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+// TestWrapCtxf tests formatting and context attachment in one call
+func TestWrapCtxf(t *testing.T) {
+	errNotFound := errors.New("not found")
+	ctx := util.UpdateLogCtx(context.Background(), "table", "users")
+
+	err := util.WrapCtxf(ctx, errNotFound, "loading user %d: %w", 42)
+	if err.Error() != "loading user 42: not found" {
+		t.Errorf("Expected the formatted message, got %q", err.Error())
+	}
+	if !errors.Is(err, errNotFound) {
+		t.Error("Expected errors.Is to find the wrapped error")
+	}
+	if attrs := util.ExtractLogAttrs(util.ErrorCtx(context.Background(), err)); len(attrs) != 1 || attrs[0].Key != "table" {
+		t.Errorf("Expected the table context attribute, got %v", attrs)
+	}
+	if util.WrapCtxf(ctx, nil, "loading user %d: %w", 42) != nil {
+		t.Error("Expected nil for a nil error")
+	}
+}
+
+// TestErrDetailed tests the structured error group attribute
+func TestErrDetailed(t *testing.T) {
+	var buf bytes.Buffer
+	logger := grovelog.NewLogger(&buf, grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON))
+
+	base := errors.New("connection refused")
+	logger.Error("query failed", util.ErrDetailed(fmt.Errorf("querying users: %w", base)))
+
+	var record struct {
+		Error struct {
+			Message string   `json:"message"`
+			Type    string   `json:"type"`
+			Chain   []string `json:"chain"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Failed to parse JSON: %v", err)
+	}
+	if record.Error.Message != "querying users: connection refused" || record.Error.Type != "*fmt.wrapError" {
+		t.Errorf("Unexpected error group %+v", record.Error)
+	}
+	if !slices.Equal(record.Error.Chain, []string{"connection refused"}) {
+		t.Errorf("Expected the unwrap chain, got %v", record.Error.Chain)
+	}
+	if !util.ErrDetailed(nil).Equal(slog.Attr{}) {
+		t.Error("Expected an empty Attr for a nil error")
+	}
+}
+
+type domainError struct{}
+
+func (e *domainError) Error() string { return "domain failure" }
+
+// TestErrs tests rendering several errors as an array attribute
+func TestErrs(t *testing.T) {
+	errName := errors.New("name is required")
+	errAge := fmt.Errorf("age: %w", errors.New("must be positive"))
+	errEmail := &domainError{}
+
+	var buf bytes.Buffer
+	logger := grovelog.NewLogger(&buf, grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON))
+	logger.Warn("validation failed", util.Errs(errors.Join(errName, errAge), nil, errEmail))
+
+	var record struct {
+		Errors []struct {
+			Message string `json:"message"`
+			Type    string `json:"type"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Failed to parse JSON: %v. Got: %s", err, buf.String())
+	}
+	if len(record.Errors) != 3 {
+		t.Fatalf("Expected 3 errors, got %+v", record.Errors)
+	}
+	if record.Errors[1].Message != "age: must be positive" || record.Errors[1].Type != "*fmt.wrapError" {
+		t.Errorf("Unexpected second error %+v", record.Errors[1])
+	}
+	if record.Errors[2].Type != "*util_test.domainError" {
+		t.Errorf("Expected the domain error type, got %+v", record.Errors[2])
+	}
+
+	buf.Reset()
+	logger = grovelog.NewLogger(&buf, grovelog.NewOptions(slog.LevelInfo, "", grovelog.Plain))
+	logger.Warn("validation failed", util.Errs(errName, errAge))
+	if !strings.Contains(buf.String(), `errors="name is required (*errors.errorString); age: must be positive (*fmt.wrapError)"`) {
+		t.Errorf("Expected the text rendering of the errors. Got: %s", buf.String())
+	}
+	if !util.Errs(nil).Equal(slog.Attr{}) {
+		t.Error("Expected an empty Attr without errors")
+	}
+}
+
+// TestHTTPAttrs tests the request and response attribute builders
+func TestHTTPAttrs(t *testing.T) {
+	util.RedactQueryParams("Session")
+	r := httptest.NewRequest(http.MethodGet, "/users?page=2&token=abc&session=s1", nil)
+	r.Header.Set("User-Agent", "curl/8.0")
+
+	h := grovelog.NewMemoryHandler(slog.LevelInfo)
+	slog.New(h).Info("served", util.Request(r), util.Response(http.StatusOK, 2048, 15*time.Millisecond))
+
+	records := h.Records()
+	for _, q := range []struct {
+		key   string
+		value any
+	}{
+		{"request.method", "GET"},
+		{"request.path", "/users"},
+		{"request.query", "page=2&session=REDACTED&token=REDACTED"},
+		{"request.remote_addr", "192.0.2.1"},
+		{"request.user_agent", "curl/8.0"},
+		{"response.status", 200},
+		{"response.size", "2.0 KiB"},
+		{"response.duration", "15ms"},
+	} {
+		if len(records.Where(q.key, "=", q.value)) != 1 {
+			t.Errorf("Expected %s = %v, got %v", q.key, q.value, records[0].Attrs)
+		}
+	}
+
+	util.SetPrivacyMode(util.PrivacyAnonymize)
+	defer util.SetPrivacyMode(util.PrivacyOff)
+	h.Reset()
+	slog.New(h).Info("served", util.Request(r))
+	if len(h.Records().Where("request.remote_addr", "=", "192.0.2.0")) != 1 {
+		t.Errorf("Expected the anonymized remote address, got %v", h.Records())
+	}
+}
+
+// stackHelper reports the stack of its caller
+func stackHelper() slog.Attr {
+	return util.Stack(1)
+}
+
+// TestStack tests the multi-frame stack trace attribute
+func TestStack(t *testing.T) {
+	a := util.Stack(0)
+	stack, ok := a.Value.Any().([]string)
+	if a.Key != util.StackKey || !ok || len(stack) == 0 {
+		t.Fatalf("Expected a stack attribute, got %v", a)
+	}
+	if !strings.HasPrefix(stack[0], "github.com/AlonMell/grovelog/util_test.TestStack ") || !strings.Contains(stack[0], "util_test.go:") {
+		t.Errorf("Expected the stack to start at the caller, got %v", stack[0])
+	}
+	for _, frame := range stack {
+		if strings.HasPrefix(frame, "runtime.") {
+			t.Errorf("Expected runtime frames trimmed, got %v", stack)
+		}
+	}
+
+	stack, _ = stackHelper().Value.Any().([]string)
+	if len(stack) == 0 || !strings.HasPrefix(stack[0], "github.com/AlonMell/grovelog/util_test.TestStack ") {
+		t.Errorf("Expected skip to drop the helper frame, got %v", stack)
+	}
+}
+
+// TestUnitAttrs tests the Duration, Bytes and Count helpers
+func TestUnitAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	logger := grovelog.NewLogger(&buf, grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON))
+	logger.Info("done",
+		util.Duration("latency", 1500*time.Microsecond),
+		util.Bytes("size_bytes", 4096),
+		util.Count("retries", 3))
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Failed to parse JSON: %v", err)
+	}
+	expected := map[string]float64{"latency_ms": 1.5, "size_bytes": 4096, "retries_count": 3}
+	for key, value := range expected {
+		if record[key] != value {
+			t.Errorf("Expected %s = %v, got %v", key, value, record[key])
+		}
+	}
+}
+
+// TestPrivacyAttrs tests the IP, URL and UUID helpers in every privacy mode
+func TestPrivacyAttrs(t *testing.T) {
+	defer util.SetPrivacyMode(util.PrivacyOff)
+
+	const (
+		ip   = "203.0.113.77:8080"
+		ip6  = "2001:db8:1234:5678::1"
+		link = "https://bob:pw@example.com/reset?token=abc#top"
+		id   = "0F8FAD5B-D9CB-469F-A165-70867728950E"
+	)
+	tests := []struct {
+		mode                util.PrivacyMode
+		ip, ip6, link, uuid string
+	}{
+		{util.PrivacyOff, "203.0.113.77", "2001:db8:1234:5678::1", link, "0f8fad5b-d9cb-469f-a165-70867728950e"},
+		{util.PrivacyAnonymize, "203.0.113.0", "2001:db8:1234::", "https://example.com/reset", "0f8fad5b-d9cb-469f-a165-70867728950e"},
+		{util.PrivacyRedact, util.Redacted, util.Redacted, util.Redacted, util.Redacted},
+	}
+	for _, tt := range tests {
+		util.SetPrivacyMode(tt.mode)
+		for _, c := range []struct{ got, expected string }{
+			{util.IP("ip", ip).Value.String(), tt.ip},
+			{util.IP("ip", ip6).Value.String(), tt.ip6},
+			{util.URL("url", link).Value.String(), tt.link},
+			{util.UUID("id", id).Value.String(), tt.uuid},
+		} {
+			if c.got != c.expected {
+				t.Errorf("Mode %d: expected %q, got %q", tt.mode, c.expected, c.got)
+			}
+		}
+	}
+
+	for _, a := range []slog.Attr{util.IP("ip", "not-an-ip"), util.URL("url", "http://[::1"), util.UUID("id", "1234")} {
+		if a.Value.String() != util.Invalid {
+			t.Errorf("Expected %s to be invalid, got %v", a.Key, a.Value)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package grovelog
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/AlonMell/grovelog/util"
+)
+
+// verboseHandler enables Debug records for contexts marked with util.WithVerbose
+type verboseHandler struct {
+	next slog.Handler
+}
+
+// Enabled reports whether the wrapped handler handles level, or level
+// is at least Debug and ctx is verbose
+func (h *verboseHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level) || level >= slog.LevelDebug && util.IsVerbose(ctx)
+}
+
+// Handle passes the record on
+func (h *verboseHandler) Handle(ctx context.Context, r slog.Record) error { //nolint:gocritic
+	return h.next.Handle(ctx, r)
+}
+
+// WithAttrs returns a verboseHandler wrapping the handler with attrs
+func (h *verboseHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &verboseHandler{next: h.next.WithAttrs(attrs)}
+}
+
+// WithGroup returns a verboseHandler wrapping the grouped handler
+func (h *verboseHandler) WithGroup(name string) slog.Handler {
+	return &verboseHandler{next: h.next.WithGroup(name)}
+}
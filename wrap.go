@@ -0,0 +1,49 @@
+package grovelog
+
+import (
+	"io"
+	"log/slog"
+)
+
+// wrapHandler wraps h with the record level middlewares enabled in opts
+func wrapHandler(h slog.Handler, out io.Writer, opts Options) slog.Handler {
+	if s, ok := out.(syncer); ok && opts.SyncLevel != nil {
+		h = &syncHandler{next: h, out: s, level: opts.SyncLevel}
+	}
+	if opts.StandardFields != nil {
+		if attrs := opts.StandardFields.attrs(); len(attrs) > 0 {
+			h = h.WithAttrs(attrs)
+		}
+	}
+	if opts.AddGoroutineID {
+		h = &goroutineHandler{next: h}
+	}
+	if opts.AddCaller {
+		h = &callerHandler{next: h}
+	}
+	if opts.ErrorLevels {
+		h = &errorLevelHandler{next: h, metrics: opts.Metrics}
+	}
+	if opts.ErrorTracker != nil {
+		h = &fingerprintHandler{next: h, tracker: opts.ErrorTracker}
+	}
+	if opts.StackTraceLevel != nil {
+		h = &stackHandler{next: h, level: opts.StackTraceLevel}
+	}
+	limited := opts.MaxMessageLen > 0 || opts.MaxAttrValueLen > 0 || opts.MaxAttrs > 0
+	if limited && resolveFormat(out, opts) != Color {
+		h = &limitHandler{
+			derived:  newDerived(h),
+			maxMsg:   opts.MaxMessageLen,
+			maxValue: opts.MaxAttrValueLen,
+			maxAttrs: opts.MaxAttrs,
+		}
+	}
+	if opts.Expvar != "" {
+		h = &expvarHandler{next: h, m: expvarMap(opts.Expvar)}
+	}
+	if opts.Metrics != nil {
+		h = &metricsHandler{next: h, metrics: opts.Metrics}
+	}
+	return h
+}
@@ -0,0 +1,115 @@
+package grovelog
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// LineWriter is an io.Writer logging every written line as a record,
+// e.g. for exec.Cmd.Stdout or http.Server.ErrorLog. Incomplete lines are
+// buffered until the next newline or Close
+type LineWriter struct {
+	logger *slog.Logger
+	level  slog.Level
+	parse  lineParser
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+// Writer returns a LineWriter logging lines to l at level
+func (l *Logger) Writer(level slog.Level) *LineWriter {
+	return &LineWriter{logger: l.Logger, level: level}
+}
+
+// LevelWriter returns a LineWriter like Writer where a leading "[ERROR]",
+// "ERROR:", "[WARN]", "WARNING:" etc. prefix selects the level of the
+// line and is removed from the message. Lines without one are logged at
+// fallback
+func (l *Logger) LevelWriter(fallback slog.Level) *LineWriter {
+	return &LineWriter{logger: l.Logger, level: fallback, parse: parseLevelPrefix}
+}
+
+// lineParser returns the level, message and attributes of a line,
+// fallback is the level of lines without a recognized level
+type lineParser func(line string, fallback slog.Level) (slog.Level, string, []slog.Attr)
+
+// Write logs every complete line of p
+func (w *LineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.log(string(bytes.TrimSuffix(w.buf[:i], []byte{'\r'})))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Close logs the buffered incomplete line, if any
+func (w *LineWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) > 0 {
+		w.log(string(w.buf))
+		w.buf = nil
+	}
+	return nil
+}
+
+func (w *LineWriter) log(line string) {
+	level := w.level
+	var attrs []slog.Attr
+	if w.parse != nil {
+		level, line, attrs = w.parse(line, level)
+	}
+	if line == "" {
+		return
+	}
+	w.logger.LogAttrs(context.Background(), level, line, attrs...)
+}
+
+// levelPrefixes maps the level names recognized by parseLevelPrefix
+var levelPrefixes = map[string]slog.Level{
+	"DEBUG":   slog.LevelDebug,
+	"INFO":    slog.LevelInfo,
+	"WARN":    slog.LevelWarn,
+	"WARNING": slog.LevelWarn,
+	"ERROR":   slog.LevelError,
+}
+
+// parseLevelPrefix returns the level named by a "[LEVEL]" or "LEVEL:"
+// prefix of line and the rest of the line, or fallback and line if
+// there is no such prefix
+func parseLevelPrefix(line string, fallback slog.Level) (slog.Level, string, []slog.Attr) {
+	var name, rest string
+	switch {
+	case strings.HasPrefix(line, "["):
+		end := strings.IndexByte(line, ']')
+		if end < 0 {
+			return fallback, line, nil
+		}
+		name, rest = line[1:end], line[end+1:]
+	default:
+		end := strings.IndexByte(line, ':')
+		if end < 0 {
+			return fallback, line, nil
+		}
+		name, rest = line[:end], line[end+1:]
+	}
+
+	level, ok := levelPrefixes[strings.ToUpper(name)]
+	if !ok {
+		return fallback, line, nil
+	}
+	return level, strings.TrimSpace(rest), nil
+}
@@ -0,0 +1,158 @@
+// Package zapbridge forwards zap log entries into a grovelog handler, so
+// services migrating from zap produce a single consistent output format
+package zapbridge
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/AlonMell/grovelog"
+	"go.uber.org/zap/zapcore"
+)
+
+// LoggerKey is the attribute key of the zap logger name, rendered as
+// the logger name column in Color format
+const LoggerKey = grovelog.LoggerKey
+
+// Core is a zapcore.Core writing entries to a slog.Handler
+type Core struct {
+	handler slog.Handler
+}
+
+// NewCore returns a Core writing to h, e.g. grovelog.NewHandler:
+//
+//	logger := zap.New(zapbridge.NewCore(grovelog.NewHandler(os.Stdout, opts)))
+func NewCore(h slog.Handler) *Core {
+	return &Core{handler: h}
+}
+
+// Enabled reports whether the handler accepts records at level
+func (c *Core) Enabled(level zapcore.Level) bool {
+	return c.handler.Enabled(context.Background(), slogLevel(level))
+}
+
+// With returns a Core adding fields to every entry. zap namespaces
+// become slog groups
+func (c *Core) With(fields []zapcore.Field) zapcore.Core {
+	h := c.handler
+	var attrs []slog.Attr
+	for _, f := range fields {
+		if f.Type == zapcore.NamespaceType {
+			if len(attrs) > 0 {
+				h = h.WithAttrs(attrs)
+				attrs = nil
+			}
+			h = h.WithGroup(f.Key)
+			continue
+		}
+		if attr := fieldAttr(f); attr.Key != "" {
+			attrs = append(attrs, attr)
+		}
+	}
+	if len(attrs) > 0 {
+		h = h.WithAttrs(attrs)
+	}
+	return &Core{handler: h}
+}
+
+// Check adds the Core to ce if the entry level is enabled
+func (c *Core) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write converts the entry and fields to a record and handles it
+func (c *Core) Write(ent zapcore.Entry, fields []zapcore.Field) error { //nolint:gocritic
+	var pc uintptr
+	if ent.Caller.Defined {
+		pc = ent.Caller.PC
+	}
+	r := slog.NewRecord(ent.Time, slogLevel(ent.Level), ent.Message, pc)
+	if ent.LoggerName != "" {
+		r.AddAttrs(slog.String(LoggerKey, ent.LoggerName))
+	}
+	r.AddAttrs(fieldAttrs(fields)...)
+	if ent.Stack != "" {
+		r.AddAttrs(slog.String(grovelog.StackKey, ent.Stack))
+	}
+	return c.handler.Handle(context.Background(), r)
+}
+
+// Sync is a no-op, grovelog handlers write synchronously
+func (c *Core) Sync() error {
+	return nil
+}
+
+// slogLevel maps zap levels to slog levels. DPanic, Panic and Fatal
+// map to levels above Error
+func slogLevel(level zapcore.Level) slog.Level {
+	switch {
+	case level < zapcore.InfoLevel:
+		return slog.LevelDebug
+	case level == zapcore.InfoLevel:
+		return slog.LevelInfo
+	case level == zapcore.WarnLevel:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError + slog.Level(level-zapcore.ErrorLevel)
+	}
+}
+
+// fieldAttrs converts fields to attributes, nesting the fields following
+// a namespace in a group
+func fieldAttrs(fields []zapcore.Field) []slog.Attr {
+	attrs := make([]slog.Attr, 0, len(fields))
+	for i, f := range fields {
+		if f.Type == zapcore.NamespaceType {
+			return append(attrs, slog.Attr{Key: f.Key, Value: slog.GroupValue(fieldAttrs(fields[i+1:])...)})
+		}
+		if attr := fieldAttr(f); attr.Key != "" {
+			attrs = append(attrs, attr)
+		}
+	}
+	return attrs
+}
+
+// fieldAttr converts a field to an attribute, keeping errors and
+// durations typed so grovelog can render them
+func fieldAttr(f zapcore.Field) slog.Attr { //nolint:gocritic
+	switch f.Type {
+	case zapcore.SkipType:
+		return slog.Attr{}
+	case zapcore.StringType:
+		return slog.String(f.Key, f.String)
+	case zapcore.BoolType:
+		return slog.Bool(f.Key, f.Integer == 1)
+	case zapcore.Int64Type, zapcore.Int32Type, zapcore.Int16Type, zapcore.Int8Type:
+		return slog.Int64(f.Key, f.Integer)
+	case zapcore.Uint64Type, zapcore.Uint32Type, zapcore.Uint16Type, zapcore.Uint8Type:
+		return slog.Uint64(f.Key, uint64(f.Integer))
+	case zapcore.Float64Type:
+		return slog.Float64(f.Key, math.Float64frombits(uint64(f.Integer)))
+	case zapcore.Float32Type:
+		return slog.Float64(f.Key, float64(math.Float32frombits(uint32(f.Integer))))
+	case zapcore.DurationType:
+		return slog.Duration(f.Key, time.Duration(f.Integer))
+	case zapcore.TimeType:
+		t := time.Unix(0, f.Integer)
+		if loc, ok := f.Interface.(*time.Location); ok {
+			t = t.In(loc)
+		}
+		return slog.Time(f.Key, t)
+	case zapcore.ErrorType:
+		return slog.Any(f.Key, f.Interface)
+	case zapcore.StringerType:
+		return slog.String(f.Key, fmt.Sprint(f.Interface))
+	}
+
+	// Objects, arrays, reflected values and the remaining types
+	// are rendered by zap's map encoder
+	enc := zapcore.NewMapObjectEncoder()
+	f.AddTo(enc)
+	return slog.Any(f.Key, enc.Fields[f.Key])
+}
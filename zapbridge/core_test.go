@@ -0,0 +1,41 @@
+package zapbridge_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/AlonMell/grovelog"
+	"github.com/AlonMell/grovelog/zapbridge"
+	"go.uber.org/zap"
+)
+
+// TestZapBridge tests forwarding zap entries to a grovelog handler
+func TestZapBridge(t *testing.T) {
+	var buf bytes.Buffer
+	h := grovelog.NewHandler(&buf, grovelog.NewOptions(slog.LevelInfo, "", grovelog.JSON))
+	logger := zap.New(zapbridge.NewCore(h)).Named("orders").With(zap.String("service", "billing"))
+
+	logger.Debug("hidden")
+	logger.Warn("slow query",
+		zap.Int("rows", 42),
+		zap.Duration("elapsed", 1500*time.Millisecond),
+		zap.Error(fmt.Errorf("timeout")),
+		zap.Namespace("db"),
+		zap.String("table", "orders"))
+
+	var jsonMap map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &jsonMap); err != nil {
+		t.Fatalf("Failed to parse JSON output %q: %v", buf.String(), err)
+	}
+	db, _ := jsonMap["db"].(map[string]any)
+	if jsonMap["level"] != "WARN" || jsonMap["msg"] != "slow query" ||
+		jsonMap["logger"] != "orders" || jsonMap["service"] != "billing" ||
+		jsonMap["rows"] != float64(42) || jsonMap["elapsed"] != float64(1500*time.Millisecond) ||
+		jsonMap["error"] != "timeout" || db["table"] != "orders" {
+		t.Errorf("Unexpected bridged record %v", jsonMap)
+	}
+}